@@ -0,0 +1,48 @@
+// Package google configures "Sign in with Google" (OpenID Connect).
+package google
+
+import (
+	"os"
+	"viral-cuts-server/oauth"
+)
+
+// ProviderID is the account.provider_id of users signed in with Google.
+// Connected YouTube channels use their own provider id, so the two never share a row.
+const ProviderID = "google"
+
+// Scopes requested at sign-in
+var Scopes = []string{"openid", "email", "profile"}
+
+// NewOAuthConfig builds the sign-in OAuth2 config from the environment.
+// GOOGLE_AUTH_URL and GOOGLE_TOKEN_URL can point at a local fake issuer.
+func NewOAuthConfig() *oauth.Config {
+	redirectURL := os.Getenv("GOOGLE_SIGNIN_REDIRECT_URL")
+	if redirectURL == "" {
+		redirectURL = envOr("BACKEND_URL", "http://localhost:3000") + "/api/auth/google/callback"
+	}
+
+	return &oauth.Config{
+		ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
+		ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
+		AuthURL:      envOr("GOOGLE_AUTH_URL", "https://accounts.google.com/o/oauth2/v2/auth"),
+		TokenURL:     envOr("GOOGLE_TOKEN_URL", "https://oauth2.googleapis.com/token"),
+		RedirectURL:  redirectURL,
+		Scopes:       Scopes,
+	}
+}
+
+// NewVerifier checks Google ID tokens. GOOGLE_ISSUER and GOOGLE_JWKS_URL can point at a local fake issuer.
+func NewVerifier() *oauth.Verifier {
+	issuers := []string{"https://accounts.google.com", "accounts.google.com"}
+	if issuer := os.Getenv("GOOGLE_ISSUER"); issuer != "" {
+		issuers = []string{issuer}
+	}
+	return oauth.NewVerifier(issuers, os.Getenv("GOOGLE_CLIENT_ID"), envOr("GOOGLE_JWKS_URL", "https://www.googleapis.com/oauth2/v3/certs"))
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -1,21 +1,23 @@
 package handlers
 
 import (
-	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
+	"viral-cuts-server/models"
+	"viral-cuts-server/store"
 
 	"github.com/gin-gonic/gin"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type AdminHandler struct {
-	db *pgxpool.Pool
+	store *store.Store
 }
 
-func NewAdminHandler(db *pgxpool.Pool) *AdminHandler {
-	return &AdminHandler{db: db}
+func NewAdminHandler(s *store.Store) *AdminHandler {
+	return &AdminHandler{store: s}
 }
 
 type UserResponse struct {
@@ -24,6 +26,7 @@ type UserResponse struct {
 	Email         string `json:"email"`
 	EmailVerified bool   `json:"emailVerified"`
 	Image         string `json:"image,omitempty"`
+	Role          string `json:"role"`
 	CreatedAt     string `json:"createdAt"`
 	UpdatedAt     string `json:"updatedAt"`
 }
@@ -51,76 +54,30 @@ func (h *AdminHandler) GetUsers(c *gin.Context) {
 
 	offset := (page - 1) * pageSize
 
-	// Build query with snake_case column names
-	var countQuery, selectQuery string
-	var args []interface{}
-
-	if search != "" {
-		countQuery = `SELECT COUNT(*) FROM "user" WHERE name ILIKE $1 OR email ILIKE $1`
-		selectQuery = `
-			SELECT id, name, email, email_verified, image, created_at, updated_at
-			FROM "user"
-			WHERE name ILIKE $1 OR email ILIKE $1
-			ORDER BY created_at DESC
-			LIMIT $2 OFFSET $3
-		`
-		searchPattern := "%" + search + "%"
-		args = []interface{}{searchPattern, pageSize, offset}
-	} else {
-		countQuery = `SELECT COUNT(*) FROM "user"`
-		selectQuery = `
-			SELECT id, name, email, email_verified, image, created_at, updated_at
-			FROM "user"
-			ORDER BY created_at DESC
-			LIMIT $1 OFFSET $2
-		`
-		args = []interface{}{pageSize, offset}
-	}
-
-	// Get total count
-	var total int
-	var err error
-	if search != "" {
-		err = h.db.QueryRow(c.Request.Context(), countQuery, "%"+search+"%").Scan(&total)
-	} else {
-		err = h.db.QueryRow(c.Request.Context(), countQuery).Scan(&total)
-	}
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count users", "details": err.Error()})
-		return
-	}
-
-	// Get users
-	rows, err := h.db.Query(c.Request.Context(), selectQuery, args...)
+	list, total, err := h.store.Users.List(c.Request.Context(), store.UserFilter{
+		Search: search,
+		Limit:  pageSize,
+		Offset: offset,
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users", "details": err.Error()})
 		return
 	}
-	defer rows.Close()
 
 	users := []UserResponse{}
-	for rows.Next() {
-		var user UserResponse
-		var imageNull sql.NullString
-		var createdAt, updatedAt time.Time
-		err := rows.Scan(
-			&user.ID,
-			&user.Name,
-			&user.Email,
-			&user.EmailVerified,
-			&imageNull,
-			&createdAt,
-			&updatedAt,
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan user row", "details": err.Error()})
-			return
+	for _, u := range list {
+		user := UserResponse{
+			ID:            u.ID,
+			Name:          u.Name,
+			Email:         u.Email,
+			EmailVerified: u.EmailVerified,
+			Role:          u.Role,
+			CreatedAt:     u.CreatedAt.Format(time.RFC3339),
+			UpdatedAt:     u.UpdatedAt.Format(time.RFC3339),
 		}
-		if imageNull.Valid {
-			user.Image = imageNull.String
+		if u.Image != nil {
+			user.Image = *u.Image
 		}
-		user.CreatedAt = createdAt.Format(time.RFC3339)
-		user.UpdatedAt = updatedAt.Format(time.RFC3339)
 		users = append(users, user)
 	}
 
@@ -134,3 +91,140 @@ func (h *AdminHandler) GetUsers(c *gin.Context) {
 		TotalPages: totalPages,
 	})
 }
+
+// UpdateRoleRequest represents the role change request body
+type UpdateRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=user admin"`
+}
+
+// UpdateUserRole handles PUT /api/admin/users/:id/role
+func (h *AdminHandler) UpdateUserRole(c *gin.Context) {
+	var req UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	targetID := c.Param("id")
+	admin, _ := CurrentUser(c)
+
+	// Prevent admins from locking themselves out
+	if admin.ID == targetID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot change your own role"})
+		return
+	}
+
+	oldRole, err := h.store.Users.UpdateRole(c.Request.Context(), targetID, req.Role, admin.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+
+	if oldRole == req.Role {
+		c.JSON(http.StatusOK, gin.H{"message": "Role unchanged", "role": oldRole})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role updated successfully", "role": req.Role})
+}
+
+// ResetUserMFA handles DELETE /api/admin/users/:id/mfa for users who lost their authenticator
+// and recovery codes. The user signs in with the password alone afterwards.
+func (h *AdminHandler) ResetUserMFA(c *gin.Context) {
+	targetID := c.Param("id")
+	admin, _ := CurrentUser(c)
+	ctx := c.Request.Context()
+
+	if _, err := h.store.Users.GetByID(ctx, targetID); errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if err := h.store.MFA.Delete(ctx, targetID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset two-factor authentication"})
+		return
+	}
+	fmt.Printf("Admin %s reset two-factor authentication of user %s\n", admin.ID, targetID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset"})
+}
+
+// GetRoleChanges handles GET /api/admin/role-changes?userId=xxx
+func (h *AdminHandler) GetRoleChanges(c *gin.Context) {
+	changes, err := h.store.Users.RoleChanges(c.Request.Context(), c.Query("userId"), 100)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch role changes", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"changes": changes})
+}
+
+type OutboxListResponse struct {
+	Emails     []*models.OutboxEmail `json:"emails"`
+	Total      int                   `json:"total"`
+	Page       int                   `json:"page"`
+	PageSize   int                   `json:"pageSize"`
+	TotalPages int                   `json:"totalPages"`
+}
+
+// GetOutboxEmails handles GET /api/admin/emails?status=dead&page=1&pageSize=20.
+// Bodies are never returned since they carry sign-in links.
+func (h *AdminHandler) GetOutboxEmails(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	status := c.Query("status")
+
+	if status != "" && !models.IsValidOutboxStatus(status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	emails, total, err := h.store.Outbox.List(c.Request.Context(), store.OutboxFilter{
+		Status: status,
+		Limit:  pageSize,
+		Offset: (page - 1) * pageSize,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch emails", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, OutboxListResponse{
+		Emails:     emails,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: (total + pageSize - 1) / pageSize,
+	})
+}
+
+// RetryOutboxEmail handles POST /api/admin/emails/:id/retry and queues a dead email again
+func (h *AdminHandler) RetryOutboxEmail(c *gin.Context) {
+	id := c.Param("id")
+	admin, _ := CurrentUser(c)
+
+	err := h.store.Outbox.Retry(c.Request.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No failed email with this id"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry email"})
+		return
+	}
+	fmt.Printf("Admin %s queued outbox email %s for another attempt\n", admin.ID, id)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email queued for delivery"})
+}
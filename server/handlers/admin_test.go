@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"viral-cuts-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestUpdateUserRole(t *testing.T) {
+	s := newTestServer(t)
+	adminSession := s.signUp("Ana Souza", "ana@example.com")
+	userSession := s.signUp("Bruno Lima", "bruno@example.com")
+	admin := s.user("ana@example.com")
+	target := s.user("bruno@example.com")
+	if _, err := s.store.Users.UpdateRole(context.Background(), admin.ID, models.RoleAdmin, admin.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only admins may change roles
+	s.expect(s.do("PUT", "/api/admin/users/"+admin.ID+"/role", gin.H{"role": "user"}, userSession), http.StatusForbidden)
+	s.expect(s.do("PUT", "/api/admin/users/"+admin.ID+"/role", gin.H{"role": "user"}, ""), http.StatusUnauthorized)
+
+	body := s.expect(s.do("PUT", "/api/admin/users/"+target.ID+"/role", gin.H{"role": "admin"}, adminSession), http.StatusOK)
+	if body["role"] != models.RoleAdmin {
+		t.Errorf("response = %v", body)
+	}
+	if got := s.user("bruno@example.com").Role; got != models.RoleAdmin {
+		t.Errorf("role = %s, want admin", got)
+	}
+
+	// Setting the same role again records nothing
+	body = s.expect(s.do("PUT", "/api/admin/users/"+target.ID+"/role", gin.H{"role": "admin"}, adminSession), http.StatusOK)
+	if body["message"] != "Role unchanged" {
+		t.Errorf("response = %v", body)
+	}
+	changes, err := s.store.Users.RoleChanges(context.Background(), target.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].OldRole != models.RoleUser || changes[0].NewRole != models.RoleAdmin || changes[0].ChangedBy == nil || *changes[0].ChangedBy != admin.ID {
+		t.Errorf("role changes = %+v", changes)
+	}
+}
+
+func TestUpdateUserRoleRejects(t *testing.T) {
+	s := newTestServer(t)
+	adminSession := s.signUp("Ana Souza", "ana@example.com")
+	admin := s.user("ana@example.com")
+	if _, err := s.store.Users.UpdateRole(context.Background(), admin.ID, models.RoleAdmin, admin.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	s.expect(s.do("PUT", "/api/admin/users/"+admin.ID+"/role", gin.H{"role": "user"}, adminSession), http.StatusBadRequest)
+	s.expect(s.do("PUT", "/api/admin/users/nobody/role", gin.H{"role": "admin"}, adminSession), http.StatusNotFound)
+	s.expect(s.do("PUT", "/api/admin/users/nobody/role", gin.H{"role": "owner"}, adminSession), http.StatusBadRequest)
+	if got := s.user("ana@example.com").Role; got != models.RoleAdmin {
+		t.Errorf("role = %s, want admin", got)
+	}
+}
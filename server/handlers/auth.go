@@ -1,30 +1,36 @@
 package handlers
 
 import (
-	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
+	"viral-cuts-server/mail"
 	"viral-cuts-server/models"
+	"viral-cuts-server/passwords"
+	"viral-cuts-server/store"
+	"viral-cuts-server/tokens"
 	"viral-cuts-server/utils"
 
 	"github.com/gin-gonic/gin"
-	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type AuthHandler struct {
-	db *pgxpool.Pool
+	store  *store.Store
+	policy SessionPolicy
 }
 
-func NewAuthHandler(db *pgxpool.Pool) *AuthHandler {
-	return &AuthHandler{db: db}
+func NewAuthHandler(s *store.Store, policy SessionPolicy) *AuthHandler {
+	return &AuthHandler{store: s, policy: policy}
 }
 
 // SignUpRequest represents the sign-up request body
 type SignUpRequest struct {
 	Name     string `json:"name" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required"`
+	Locale   string `json:"locale"` // language of emails; defaults to the browser's
 }
 
 // SignInRequest represents the sign-in request body
@@ -41,17 +47,19 @@ func (h *AuthHandler) SignUp(c *gin.Context) {
 		return
 	}
 
-	// Check if user already exists
-	var existingEmail string
-	err := h.db.QueryRow(context.Background(),
-		`SELECT email FROM "user" WHERE email = $1`,
-		req.Email,
-	).Scan(&existingEmail)
+	if err := passwords.Check(req.Password, req.Email, req.Name); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
 
+	// Check if user already exists
+	_, err := h.store.Users.GetByEmail(ctx, req.Email)
 	if err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
 		return
-	} else if err != pgx.ErrNoRows {
+	} else if !errors.Is(err, store.ErrNotFound) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
 	}
@@ -63,77 +71,78 @@ func (h *AuthHandler) SignUp(c *gin.Context) {
 		return
 	}
 
-	// Create user
-	userID := utils.GenerateID()
 	now := time.Now()
-
-	_, err = h.db.Exec(context.Background(),
-		`INSERT INTO "user" (id, name, email, email_verified, created_at, updated_at)
-		 VALUES ($1, $2, $3, $4, $5, $6)`,
-		userID, req.Name, req.Email, false, now, now,
-	)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
-		return
+	user := &models.User{
+		ID:            utils.GenerateID(),
+		Name:          req.Name,
+		Email:         req.Email,
+		EmailVerified: false,
+		Role:          models.RoleUser,
+		Locale:        requestLocale(c, req.Locale),
+		CreatedAt:     now,
+		UpdatedAt:     now,
 	}
 
-	// Create account with password
-	accountID := utils.GenerateID()
-	_, err = h.db.Exec(context.Background(),
-		`INSERT INTO "account" (id, account_id, provider_id, user_id, password, created_at, updated_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		accountID, req.Email, "credential", userID, hashedPassword, now, now,
-	)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create account"})
-		return
-	}
+	// User, account, session, verification token and its email are created atomically so a failed step
+	// never leaves a user behind that can't log in but blocks the email
+	var session *models.Session
+	step := "create user"
+	err = h.store.InTx(ctx, func(tx *store.Store) error {
+		if err := tx.Users.Create(ctx, user); err != nil {
+			return err
+		}
 
-	// Create session
-	session, err := h.createSession(userID, c.ClientIP(), c.Request.UserAgent())
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
-		return
-	}
+		// Create account with password
+		step = "create account"
+		err := tx.Accounts.Create(ctx, &models.Account{
+			ID:         utils.GenerateID(),
+			AccountID:  req.Email,
+			ProviderID: store.CredentialProvider,
+			UserID:     user.ID,
+			Password:   &hashedPassword,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		})
+		if err != nil {
+			return err
+		}
 
-	// Set session cookie
-	h.setSessionCookie(c, session.Token)
+		// Create session
+		step = "create session"
+		session, err = h.createSession(c, tx.Sessions, user.ID)
+		if err != nil {
+			return err
+		}
 
-	// Generate and send verification email
-	verificationToken := utils.GenerateID()
-	verificationExpiresAt := now.Add(24 * time.Hour)
+		// Create verification token
+		step = "create verification token"
+		verificationToken, err := tokens.Issue(ctx, tx.Verifications, tokens.EmailVerify, req.Email, tokens.EmailVerifyTTL)
+		if err != nil {
+			return err
+		}
 
-	_, err = h.db.Exec(context.Background(),
-		`INSERT INTO verification (id, identifier, value, expires_at, created_at, updated_at)
-		 VALUES ($1, $2, $3, $4, $5, $6)`,
-		utils.GenerateID(), req.Email, verificationToken, verificationExpiresAt, now, now,
-	)
-	if err != nil {
-		// Don't fail registration if verification email fails
-		// Just log the error
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create verification token"})
+		// Queue verification email (delivered by the outbox worker, don't block registration)
+		step = "queue verification email"
+		msg, err := mail.VerificationEmail(req.Email, user.Locale, verificationToken)
+		if err != nil {
+			return err
+		}
+		return queueEmail(ctx, tx.Outbox, msg)
+	})
+	if errors.Is(err, store.ErrDuplicate) && step == "create user" {
+		// Lost a race with a concurrent sign-up for the same email
+		c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
+		return
+	} else if err != nil {
+		fmt.Printf("Sign-up failed to %s: %v\n", step, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + step})
 		return
 	}
 
-	// Send verification email (async, don't block registration)
-	go func() {
-		err := utils.SendVerificationEmail(req.Email, verificationToken)
-		if err != nil {
-			// Log error but don't fail the request
-			println("Failed to send verification email:", err.Error())
-		}
-	}()
+	// Set session cookie
+	setSessionCookie(c, session)
 
 	// Return user data
-	user := models.User{
-		ID:            userID,
-		Name:          req.Name,
-		Email:         req.Email,
-		EmailVerified: false,
-		CreatedAt:     now,
-		UpdatedAt:     now,
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"user":    user,
 		"session": session,
@@ -148,20 +157,15 @@ func (h *AuthHandler) SignIn(c *gin.Context) {
 		return
 	}
 
+	ctx := c.Request.Context()
+
 	// Get user and password
-	var userID, name, email, hashedPassword string
-	var emailVerified bool
-	var createdAt, updatedAt time.Time
-
-	err := h.db.QueryRow(context.Background(),
-		`SELECT u.id, u.name, u.email, u.email_verified, u.created_at, u.updated_at, a.password
-		 FROM "user" u
-		 JOIN "account" a ON u.id = a.user_id
-		 WHERE u.email = $1 AND a.provider_id = 'credential'`,
-		req.Email,
-	).Scan(&userID, &name, &email, &emailVerified, &createdAt, &updatedAt, &hashedPassword)
-
-	if err == pgx.ErrNoRows {
+	user, err := h.store.Users.GetByEmail(ctx, req.Email)
+	var account *models.Account
+	if err == nil {
+		account, err = h.store.Accounts.GetCredential(ctx, user.ID)
+	}
+	if errors.Is(err, store.ErrNotFound) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 		return
 	} else if err != nil {
@@ -170,154 +174,204 @@ func (h *AuthHandler) SignIn(c *gin.Context) {
 	}
 
 	// Check password
-	if !utils.CheckPassword(hashedPassword, req.Password) {
+	if account.Password == nil || !utils.CheckPassword(*account.Password, req.Password) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 		return
 	}
 
+	// Users with two-factor authentication get a challenge instead of a session
+	enrollment, err := h.store.MFA.Get(ctx, user.ID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if enrollment.Enabled() {
+		h.issueMFAChallenge(c, user.ID)
+		return
+	}
+
 	// Create session
-	session, err := h.createSession(userID, c.ClientIP(), c.Request.UserAgent())
+	session, err := h.createSession(c, h.store.Sessions, user.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
 		return
 	}
 
 	// Set session cookie
-	h.setSessionCookie(c, session.Token)
+	setSessionCookie(c, session)
 
 	// Return user data
-	user := models.User{
-		ID:            userID,
-		Name:          name,
-		Email:         email,
-		EmailVerified: emailVerified,
-		CreatedAt:     createdAt,
-		UpdatedAt:     updatedAt,
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"user":    user,
 		"session": session,
 	})
 }
 
-// GetSession returns the current session
+// GetSession returns the current session (requires RequireSession middleware)
 func (h *AuthHandler) GetSession(c *gin.Context) {
-	// Get session token from cookie
-	token, err := c.Cookie("better-auth.session_token")
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "No session found"})
+	user, _ := CurrentUser(c)
+	session, _ := CurrentSession(c)
+
+	c.JSON(http.StatusOK, gin.H{
+		"user":    user,
+		"session": session,
+	})
+}
+
+// UpdateLocaleRequest represents the email language change request body
+type UpdateLocaleRequest struct {
+	Locale string `json:"locale" binding:"required,oneof=pt-BR en"`
+}
+
+// UpdateLocale handles PUT /api/auth/locale and sets the language of the user's emails
+func (h *AuthHandler) UpdateLocale(c *gin.Context) {
+	var req UpdateLocaleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, _ := CurrentUser(c)
+	if err := h.store.Users.UpdateLocale(c.Request.Context(), user.ID, req.Locale); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update language"})
 		return
 	}
 
-	// Get session and user
-	var sessionID, userID, name, email string
-	var emailVerified bool
-	var expiresAt, createdAt, updatedAt time.Time
-
-	err = h.db.QueryRow(context.Background(),
-		`SELECT s.id, s.expires_at, s.user_id, u.name, u.email, u.email_verified, u.created_at, u.updated_at
-		 FROM "session" s
-		 JOIN "user" u ON s.user_id = u.id
-		 WHERE s.token = $1 AND s.expires_at > NOW()`,
-		token,
-	).Scan(&sessionID, &expiresAt, &userID, &name, &email, &emailVerified, &createdAt, &updatedAt)
-
-	if err == pgx.ErrNoRows {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired session"})
+	c.JSON(http.StatusOK, gin.H{"locale": req.Locale})
+}
+
+// ChangePasswordRequest represents the password change request body
+type ChangePasswordRequest struct {
+	CurrentPassword     string `json:"currentPassword" binding:"required"`
+	NewPassword         string `json:"newPassword" binding:"required"` // checked by the password policy
+	RevokeOtherSessions bool   `json:"revokeOtherSessions"`
+}
+
+// ChangePassword handles POST /api/auth/change-password. Other sessions are signed out when
+// revokeOtherSessions is set; the current one stays.
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, _ := CurrentUser(c)
+	session, _ := CurrentSession(c)
+	ctx := c.Request.Context()
+
+	account, err := h.store.Accounts.GetCredential(ctx, user.ID)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && account.Password == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No password is set, use forgot password to create one"})
 		return
 	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
 	}
+	if !utils.CheckPassword(*account.Password, req.CurrentPassword) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+		return
+	}
 
-	user := models.User{
-		ID:            userID,
-		Name:          name,
-		Email:         email,
-		EmailVerified: emailVerified,
-		CreatedAt:     createdAt,
-		UpdatedAt:     updatedAt,
+	if err := passwords.Check(req.NewPassword, user.Email, user.Name); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.NewPassword == req.CurrentPassword {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "New password must differ from the current one"})
+		return
 	}
 
-	session := models.Session{
-		ID:        sessionID,
-		Token:     token,
-		ExpiresAt: expiresAt,
-		UserID:    userID,
+	hashedPassword, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"user":    user,
-		"session": session,
+	revoked := 0
+	err = h.store.InTx(ctx, func(tx *store.Store) error {
+		if err := tx.Accounts.UpdatePassword(ctx, user.ID, hashedPassword); err != nil {
+			return err
+		}
+		// Reset links requested before the change shouldn't override it
+		if err := tokens.Revoke(ctx, tx.Verifications, tokens.PasswordReset, user.Email); err != nil {
+			return err
+		}
+		if req.RevokeOtherSessions {
+			var err error
+			revoked, err = tx.Sessions.DeleteOthers(ctx, user.ID, session.ID)
+			return err
+		}
+		return nil
 	})
+	if err != nil {
+		fmt.Printf("Error changing password: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully", "revokedSessions": revoked})
 }
 
 // SignOut handles user logout
 func (h *AuthHandler) SignOut(c *gin.Context) {
 	// Get session token from cookie
-	token, err := c.Cookie("better-auth.session_token")
+	token, err := sessionToken(c)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{"message": "Already signed out"})
 		return
 	}
 
 	// Delete session from database
-	_, err = h.db.Exec(context.Background(),
-		`DELETE FROM "session" WHERE token = $1`,
-		token,
-	)
-	if err != nil {
+	if err := h.store.Sessions.DeleteByToken(c.Request.Context(), token); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign out"})
 		return
 	}
 
 	// Clear cookie
-	c.SetCookie("better-auth.session_token", "", -1, "/", "", false, true)
+	clearSessionCookie(c)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Signed out successfully"})
 }
 
 // Helper functions
 
-func (h *AuthHandler) createSession(userID, ipAddress, userAgent string) (*models.Session, error) {
-	sessionID := utils.GenerateID()
+func (h *AuthHandler) createSession(c *gin.Context, sessions store.SessionStore, userID string) (*models.Session, error) {
 	token, err := utils.GenerateSessionToken()
 	if err != nil {
 		return nil, err
 	}
 
 	now := time.Now()
-	expiresAt := now.Add(30 * 24 * time.Hour) // 30 days
-
-	_, err = h.db.Exec(context.Background(),
-		`INSERT INTO "session" (id, token, expires_at, user_id, ip_address, user_agent, created_at, updated_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
-		sessionID, token, expiresAt, userID, ipAddress, userAgent, now, now,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	return &models.Session{
-		ID:        sessionID,
+	ipAddress := c.ClientIP()
+	userAgent := c.Request.UserAgent()
+	session := &models.Session{
+		ID:        utils.GenerateID(),
 		Token:     token,
-		ExpiresAt: expiresAt,
+		ExpiresAt: h.policy.expiry(now, now),
 		UserID:    userID,
+		IPAddress: &ipAddress,
+		UserAgent: &userAgent,
 		CreatedAt: now,
 		UpdatedAt: now,
-	}, nil
+	}
+	if err := sessions.Create(c.Request.Context(), session); err != nil {
+		return nil, err
+	}
+	return session, nil
 }
 
-func (h *AuthHandler) setSessionCookie(c *gin.Context, token string) {
-	// Set HTTP-only cookie (same name as Better Auth for compatibility)
-	c.SetCookie(
-		"better-auth.session_token", // name
-		token,                       // value
-		30*24*60*60,                 // maxAge (30 days in seconds)
-		"/",                         // path
-		"",                          // domain (empty = current domain)
-		false,                       // secure (set to true in production with HTTPS)
-		true,                        // httpOnly
-	)
+// requestLocale picks the email language of a new user: the requested one if supported,
+// else the first supported language of the Accept-Language header, else the default
+func requestLocale(c *gin.Context, requested string) string {
+	if locale := models.MatchLocale(requested); locale != "" {
+		return locale
+	}
+	for _, tag := range strings.Split(c.GetHeader("Accept-Language"), ",") {
+		tag, _, _ = strings.Cut(tag, ";")
+		if locale := models.MatchLocale(tag); locale != "" {
+			return locale
+		}
+	}
+	return models.DefaultLocale
 }
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+	"viral-cuts-server/mail"
+	"viral-cuts-server/models"
+	"viral-cuts-server/ratelimit"
+	"viral-cuts-server/storage"
+	"viral-cuts-server/store"
+	"viral-cuts-server/tokens"
+	"viral-cuts-server/utils"
+	"viral-cuts-server/worker"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	testPassword      = "correct horse battery staple"
+	testStorageOrigin = "https://project.supabase.co"
+)
+
+// testServer routes requests like main.go against the in-memory store. Queued emails are delivered
+// to mailer by deliverEmails.
+type testServer struct {
+	t      *testing.T
+	store  *store.Store
+	router *gin.Engine
+	mailer *mail.Recorder
+	outbox *worker.EmailOutbox
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	stores := store.NewMemory()
+	policy := DefaultSessionPolicy()
+	authMiddleware := NewAuthMiddleware(stores.Sessions, policy)
+	rateLimit := NewRateLimitMiddleware(ratelimit.New(ratelimit.NewMemoryBackend()))
+	authHandler := NewAuthHandler(stores, policy)
+	passwordResetHandler := NewPasswordResetHandler(stores)
+	emailVerificationHandler := NewEmailVerificationHandler(stores)
+	adminHandler := NewAdminHandler(stores)
+	mfaHandler := NewMFAHandler(stores)
+	queueHandler := NewQueueHandler(stores, &storage.Source{Origin: testStorageOrigin})
+
+	r := gin.New()
+	r.POST("/api/auth/sign-up", authHandler.SignUp)
+	r.POST("/api/auth/sign-in",
+		rateLimit.Limit("sign-in", ratelimit.Rule{Limit: 30, Window: 10 * time.Minute}, ratelimit.Rule{Limit: 10, Window: 10 * time.Minute}),
+		rateLimit.Lockout("sign-in"),
+		authHandler.SignIn)
+	r.GET("/api/auth/session", authMiddleware.RequireSession(), authHandler.GetSession)
+	r.POST("/api/auth/forgot-password", passwordResetHandler.ForgotPassword)
+	r.POST("/api/auth/reset-password", passwordResetHandler.ResetPassword)
+	r.GET("/api/auth/verify-email", emailVerificationHandler.VerifyEmail)
+	r.POST("/api/auth/resend-verification", emailVerificationHandler.ResendVerification)
+
+	mfaRoutes := r.Group("/api/auth/mfa", authMiddleware.RequireSession())
+	mfaRoutes.POST("/recovery-codes",
+		rateLimit.LimitUser("mfa-recovery-codes", ratelimit.Rule{Limit: 30, Window: 10 * time.Minute}, ratelimit.Rule{Limit: 10, Window: 10 * time.Minute}),
+		mfaHandler.RegenerateRecoveryCodes)
+	mfaRoutes.POST("/disable",
+		rateLimit.LimitUser("mfa-disable", ratelimit.Rule{Limit: 30, Window: 10 * time.Minute}, ratelimit.Rule{Limit: 10, Window: 10 * time.Minute}),
+		mfaHandler.Disable)
+
+	admin := r.Group("/api/admin", authMiddleware.RequireSession(), authMiddleware.RequireRole(models.RoleAdmin))
+	admin.PUT("/users/:id/role", adminHandler.UpdateUserRole)
+	admin.GET("/role-changes", adminHandler.GetRoleChanges)
+
+	queue := r.Group("/api/queue", authMiddleware.RequireSession())
+	queue.GET("", queueHandler.ListQueue)
+	queue.POST("", queueHandler.CreateQueueItem)
+	queue.GET("/:id", queueHandler.GetQueueItem)
+	queue.PUT("/:id", queueHandler.UpdateQueueItem)
+	queue.PUT("/:id/status", queueHandler.UpdateQueueStatus)
+	queue.DELETE("/:id", queueHandler.DeleteQueueItem)
+
+	mailer := mail.NewRecorder()
+	return &testServer{
+		t:      t,
+		store:  stores,
+		router: r,
+		mailer: mailer,
+		outbox: worker.NewEmailOutbox(stores.Outbox, mailer, worker.DefaultOutboxConfig()),
+	}
+}
+
+// do sends a JSON request, with the session cookie when session isn't empty
+func (s *testServer) do(method, path string, body any, session string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			s.t.Fatal(err)
+		}
+	}
+
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	if session != "" {
+		req.AddCookie(&http.Cookie{Name: SessionCookieName, Value: session})
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// expect fails the test unless rec has the status, and decodes its JSON body
+func (s *testServer) expect(rec *httptest.ResponseRecorder, status int) map[string]any {
+	s.t.Helper()
+	if rec.Code != status {
+		s.t.Fatalf("status = %d, want %d: %s", rec.Code, status, rec.Body.String())
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		s.t.Fatalf("invalid JSON body %q: %v", rec.Body.String(), err)
+	}
+	return body
+}
+
+// sessionCookieValue returns the session token set by a response
+func sessionCookieValue(rec *httptest.ResponseRecorder) string {
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == SessionCookieName && cookie.MaxAge >= 0 {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// signUp registers a user and returns their session token
+func (s *testServer) signUp(name, email string) string {
+	s.t.Helper()
+	rec := s.do("POST", "/api/auth/sign-up", gin.H{"name": name, "email": email, "password": testPassword}, "")
+	s.expect(rec, http.StatusOK)
+	session := sessionCookieValue(rec)
+	if session == "" {
+		s.t.Fatal("sign-up set no session cookie")
+	}
+	return session
+}
+
+func (s *testServer) user(email string) *models.User {
+	s.t.Helper()
+	user, err := s.store.Users.GetByEmail(context.Background(), email)
+	if err != nil {
+		s.t.Fatalf("GetByEmail(%s): %v", email, err)
+	}
+	return user
+}
+
+// seedToken issues a token for email, as the emailed links would carry
+func (s *testServer) seedToken(purpose tokens.Purpose, email string, ttl time.Duration) string {
+	s.t.Helper()
+	token, err := tokens.Issue(context.Background(), s.store.Verifications, purpose, email, ttl)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return token
+}
+
+var linkToken = regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+// deliverEmails runs the outbox worker and returns the token in the link of the last email to recipient
+func (s *testServer) deliverEmails(recipient string) string {
+	s.t.Helper()
+	if err := s.outbox.RunOnce(context.Background()); err != nil {
+		s.t.Fatal(err)
+	}
+	sent := s.mailer.SentTo(recipient)
+	if len(sent) == 0 {
+		s.t.Fatalf("no email sent to %s", recipient)
+	}
+	match := linkToken.FindStringSubmatch(sent[len(sent)-1].Text)
+	if match == nil {
+		s.t.Fatalf("no token link in %q", sent[len(sent)-1].Text)
+	}
+	return match[1]
+}
+
+func TestSignUpAndSignIn(t *testing.T) {
+	s := newTestServer(t)
+
+	session := s.signUp("Ana Souza", "ana@example.com")
+	body := s.expect(s.do("GET", "/api/auth/session", nil, session), http.StatusOK)
+	if user := body["user"].(map[string]any); user["email"] != "ana@example.com" || user["emailVerified"] != false {
+		t.Errorf("session user = %v", user)
+	}
+
+	rec := s.do("POST", "/api/auth/sign-in", gin.H{"email": "ana@example.com", "password": testPassword}, "")
+	s.expect(rec, http.StatusOK)
+	if sessionCookieValue(rec) == "" || sessionCookieValue(rec) == session {
+		t.Error("sign-in did not issue a new session cookie")
+	}
+
+	rec = s.do("POST", "/api/auth/sign-in", gin.H{"email": "ana@example.com", "password": "wrong password entirely"}, "")
+	s.expect(rec, http.StatusUnauthorized)
+	if sessionCookieValue(rec) != "" {
+		t.Error("failed sign-in set a session cookie")
+	}
+	s.expect(s.do("POST", "/api/auth/sign-in", gin.H{"email": "bruno@example.com", "password": testPassword}, ""), http.StatusUnauthorized)
+	s.expect(s.do("GET", "/api/auth/session", nil, "not-a-session"), http.StatusUnauthorized)
+}
+
+func TestSignUpRejects(t *testing.T) {
+	s := newTestServer(t)
+	s.signUp("Ana Souza", "ana@example.com")
+
+	cases := []struct {
+		name   string
+		body   gin.H
+		status int
+	}{
+		{"existing email", gin.H{"name": "Ana", "email": "ana@example.com", "password": testPassword}, http.StatusConflict},
+		{"short password", gin.H{"name": "Bruno", "email": "bruno@example.com", "password": "short"}, http.StatusBadRequest},
+		{"password with the name", gin.H{"name": "Bruno", "email": "bruno@example.com", "password": "bruno bruno bruno"}, http.StatusBadRequest},
+		{"invalid email", gin.H{"name": "Bruno", "email": "bruno", "password": testPassword}, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		if rec := s.do("POST", "/api/auth/sign-up", tc.body, ""); rec.Code != tc.status {
+			t.Errorf("%s: status = %d, want %d: %s", tc.name, rec.Code, tc.status, rec.Body.String())
+		}
+	}
+	if _, err := s.store.Users.GetByEmail(context.Background(), "bruno@example.com"); err == nil {
+		t.Error("a rejected sign-up created the user")
+	}
+}
+
+func TestSignInLockout(t *testing.T) {
+	s := newTestServer(t)
+	s.signUp("Ana Souza", "ana@example.com")
+	wrong := gin.H{"email": "ana@example.com", "password": "wrong password entirely"}
+	right := gin.H{"email": "ana@example.com", "password": testPassword}
+
+	// A successful sign-in clears earlier failures, so eight in total don't lock
+	for i := 0; i < 4; i++ {
+		s.expect(s.do("POST", "/api/auth/sign-in", wrong, ""), http.StatusUnauthorized)
+	}
+	s.expect(s.do("POST", "/api/auth/sign-in", right, ""), http.StatusOK)
+	for i := 0; i < 4; i++ {
+		s.expect(s.do("POST", "/api/auth/sign-in", wrong, ""), http.StatusUnauthorized)
+	}
+	s.expect(s.do("POST", "/api/auth/sign-in", right, ""), http.StatusOK)
+
+	// Five failures in a row lock the email, even with the right password
+	s.signUp("Bruno Lima", "bruno@example.com")
+	wrong["email"], right["email"] = "bruno@example.com", "bruno@example.com"
+	for i := 0; i < 5; i++ {
+		s.expect(s.do("POST", "/api/auth/sign-in", wrong, ""), http.StatusUnauthorized)
+	}
+	rec := s.do("POST", "/api/auth/sign-in", right, "")
+	s.expect(rec, http.StatusTooManyRequests)
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("no Retry-After header")
+	}
+}
+
+func TestSignInLockoutNotClearedByMFAChallenge(t *testing.T) {
+	s := newTestServer(t)
+	s.signUp("Ana Souza", "ana@example.com")
+	ctx := context.Background()
+	user := s.user("ana@example.com")
+	if err := s.store.MFA.SetPending(ctx, &models.UserMFA{ID: utils.GenerateID(), UserID: user.ID, Secret: "JBSWY3DPEHPK3PXP"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.MFA.Enable(ctx, user.ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	wrong := gin.H{"email": "ana@example.com", "password": "wrong password entirely"}
+
+	for i := 0; i < 4; i++ {
+		s.expect(s.do("POST", "/api/auth/sign-in", wrong, ""), http.StatusUnauthorized)
+	}
+	// The password step alone issues no session and must not reset the failures
+	rec := s.do("POST", "/api/auth/sign-in", gin.H{"email": "ana@example.com", "password": testPassword}, "")
+	if body := s.expect(rec, http.StatusOK); body["status"] != "mfa_pending" || sessionCookieValue(rec) != "" {
+		t.Fatalf("sign-in = %v, want an mfa_pending challenge without a session", body)
+	}
+	s.expect(s.do("POST", "/api/auth/sign-in", wrong, ""), http.StatusUnauthorized)
+	s.expect(s.do("POST", "/api/auth/sign-in", wrong, ""), http.StatusTooManyRequests)
+}
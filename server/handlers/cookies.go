@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"viral-cuts-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// hostCookiePrefix makes browsers reject the cookie unless it is Secure, has Path=/ and no Domain,
+// so a sibling subdomain can't set or shadow it
+const hostCookiePrefix = "__Host-"
+
+// CookieConfig holds the attributes of the session cookie
+type CookieConfig struct {
+	Name     string
+	Secure   bool
+	SameSite http.SameSite
+	Domain   string
+}
+
+// sessionCookie is set once at startup with SetCookieConfig
+var sessionCookie = CookieConfig{Name: SessionCookieName, SameSite: http.SameSiteLaxMode}
+
+// SetCookieConfig replaces the session cookie attributes
+func SetCookieConfig(config CookieConfig) {
+	sessionCookie = config
+}
+
+// CookieConfigFromEnv reads COOKIE_SECURE, COOKIE_SAMESITE (lax, strict or none), COOKIE_DOMAIN and
+// COOKIE_HOST_PREFIX. Cookies are Secure by default when GO_ENV=production.
+// A frontend on another site than the API (vercel.app and fly.dev) needs COOKIE_SAMESITE=none.
+func CookieConfigFromEnv() (CookieConfig, error) {
+	config := CookieConfig{
+		Name:     SessionCookieName,
+		Secure:   os.Getenv("GO_ENV") == "production",
+		SameSite: http.SameSiteLaxMode,
+		Domain:   os.Getenv("COOKIE_DOMAIN"),
+	}
+
+	if value := os.Getenv("COOKIE_SECURE"); value != "" {
+		config.Secure = value == "true"
+	}
+
+	switch strings.ToLower(os.Getenv("COOKIE_SAMESITE")) {
+	case "", "lax":
+	case "strict":
+		config.SameSite = http.SameSiteStrictMode
+	case "none":
+		config.SameSite = http.SameSiteNoneMode
+		if !config.Secure {
+			return config, errors.New("COOKIE_SAMESITE=none requires secure cookies")
+		}
+	default:
+		return config, fmt.Errorf("invalid COOKIE_SAMESITE %q", os.Getenv("COOKIE_SAMESITE"))
+	}
+
+	if os.Getenv("COOKIE_HOST_PREFIX") == "true" {
+		if !config.Secure || config.Domain != "" {
+			return config, errors.New("COOKIE_HOST_PREFIX requires secure cookies and no COOKIE_DOMAIN")
+		}
+		config.Name = hostCookiePrefix + config.Name
+	}
+	return config, nil
+}
+
+// sessionToken returns the token from the session cookie
+func sessionToken(c *gin.Context) (string, error) {
+	return c.Cookie(sessionCookie.Name)
+}
+
+// sessionIssuedKey is set on the context once a response carries a session cookie
+const sessionIssuedKey = "session.issued"
+
+// setSessionCookie sets the session cookie to expire with the session
+func setSessionCookie(c *gin.Context, session *models.Session) {
+	c.Set(sessionIssuedKey, true)
+	writeSessionCookie(c, session.Token, int(time.Until(session.ExpiresAt).Seconds()))
+}
+
+// clearSessionCookie removes the session cookie. The attributes must match the ones it was set with.
+func clearSessionCookie(c *gin.Context) {
+	writeSessionCookie(c, "", -1)
+}
+
+func writeSessionCookie(c *gin.Context, value string, maxAge int) {
+	writeCookie(c, sessionCookie.Name, value, maxAge)
+}
+
+// mfaChallengeCookieName carries the challenge of a Google sign-in to POST /api/auth/mfa/verify,
+// so it never appears in a URL (browser history, Referer headers, proxy logs)
+const mfaChallengeCookieName = "mfa_challenge"
+
+// setMFAChallengeCookie sets the challenge cookie to expire with the challenge
+func setMFAChallengeCookie(c *gin.Context, challenge string, ttl time.Duration) {
+	writeCookie(c, challengeCookieName(), challenge, int(ttl.Seconds()))
+}
+
+func clearMFAChallengeCookie(c *gin.Context) {
+	writeCookie(c, challengeCookieName(), "", -1)
+}
+
+// mfaChallengeFromCookie returns the challenge set by setMFAChallengeCookie, or an empty string
+func mfaChallengeFromCookie(c *gin.Context) string {
+	challenge, _ := c.Cookie(challengeCookieName())
+	return challenge
+}
+
+// challengeCookieName follows the session cookie's __Host- prefix
+func challengeCookieName() string {
+	if strings.HasPrefix(sessionCookie.Name, hostCookiePrefix) {
+		return hostCookiePrefix + mfaChallengeCookieName
+	}
+	return mfaChallengeCookieName
+}
+
+// writeCookie sets an HttpOnly cookie with the session cookie attributes
+func writeCookie(c *gin.Context, name, value string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		MaxAge:   maxAge,
+		Path:     "/",
+		Domain:   sessionCookie.Domain,
+		Secure:   sessionCookie.Secure,
+		HttpOnly: true,
+		SameSite: sessionCookie.SameSite,
+	})
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireTrustedOrigin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequireTrustedOrigin([]string{"https://app.example.com"}))
+	r.POST("/api/thing", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/api/thing", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	cases := []struct {
+		name    string
+		method  string
+		host    string
+		tls     bool
+		headers map[string]string
+		want    int
+	}{
+		{"safe method", "GET", "api.example.com", false, map[string]string{"Origin": "https://evil.example.com"}, http.StatusOK},
+		{"allowed origin", "POST", "api.example.com", false, map[string]string{"Origin": "https://app.example.com"}, http.StatusOK},
+		{"other origin", "POST", "api.example.com", false, map[string]string{"Origin": "https://evil.example.com"}, http.StatusForbidden},
+		{"same origin over TLS", "POST", "api.example.com", true, map[string]string{"Origin": "https://api.example.com"}, http.StatusOK},
+		{"same origin with default port", "POST", "api.example.com:443", true, map[string]string{"Origin": "https://API.example.com"}, http.StatusOK},
+		{"same origin behind proxy", "POST", "api.example.com", false, map[string]string{"Origin": "https://api.example.com", "X-Forwarded-Proto": "https"}, http.StatusOK},
+		{"plain http page on a TLS host", "POST", "api.example.com", true, map[string]string{"Origin": "http://api.example.com"}, http.StatusForbidden},
+		{"https page on a plain http host", "POST", "localhost:8080", false, map[string]string{"Origin": "https://localhost:8080"}, http.StatusForbidden},
+		{"other port", "POST", "localhost:8080", false, map[string]string{"Origin": "http://localhost:3001"}, http.StatusForbidden},
+		{"same host and port", "POST", "localhost:8080", false, map[string]string{"Origin": "http://localhost:8080"}, http.StatusOK},
+		{"referer fallback", "POST", "api.example.com", false, map[string]string{"Referer": "https://evil.example.com/page"}, http.StatusForbidden},
+		{"non-browser client", "POST", "api.example.com", false, nil, http.StatusOK},
+		{"cross-site without origin", "POST", "api.example.com", false, map[string]string{"Sec-Fetch-Site": "cross-site"}, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, "/api/thing", nil)
+		req.Host = tc.host
+		if tc.tls {
+			req.TLS = &tls.ConnectionState{}
+		}
+		for name, value := range tc.headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s: status = %d, want %d", tc.name, rec.Code, tc.want)
+		}
+	}
+}
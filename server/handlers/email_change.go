@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"viral-cuts-server/mail"
+	"viral-cuts-server/models"
+	"viral-cuts-server/store"
+	"viral-cuts-server/tokens"
+	"viral-cuts-server/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EmailChangeHandler struct {
+	store *store.Store
+}
+
+func NewEmailChangeHandler(s *store.Store) *EmailChangeHandler {
+	return &EmailChangeHandler{store: s}
+}
+
+// ChangeEmailRequest represents the email change request body
+type ChangeEmailRequest struct {
+	NewEmail string `json:"newEmail" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+// EmailChangeTokenRequest represents the body of the confirm and cancel endpoints
+type EmailChangeTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// RequestChange handles POST /api/auth/change-email. The new address gets a confirmation link and
+// the current one a notice with a cancel link; nothing changes until the link is confirmed.
+func (h *EmailChangeHandler) RequestChange(c *gin.Context) {
+	var req ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, _ := CurrentUser(c)
+	ctx := c.Request.Context()
+	newEmail := strings.TrimSpace(req.NewEmail)
+
+	if strings.EqualFold(newEmail, user.Email) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This is already your email"})
+		return
+	}
+
+	// A stolen session alone must not be enough to take over the account
+	account, err := h.store.Accounts.GetCredential(ctx, user.ID)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && account.Password == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Set a password before changing your email"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if !utils.CheckPassword(*account.Password, req.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+		return
+	}
+
+	if _, err := h.store.Users.GetByEmail(ctx, newEmail); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email is already in use"})
+		return
+	} else if !errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	// The pending change replaces any earlier one, whose links stop working
+	now := time.Now()
+	change := &models.EmailChange{
+		ID:        utils.GenerateID(),
+		UserID:    user.ID,
+		NewEmail:  newEmail,
+		ExpiresAt: now.Add(tokens.EmailChangeTTL),
+		CreatedAt: now,
+	}
+	err = h.store.InTx(ctx, func(tx *store.Store) error {
+		if err := tx.EmailChanges.Set(ctx, change); err != nil {
+			return err
+		}
+		if err := revokeEmailChangeTokens(ctx, tx.Verifications, user.ID); err != nil {
+			return err
+		}
+
+		confirmToken, err := tokens.Issue(ctx, tx.Verifications, tokens.EmailChange, user.ID, tokens.EmailChangeTTL)
+		if err != nil {
+			return err
+		}
+		cancelToken, err := tokens.Issue(ctx, tx.Verifications, tokens.EmailChangeCancel, user.ID, tokens.EmailChangeTTL)
+		if err != nil {
+			return err
+		}
+
+		confirm, err := mail.EmailChangeConfirmEmail(newEmail, user.Locale, confirmToken)
+		if err != nil {
+			return err
+		}
+		if err := queueEmail(ctx, tx.Outbox, confirm); err != nil {
+			return err
+		}
+		notice, err := mail.EmailChangeNoticeEmail(user.Email, user.Locale, newEmail, cancelToken)
+		if err != nil {
+			return err
+		}
+		return queueEmail(ctx, tx.Outbox, notice)
+	})
+	if err != nil {
+		fmt.Printf("Error requesting email change: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request email change"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Confirmation link sent to the new email",
+		"newEmail":  newEmail,
+		"expiresAt": change.ExpiresAt,
+	})
+}
+
+// ConfirmChange handles POST /api/auth/change-email/confirm and swaps the email of the user and
+// of their email/password account
+func (h *EmailChangeHandler) ConfirmChange(c *gin.Context) {
+	var req EmailChangeTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	var newEmail string
+	err := h.store.InTx(ctx, func(tx *store.Store) error {
+		verification, err := tokens.Consume(ctx, tx.Verifications, tokens.EmailChange, req.Token)
+		if err != nil {
+			return err
+		}
+		userID := verification.Identifier
+
+		change, err := tx.EmailChanges.Get(ctx, userID)
+		if errors.Is(err, store.ErrNotFound) {
+			return tokens.ErrInvalid
+		} else if err != nil {
+			return err
+		}
+		user, err := tx.Users.GetByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Users.UpdateEmail(ctx, userID, change.NewEmail); err != nil {
+			return err
+		}
+		if err := tx.Accounts.UpdateCredentialEmail(ctx, userID, change.NewEmail); err != nil {
+			return err
+		}
+		if err := tx.EmailChanges.Delete(ctx, userID); err != nil {
+			return err
+		}
+		if err := revokeEmailChangeTokens(ctx, tx.Verifications, userID); err != nil {
+			return err
+		}
+
+		// Links sent to the old address must not act on the account anymore
+		if err := tokens.Revoke(ctx, tx.Verifications, tokens.EmailVerify, user.Email); err != nil {
+			return err
+		}
+		if err := tokens.Revoke(ctx, tx.Verifications, tokens.PasswordReset, user.Email); err != nil {
+			return err
+		}
+		newEmail = change.NewEmail
+		return nil
+	})
+	if errors.Is(err, tokens.ErrInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired confirmation token"})
+		return
+	} else if errors.Is(err, store.ErrDuplicate) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email is already in use"})
+		return
+	} else if err != nil {
+		fmt.Printf("Error confirming email change: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email changed successfully", "email": newEmail})
+}
+
+// CancelChange handles POST /api/auth/change-email/cancel with the token sent to the old address
+func (h *EmailChangeHandler) CancelChange(c *gin.Context) {
+	var req EmailChangeTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	err := h.store.InTx(ctx, func(tx *store.Store) error {
+		verification, err := tokens.Consume(ctx, tx.Verifications, tokens.EmailChangeCancel, req.Token)
+		if err != nil {
+			return err
+		}
+		if err := tx.EmailChanges.Delete(ctx, verification.Identifier); err != nil {
+			return err
+		}
+		return revokeEmailChangeTokens(ctx, tx.Verifications, verification.Identifier)
+	})
+	if errors.Is(err, tokens.ErrInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired cancel token"})
+		return
+	} else if err != nil {
+		fmt.Printf("Error cancelling email change: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel email change"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email change cancelled"})
+}
+
+// revokeEmailChangeTokens deletes the outstanding confirm and cancel links of a user
+func revokeEmailChangeTokens(ctx context.Context, verifications store.VerificationStore, userID string) error {
+	if err := tokens.Revoke(ctx, verifications, tokens.EmailChange, userID); err != nil {
+		return err
+	}
+	return tokens.Revoke(ctx, verifications, tokens.EmailChangeCancel, userID)
+}
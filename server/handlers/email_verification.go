@@ -1,25 +1,22 @@
 package handlers
 
 import (
-	"context"
+	"errors"
 	"fmt"
 	"net/http"
-	"time"
-	"viral-cuts-server/models"
-	"viral-cuts-server/utils"
+	"viral-cuts-server/mail"
+	"viral-cuts-server/store"
+	"viral-cuts-server/tokens"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type EmailVerificationHandler struct {
-	db *pgxpool.Pool
+	store *store.Store
 }
 
-func NewEmailVerificationHandler(db *pgxpool.Pool) *EmailVerificationHandler {
-	return &EmailVerificationHandler{db: db}
+func NewEmailVerificationHandler(s *store.Store) *EmailVerificationHandler {
+	return &EmailVerificationHandler{store: s}
 }
 
 // VerifyEmail handles GET /api/auth/verify-email?token=xxx
@@ -30,17 +27,11 @@ func (h *EmailVerificationHandler) VerifyEmail(c *gin.Context) {
 		return
 	}
 
-	ctx := context.Background()
+	ctx := c.Request.Context()
 
-	// Find verification record
-	var verification models.Verification
-	err := h.db.QueryRow(ctx, `
-		SELECT id, identifier, value, expires_at
-		FROM verification
-		WHERE value = $1 AND expires_at > NOW()
-	`, token).Scan(&verification.ID, &verification.Identifier, &verification.Value, &verification.ExpiresAt)
-
-	if err == pgx.ErrNoRows {
+	// Use up the token; reset and email-change tokens are rejected
+	verification, err := tokens.Consume(ctx, h.store.Verifications, tokens.EmailVerify, token)
+	if errors.Is(err, tokens.ErrInvalid) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
 		return
 	}
@@ -51,28 +42,13 @@ func (h *EmailVerificationHandler) VerifyEmail(c *gin.Context) {
 	}
 
 	// Update user's email_verified status
-	_, err = h.db.Exec(ctx, `
-		UPDATE "user"
-		SET email_verified = true
-		WHERE email = $1
-	`, verification.Identifier)
-
+	err = h.store.Users.MarkEmailVerified(ctx, verification.Identifier)
 	if err != nil {
 		fmt.Printf("Error updating user: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
 		return
 	}
 
-	// Delete used verification token
-	_, err = h.db.Exec(ctx, `
-		DELETE FROM verification WHERE id = $1
-	`, verification.ID)
-
-	if err != nil {
-		fmt.Printf("Error deleting verification token: %v\n", err)
-		// Don't fail the request, email is already verified
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Email verified successfully",
 		"success": true,
@@ -90,17 +66,11 @@ func (h *EmailVerificationHandler) ResendVerification(c *gin.Context) {
 		return
 	}
 
-	ctx := context.Background()
+	ctx := c.Request.Context()
 
 	// Check if user exists
-	var user models.User
-	err := h.db.QueryRow(ctx, `
-		SELECT id, email, email_verified
-		FROM "user"
-		WHERE email = $1
-	`, req.Email).Scan(&user.ID, &user.Email, &user.EmailVerified)
-
-	if err == pgx.ErrNoRows {
+	user, err := h.store.Users.GetByEmail(ctx, req.Email)
+	if errors.Is(err, store.ErrNotFound) {
 		// Don't reveal if email exists or not for security
 		c.JSON(http.StatusOK, gin.H{
 			"message": "If the email exists, a verification link has been sent",
@@ -119,39 +89,27 @@ func (h *EmailVerificationHandler) ResendVerification(c *gin.Context) {
 		return
 	}
 
-	// Delete any existing verification tokens for this email
-	_, err = h.db.Exec(ctx, `
-		DELETE FROM verification WHERE identifier = $1
-	`, user.Email)
-	if err != nil {
-		fmt.Printf("Error deleting old tokens: %v\n", err)
-		// Continue anyway
-	}
-
-	// Generate new verification token
-	verificationToken := uuid.New().String()
-	expiresAt := time.Now().Add(24 * time.Hour)
-
-	// Store verification token
-	_, err = h.db.Exec(ctx, `
-		INSERT INTO verification (id, identifier, value, expires_at, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, NOW(), NOW())
-	`, uuid.New().String(), user.Email, verificationToken, expiresAt)
-
+	// Replace any existing verification tokens for this email and queue the new link
+	err = h.store.InTx(ctx, func(tx *store.Store) error {
+		if err := tokens.Revoke(ctx, tx.Verifications, tokens.EmailVerify, user.Email); err != nil {
+			return err
+		}
+		verificationToken, err := tokens.Issue(ctx, tx.Verifications, tokens.EmailVerify, user.Email, tokens.EmailVerifyTTL)
+		if err != nil {
+			return err
+		}
+		msg, err := mail.VerificationEmail(user.Email, user.Locale, verificationToken)
+		if err != nil {
+			return err
+		}
+		return queueEmail(ctx, tx.Outbox, msg)
+	})
 	if err != nil {
 		fmt.Printf("Error creating verification token: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create verification token"})
 		return
 	}
 
-	// Send verification email
-	err = utils.SendVerificationEmail(user.Email, verificationToken)
-	if err != nil {
-		fmt.Printf("Error sending verification email: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
-		return
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Verification email sent successfully",
 	})
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+	"viral-cuts-server/tokens"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestVerifyEmail(t *testing.T) {
+	s := newTestServer(t)
+	s.signUp("Ana Souza", "ana@example.com")
+	token := s.deliverEmails("ana@example.com")
+
+	s.expect(s.do("GET", "/api/auth/verify-email?token="+token, nil, ""), http.StatusOK)
+	if !s.user("ana@example.com").EmailVerified {
+		t.Error("email was not marked verified")
+	}
+
+	// The token is used up, and a verified email gets no new link
+	s.expect(s.do("GET", "/api/auth/verify-email?token="+token, nil, ""), http.StatusBadRequest)
+	s.expect(s.do("POST", "/api/auth/resend-verification", gin.H{"email": "ana@example.com"}, ""), http.StatusBadRequest)
+}
+
+func TestVerifyEmailRejectsOtherTokens(t *testing.T) {
+	s := newTestServer(t)
+	s.signUp("Ana Souza", "ana@example.com")
+	expired := s.seedToken(tokens.EmailVerify, "ana@example.com", -time.Minute)
+	resetToken := s.seedToken(tokens.PasswordReset, "ana@example.com", time.Hour)
+
+	s.expect(s.do("GET", "/api/auth/verify-email", nil, ""), http.StatusBadRequest)
+	s.expect(s.do("GET", "/api/auth/verify-email?token="+expired, nil, ""), http.StatusBadRequest)
+	s.expect(s.do("GET", "/api/auth/verify-email?token=not-a-token", nil, ""), http.StatusBadRequest)
+	s.expect(s.do("GET", "/api/auth/verify-email?token="+resetToken, nil, ""), http.StatusBadRequest)
+	if s.user("ana@example.com").EmailVerified {
+		t.Error("a reset token verified the email")
+	}
+}
+
+func TestResendVerificationReplacesToken(t *testing.T) {
+	s := newTestServer(t)
+	s.signUp("Ana Souza", "ana@example.com")
+	first := s.deliverEmails("ana@example.com")
+
+	s.expect(s.do("POST", "/api/auth/resend-verification", gin.H{"email": "ana@example.com"}, ""), http.StatusOK)
+	second := s.deliverEmails("ana@example.com")
+	if second == first {
+		t.Fatal("resend sent the same token")
+	}
+
+	s.expect(s.do("GET", "/api/auth/verify-email?token="+first, nil, ""), http.StatusBadRequest)
+	s.expect(s.do("GET", "/api/auth/verify-email?token="+second, nil, ""), http.StatusOK)
+}
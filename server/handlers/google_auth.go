@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+	"viral-cuts-server/google"
+	"viral-cuts-server/models"
+	"viral-cuts-server/oauth"
+	"viral-cuts-server/store"
+	"viral-cuts-server/tokens"
+	"viral-cuts-server/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// googleSignInStatePrefix namespaces sign-in states in the verification table.
+// The identifier carries the OIDC nonce instead of a user ID, since nobody is signed in yet.
+const googleSignInStatePrefix = "google-signin:"
+
+var errGoogleEmailUnverified = errors.New("google email is not verified")
+
+type GoogleAuthHandler struct {
+	auth     *AuthHandler
+	store    *store.Store
+	config   *oauth.Config
+	verifier *oauth.Verifier
+}
+
+func NewGoogleAuthHandler(auth *AuthHandler, s *store.Store, config *oauth.Config, verifier *oauth.Verifier) *GoogleAuthHandler {
+	return &GoogleAuthHandler{auth: auth, store: s, config: config, verifier: verifier}
+}
+
+// Start handles GET /api/auth/google and redirects to Google's account chooser
+func (h *GoogleAuthHandler) Start(c *gin.Context) {
+	nonce, err := utils.GenerateSessionToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start Google sign-in"})
+		return
+	}
+
+	state, err := createOAuthState(c.Request.Context(), h.store.Verifications, googleSignInStatePrefix, nonce)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start Google sign-in"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, h.config.AuthCodeURL(state, map[string]string{
+		"nonce":  nonce,
+		"prompt": "select_account",
+	}))
+}
+
+// Callback handles GET /api/auth/google/callback?code=xxx&state=xxx
+func (h *GoogleAuthHandler) Callback(c *gin.Context) {
+	if oauthErr := c.Query("error"); oauthErr != "" {
+		h.redirectToLogin(c, url.Values{"error": {oauthErr}})
+		return
+	}
+
+	code := c.Query("code")
+	state := c.Query("state")
+	if code == "" || state == "" {
+		h.redirectToLogin(c, url.Values{"error": {"missing_code"}})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	nonce, err := consumeOAuthState(ctx, h.store.Verifications, googleSignInStatePrefix, state)
+	if errors.Is(err, errInvalidOAuthState) {
+		h.redirectToLogin(c, url.Values{"error": {"invalid_state"}})
+		return
+	} else if err != nil {
+		fmt.Printf("Error consuming Google sign-in state: %v\n", err)
+		h.redirectToLogin(c, url.Values{"error": {"server_error"}})
+		return
+	}
+
+	token, err := h.config.Exchange(ctx, code)
+	if err != nil {
+		fmt.Printf("Error exchanging Google authorization code: %v\n", err)
+		h.redirectToLogin(c, url.Values{"error": {"exchange_failed"}})
+		return
+	}
+
+	claims, err := h.verifier.Verify(ctx, token.IDToken, nonce)
+	if err != nil {
+		fmt.Printf("Error verifying Google ID token: %v\n", err)
+		h.redirectToLogin(c, url.Values{"error": {"invalid_id_token"}})
+		return
+	}
+
+	user, err := h.resolveUser(ctx, claims, requestLocale(c, claims.Locale))
+	if errors.Is(err, errGoogleEmailUnverified) {
+		h.redirectToLogin(c, url.Values{"error": {"email_not_verified"}})
+		return
+	} else if err != nil {
+		fmt.Printf("Error signing in with Google: %v\n", err)
+		h.redirectToLogin(c, url.Values{"error": {"server_error"}})
+		return
+	}
+
+	// Google doesn't replace our second factor
+	enrollment, err := h.store.MFA.Get(ctx, user.ID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		h.redirectToLogin(c, url.Values{"error": {"server_error"}})
+		return
+	}
+	if enrollment.Enabled() {
+		challenge, err := tokens.Issue(ctx, h.store.Verifications, tokens.MFAChallenge, user.ID, tokens.MFAChallengeTTL)
+		if err != nil {
+			h.redirectToLogin(c, url.Values{"error": {"server_error"}})
+			return
+		}
+		setMFAChallengeCookie(c, challenge, tokens.MFAChallengeTTL)
+		h.redirectToLogin(c, url.Values{"status": {"mfa_pending"}})
+		return
+	}
+
+	session, err := h.auth.createSession(c, h.store.Sessions, user.ID)
+	if err != nil {
+		h.redirectToLogin(c, url.Values{"error": {"server_error"}})
+		return
+	}
+	setSessionCookie(c, session)
+
+	c.Redirect(http.StatusFound, appURL()+"/dashboard")
+}
+
+// resolveUser finds the user of a Google account, linking it to the user with the same email or
+// creating a new user with the given locale on first sign-in.
+func (h *GoogleAuthHandler) resolveUser(ctx context.Context, claims *oauth.IDTokenClaims, locale string) (*models.User, error) {
+	var user *models.User
+	err := h.store.InTx(ctx, func(tx *store.Store) error {
+		account, err := tx.Accounts.GetByProvider(ctx, google.ProviderID, claims.Subject)
+		if err == nil {
+			user, err = tx.Users.GetByID(ctx, account.UserID)
+			return err
+		} else if !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+
+		// Linking by email is only safe when Google vouches for the address
+		if !claims.EmailVerified || claims.Email == "" {
+			return errGoogleEmailUnverified
+		}
+
+		now := time.Now()
+		user, err = tx.Users.GetByEmail(ctx, claims.Email)
+		if err == nil && !user.EmailVerified {
+			// Anyone could have signed up with this address before its owner. Drop that password and
+			// its sessions; the owner can set a password again through the reset flow.
+			if err := tx.Sessions.DeleteByUser(ctx, user.ID); err != nil {
+				return err
+			}
+			if err := tx.Accounts.DeleteCredential(ctx, user.ID); err != nil {
+				return err
+			}
+			if err := tx.Users.MarkEmailVerified(ctx, user.Email); err != nil {
+				return err
+			}
+			user.EmailVerified = true
+		} else if errors.Is(err, store.ErrNotFound) {
+			user = &models.User{
+				ID:            utils.GenerateID(),
+				Name:          claims.Name,
+				Email:         claims.Email,
+				EmailVerified: true,
+				Role:          models.RoleUser,
+				Locale:        locale,
+				CreatedAt:     now,
+				UpdatedAt:     now,
+			}
+			if user.Name == "" {
+				user.Name = claims.Email
+			}
+			if claims.Picture != "" {
+				user.Image = &claims.Picture
+			}
+			if err := tx.Users.Create(ctx, user); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+
+		return tx.Accounts.Create(ctx, &models.Account{
+			ID:         utils.GenerateID(),
+			AccountID:  claims.Subject,
+			ProviderID: google.ProviderID,
+			UserID:     user.ID,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (h *GoogleAuthHandler) redirectToLogin(c *gin.Context, params url.Values) {
+	c.Redirect(http.StatusFound, appURL()+"/login?"+params.Encode())
+}
@@ -0,0 +1,416 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+	"viral-cuts-server/mfa"
+	"viral-cuts-server/models"
+	"viral-cuts-server/store"
+	"viral-cuts-server/tokens"
+	"viral-cuts-server/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxMFAAttempts is how many wrong codes end a sign-in challenge
+const maxMFAAttempts = 5
+
+var errInvalidMFACode = errors.New("invalid two-factor code")
+
+type MFAHandler struct {
+	store *store.Store
+}
+
+func NewMFAHandler(s *store.Store) *MFAHandler {
+	return &MFAHandler{store: s}
+}
+
+// MFACodeRequest carries either a TOTP code or a recovery code
+type MFACodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+// VerifyMFARequest represents the second sign-in step.
+// The challenge of a Google sign-in comes in a cookie instead of mfaToken.
+type VerifyMFARequest struct {
+	MFAToken string `json:"mfaToken"`
+	MFACodeRequest
+}
+
+// Status handles GET /api/auth/mfa
+func (h *MFAHandler) Status(c *gin.Context) {
+	user, _ := CurrentUser(c)
+	ctx := c.Request.Context()
+
+	enrollment, err := h.store.MFA.Get(ctx, user.ID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if !enrollment.Enabled() {
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+		return
+	}
+
+	left, err := h.store.MFA.RecoveryCodesLeft(ctx, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":           true,
+		"enabledAt":         enrollment.EnabledAt,
+		"recoveryCodesLeft": left,
+	})
+}
+
+// Enroll handles POST /api/auth/mfa/enroll and returns a new secret to add to an authenticator app.
+// Two-factor authentication is only enabled once Confirm receives a code generated from it.
+func (h *MFAHandler) Enroll(c *gin.Context) {
+	user, _ := CurrentUser(c)
+	ctx := c.Request.Context()
+
+	enrollment, err := h.store.MFA.Get(ctx, user.ID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if enrollment.Enabled() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := mfa.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+
+	now := time.Now()
+	err = h.store.MFA.SetPending(ctx, &models.UserMFA{
+		ID:        utils.GenerateID(),
+		UserID:    user.ID,
+		Secret:    secret,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		fmt.Printf("Error saving MFA secret: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start enrollment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":     secret,
+		"otpauthUri": mfa.URI(mfaIssuer(), user.Email, secret),
+	})
+}
+
+// Confirm handles POST /api/auth/mfa/confirm. A valid code enables two-factor authentication and
+// returns the recovery codes, which are only shown this once.
+func (h *MFAHandler) Confirm(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, _ := CurrentUser(c)
+	ctx := c.Request.Context()
+
+	enrollment, err := h.store.MFA.Get(ctx, user.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start enrollment first"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if enrollment.Enabled() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	step, ok := mfa.Validate(enrollment.Secret, req.Code, time.Now())
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	codes, err := mfa.GenerateRecoveryCodes(mfa.RecoveryCodeCount)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	err = h.store.InTx(ctx, func(tx *store.Store) error {
+		if err := tx.MFA.Enable(ctx, user.ID, step); err != nil {
+			return err
+		}
+		return tx.MFA.ReplaceRecoveryCodes(ctx, user.ID, hashRecoveryCodes(codes))
+	})
+	if err != nil {
+		fmt.Printf("Error enabling MFA: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Two-factor authentication enabled",
+		"recoveryCodes": codes,
+	})
+}
+
+// RegenerateRecoveryCodes handles POST /api/auth/mfa/recovery-codes and replaces every recovery code
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, _ := CurrentUser(c)
+	ctx := c.Request.Context()
+
+	if !h.requireSecondFactor(c, user.ID, req) {
+		return
+	}
+
+	codes, err := mfa.GenerateRecoveryCodes(mfa.RecoveryCodeCount)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+	if err := h.store.MFA.ReplaceRecoveryCodes(ctx, user.ID, hashRecoveryCodes(codes)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+// Disable handles POST /api/auth/mfa/disable. It needs a current code so a stolen session alone
+// can't turn the second factor off.
+func (h *MFAHandler) Disable(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, _ := CurrentUser(c)
+
+	if !h.requireSecondFactor(c, user.ID, req) {
+		return
+	}
+
+	if err := h.store.MFA.Delete(c.Request.Context(), user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// requireSecondFactor checks the code of an enabled enrollment, writing the error response when it fails.
+// Wrong codes count towards maxMFAAttempts like at sign-in; past it the session is signed out, so a
+// stolen session can't keep guessing.
+func (h *MFAHandler) requireSecondFactor(c *gin.Context, userID string, req MFACodeRequest) bool {
+	ctx := c.Request.Context()
+
+	enrollment, err := h.store.MFA.Get(ctx, userID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return false
+	}
+	if !enrollment.Enabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return false
+	}
+
+	err = checkSecondFactor(ctx, h.store.MFA, enrollment, req)
+	if errors.Is(err, errInvalidMFACode) {
+		h.secondFactorFailed(c, userID)
+		return false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return false
+	}
+	return true
+}
+
+// secondFactorFailed counts a wrong code and signs the current session out after maxMFAAttempts
+func (h *MFAHandler) secondFactorFailed(c *gin.Context, userID string) {
+	ctx := c.Request.Context()
+
+	attempts, err := h.store.MFA.RecordFailure(ctx, userID)
+	if err != nil {
+		fmt.Printf("Error recording MFA failure: %v\n", err)
+	}
+	if attempts >= maxMFAAttempts {
+		if session, ok := CurrentSession(c); ok {
+			if err := h.store.Sessions.Delete(ctx, userID, session.ID); err != nil {
+				fmt.Printf("Error signing out after MFA failures: %v\n", err)
+			}
+		}
+		clearSessionCookie(c)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Too many invalid codes, sign in again"})
+		return
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+}
+
+// VerifyMFA handles POST /api/auth/mfa/verify, the second step of SignIn for users with
+// two-factor authentication. It trades the mfa_pending challenge and a code for a session.
+func (h *AuthHandler) VerifyMFA(c *gin.Context) {
+	var req VerifyMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.MFAToken == "" {
+		req.MFAToken = mfaChallengeFromCookie(c)
+	}
+	if req.MFAToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing sign-in challenge"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	challenge, err := tokens.Check(ctx, h.store.Verifications, tokens.MFAChallenge, req.MFAToken)
+	if errors.Is(err, tokens.ErrInvalid) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired sign-in challenge"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	userID := challenge.Identifier
+
+	enrollment, err := h.store.MFA.Get(ctx, userID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if !enrollment.Enabled() {
+		// Reset by an admin after the password step
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired sign-in challenge"})
+		return
+	}
+
+	// The challenge, the code and the new session are used up together
+	var user *models.User
+	var session *models.Session
+	err = h.store.InTx(ctx, func(tx *store.Store) error {
+		if _, err := tokens.Consume(ctx, tx.Verifications, tokens.MFAChallenge, req.MFAToken); err != nil {
+			return err
+		}
+		if err := checkSecondFactor(ctx, tx.MFA, enrollment, req.MFACodeRequest); err != nil {
+			return err
+		}
+		var err error
+		if user, err = tx.Users.GetByID(ctx, userID); err != nil {
+			return err
+		}
+		session, err = h.createSession(c, tx.Sessions, userID)
+		return err
+	})
+	if errors.Is(err, errInvalidMFACode) {
+		h.mfaFailed(c, userID)
+		return
+	} else if errors.Is(err, tokens.ErrInvalid) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired sign-in challenge"})
+		return
+	} else if err != nil {
+		fmt.Printf("MFA verification failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+
+	setSessionCookie(c, session)
+	clearMFAChallengeCookie(c)
+
+	c.JSON(http.StatusOK, gin.H{
+		"user":    user,
+		"session": session,
+	})
+}
+
+// mfaFailed counts a wrong code and ends the user's sign-in challenges after maxMFAAttempts
+func (h *AuthHandler) mfaFailed(c *gin.Context, userID string) {
+	ctx := c.Request.Context()
+
+	attempts, err := h.store.MFA.RecordFailure(ctx, userID)
+	if err != nil {
+		fmt.Printf("Error recording MFA failure: %v\n", err)
+	}
+	if attempts >= maxMFAAttempts {
+		if err := tokens.Revoke(ctx, h.store.Verifications, tokens.MFAChallenge, userID); err != nil {
+			fmt.Printf("Error revoking MFA challenges: %v\n", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Too many invalid codes, sign in again"})
+		return
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+}
+
+// issueMFAChallenge starts the mfa_pending step of SignIn
+func (h *AuthHandler) issueMFAChallenge(c *gin.Context, userID string) {
+	challenge, err := tokens.Issue(c.Request.Context(), h.store.Verifications, tokens.MFAChallenge, userID, tokens.MFAChallengeTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create sign-in challenge"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "mfa_pending",
+		"mfaToken":  challenge,
+		"expiresIn": int(tokens.MFAChallengeTTL.Seconds()),
+	})
+}
+
+// checkSecondFactor accepts a TOTP code newer than the last accepted one, or an unused recovery code
+func checkSecondFactor(ctx context.Context, mfaStore store.MFAStore, enrollment *models.UserMFA, req MFACodeRequest) error {
+	var err error
+	switch {
+	case req.Code != "":
+		step, ok := mfa.Validate(enrollment.Secret, req.Code, time.Now())
+		if !ok {
+			return errInvalidMFACode
+		}
+		err = mfaStore.AcceptStep(ctx, enrollment.UserID, step)
+	case req.RecoveryCode != "":
+		err = mfaStore.UseRecoveryCode(ctx, enrollment.UserID, mfa.HashRecoveryCode(req.RecoveryCode))
+	default:
+		return errInvalidMFACode
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		return errInvalidMFACode
+	}
+	return err
+}
+
+func hashRecoveryCodes(codes []string) []string {
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = mfa.HashRecoveryCode(code)
+	}
+	return hashes
+}
+
+// mfaIssuer names the account in authenticator apps
+func mfaIssuer() string {
+	if issuer := os.Getenv("MFA_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "Viral Cuts"
+}
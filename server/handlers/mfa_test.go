@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+	"viral-cuts-server/mfa"
+	"viral-cuts-server/models"
+	"viral-cuts-server/store"
+	"viral-cuts-server/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// enableMFA enrolls userID with secret, as if confirmed by a code of step
+func enableMFA(t *testing.T, stores *store.Store, userID, secret string, step int64) *models.UserMFA {
+	t.Helper()
+	ctx := context.Background()
+	if err := stores.MFA.SetPending(ctx, &models.UserMFA{ID: utils.GenerateID(), UserID: userID, Secret: secret}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.MFA.Enable(ctx, userID, step); err != nil {
+		t.Fatal(err)
+	}
+	enrollment, err := stores.MFA.Get(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enrollment
+}
+
+func TestCheckSecondFactorRejectsReplay(t *testing.T) {
+	ctx := context.Background()
+	stores := store.NewMemory()
+	secret, _ := mfa.GenerateSecret()
+	now := mfa.Step(time.Now())
+	enrollment := enableMFA(t, stores, "user-1", secret, now-2)
+
+	code, _ := mfa.Code(secret, now)
+	if err := checkSecondFactor(ctx, stores.MFA, enrollment, MFACodeRequest{Code: code}); err != nil {
+		t.Fatalf("fresh code: %v", err)
+	}
+	if err := checkSecondFactor(ctx, stores.MFA, enrollment, MFACodeRequest{Code: code}); !errors.Is(err, errInvalidMFACode) {
+		t.Errorf("replayed code: err = %v, want errInvalidMFACode", err)
+	}
+
+	// A code from before the last accepted step is stale even inside the skew
+	previous, _ := mfa.Code(secret, now-1)
+	if err := checkSecondFactor(ctx, stores.MFA, enrollment, MFACodeRequest{Code: previous}); !errors.Is(err, errInvalidMFACode) {
+		t.Errorf("older code: err = %v, want errInvalidMFACode", err)
+	}
+	stored, _ := stores.MFA.Get(ctx, "user-1")
+	if stored.LastUsedStep != now {
+		t.Errorf("last used step = %d, want %d", stored.LastUsedStep, now)
+	}
+
+	if err := checkSecondFactor(ctx, stores.MFA, enrollment, MFACodeRequest{Code: "000000x"}); !errors.Is(err, errInvalidMFACode) {
+		t.Errorf("malformed code: err = %v, want errInvalidMFACode", err)
+	}
+	if err := checkSecondFactor(ctx, stores.MFA, enrollment, MFACodeRequest{}); !errors.Is(err, errInvalidMFACode) {
+		t.Errorf("no code: err = %v, want errInvalidMFACode", err)
+	}
+}
+
+func TestCheckSecondFactorRecoveryCodesAreSingleUse(t *testing.T) {
+	ctx := context.Background()
+	stores := store.NewMemory()
+	secret, _ := mfa.GenerateSecret()
+	enrollment := enableMFA(t, stores, "user-1", secret, 0)
+
+	codes, _ := mfa.GenerateRecoveryCodes(2)
+	if err := stores.MFA.ReplaceRecoveryCodes(ctx, "user-1", hashRecoveryCodes(codes)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stores.MFA.RecordFailure(ctx, "user-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkSecondFactor(ctx, stores.MFA, enrollment, MFACodeRequest{RecoveryCode: codes[0]}); err != nil {
+		t.Fatalf("unused recovery code: %v", err)
+	}
+	if err := checkSecondFactor(ctx, stores.MFA, enrollment, MFACodeRequest{RecoveryCode: codes[0]}); !errors.Is(err, errInvalidMFACode) {
+		t.Errorf("used recovery code: err = %v, want errInvalidMFACode", err)
+	}
+	if err := checkSecondFactor(ctx, stores.MFA, enrollment, MFACodeRequest{RecoveryCode: "00000-00000"}); !errors.Is(err, errInvalidMFACode) {
+		t.Errorf("unknown recovery code: err = %v, want errInvalidMFACode", err)
+	}
+
+	if left, _ := stores.MFA.RecoveryCodesLeft(ctx, "user-1"); left != 1 {
+		t.Errorf("recovery codes left = %d, want 1", left)
+	}
+	if stored, _ := stores.MFA.Get(ctx, "user-1"); stored.FailedAttempts != 0 {
+		t.Errorf("failed attempts = %d after a recovery code, want 0", stored.FailedAttempts)
+	}
+
+	// Regenerating replaces the codes left
+	fresh, _ := mfa.GenerateRecoveryCodes(1)
+	if err := stores.MFA.ReplaceRecoveryCodes(ctx, "user-1", hashRecoveryCodes(fresh)); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSecondFactor(ctx, stores.MFA, enrollment, MFACodeRequest{RecoveryCode: codes[1]}); !errors.Is(err, errInvalidMFACode) {
+		t.Errorf("replaced recovery code: err = %v, want errInvalidMFACode", err)
+	}
+}
+
+func TestDisableMFASignsOutAfterTooManyCodes(t *testing.T) {
+	s := newTestServer(t)
+	session := s.signUp("Ana Souza", "ana@example.com")
+	user := s.user("ana@example.com")
+	secret, _ := mfa.GenerateSecret()
+	now := mfa.Step(time.Now())
+	enableMFA(t, s.store, user.ID, secret, now-2)
+
+	wrong, _ := mfa.Code(secret, now+10)
+	for i := 1; i < maxMFAAttempts; i++ {
+		body := s.expect(s.do("POST", "/api/auth/mfa/disable", gin.H{"code": wrong}, session), http.StatusUnauthorized)
+		if body["error"] != "Invalid code" {
+			t.Fatalf("attempt %d = %v", i, body)
+		}
+	}
+	body := s.expect(s.do("POST", "/api/auth/mfa/recovery-codes", gin.H{"recoveryCode": "00000-00000"}, session), http.StatusUnauthorized)
+	if body["error"] != "Too many invalid codes, sign in again" {
+		t.Fatalf("last attempt = %v", body)
+	}
+
+	// The session is gone and the second factor is still on
+	s.expect(s.do("GET", "/api/auth/session", nil, session), http.StatusUnauthorized)
+	s.expect(s.do("POST", "/api/auth/mfa/disable", gin.H{"code": wrong}, session), http.StatusUnauthorized)
+	if enrollment, err := s.store.MFA.Get(context.Background(), user.ID); err != nil || !enrollment.Enabled() {
+		t.Errorf("enrollment = %+v, %v, want still enabled", enrollment, err)
+	}
+}
+
+func TestRegenerateRecoveryCodesIsRateLimitedPerUser(t *testing.T) {
+	s := newTestServer(t)
+	session := s.signUp("Ana Souza", "ana@example.com")
+	user := s.user("ana@example.com")
+	secret, _ := mfa.GenerateSecret()
+	enableMFA(t, s.store, user.ID, secret, 0)
+	codes, _ := mfa.GenerateRecoveryCodes(1)
+	if err := s.store.MFA.ReplaceRecoveryCodes(context.Background(), user.ID, hashRecoveryCodes(codes)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each call uses up a recovery code from the previous response
+	code := codes[0]
+	for i := 0; i < 10; i++ {
+		body := s.expect(s.do("POST", "/api/auth/mfa/recovery-codes", gin.H{"recoveryCode": code}, session), http.StatusOK)
+		code = body["recoveryCodes"].([]any)[0].(string)
+	}
+	s.expect(s.do("POST", "/api/auth/mfa/recovery-codes", gin.H{"recoveryCode": code}, session), http.StatusTooManyRequests)
+}
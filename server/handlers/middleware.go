@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"viral-cuts-server/models"
+	"viral-cuts-server/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionCookieName is the cookie that carries the session token (same name as Better Auth for compatibility).
+// COOKIE_HOST_PREFIX adds the __Host- prefix.
+const SessionCookieName = "better-auth.session_token"
+
+// Context keys used by the session middleware
+const (
+	contextUserKey    = "auth.user"
+	contextSessionKey = "auth.session"
+)
+
+type AuthMiddleware struct {
+	sessions store.SessionStore
+	policy   SessionPolicy
+}
+
+func NewAuthMiddleware(sessions store.SessionStore, policy SessionPolicy) *AuthMiddleware {
+	return &AuthMiddleware{sessions: sessions, policy: policy}
+}
+
+// RequireSession resolves the session cookie and attaches the user and session to the context.
+// Requests without a valid, unexpired session are rejected with 401. Sessions in use are renewed
+// according to the session policy.
+func (m *AuthMiddleware) RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, err := sessionToken(c)
+		if err != nil || token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "No session found"})
+			return
+		}
+
+		session, user, err := m.sessions.GetByToken(c.Request.Context(), token)
+		if errors.Is(err, store.ErrNotFound) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired session"})
+			return
+		} else if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		// Catches sessions created before SESSION_MAX_LIFETIME was lowered
+		if time.Since(session.CreatedAt) > m.policy.MaxLifetime {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired session"})
+			return
+		}
+
+		m.touch(c, session)
+
+		c.Set(contextUserKey, user)
+		c.Set(contextSessionKey, session)
+		c.Next()
+	}
+}
+
+// touch records activity on the session at most once per ActivityInterval and slides its expiry
+// once past half of the idle timeout, refreshing the cookie to match. Failures are only logged:
+// the session is still valid.
+func (m *AuthMiddleware) touch(c *gin.Context, session *models.Session) {
+	now := time.Now()
+	renew := m.policy.needsRenewal(session.CreatedAt, session.ExpiresAt, now)
+	if !renew && now.Sub(session.UpdatedAt) < m.policy.ActivityInterval {
+		return
+	}
+
+	expiresAt := session.ExpiresAt
+	if renew {
+		expiresAt = m.policy.expiry(session.CreatedAt, now)
+	}
+	if err := m.sessions.Touch(c.Request.Context(), session.ID, now, expiresAt); err != nil {
+		fmt.Printf("Error touching session: %v\n", err)
+		return
+	}
+
+	session.UpdatedAt = now
+	if renew {
+		session.ExpiresAt = expiresAt
+		setSessionCookie(c, session)
+	}
+}
+
+// RequireRole rejects users without the given role with 403. Must run after RequireSession.
+func (m *AuthMiddleware) RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := CurrentUser(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "No session found"})
+			return
+		}
+		if user.Role != role {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// CurrentUser returns the user attached by RequireSession
+func CurrentUser(c *gin.Context) (*models.User, bool) {
+	value, ok := c.Get(contextUserKey)
+	if !ok {
+		return nil, false
+	}
+	user, ok := value.(*models.User)
+	return user, ok
+}
+
+// CurrentSession returns the session attached by RequireSession
+func CurrentSession(c *gin.Context) (*models.Session, bool) {
+	value, ok := c.Get(contextSessionKey)
+	if !ok {
+		return nil, false
+	}
+	session, ok := value.(*models.Session)
+	return session, ok
+}
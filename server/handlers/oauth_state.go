@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"viral-cuts-server/store"
+	"viral-cuts-server/tokens"
+)
+
+var errInvalidOAuthState = errors.New("invalid or expired oauth state")
+
+// createOAuthState stores a one-time state value tying an OAuth callback back to the user.
+// prefix namespaces states per provider in the verification table.
+func createOAuthState(ctx context.Context, verifications store.VerificationStore, prefix, userID string) (string, error) {
+	return tokens.Issue(ctx, verifications, tokens.OAuthState, prefix+userID, tokens.OAuthStateTTL)
+}
+
+// consumeOAuthState deletes the state and returns the user it was created for
+func consumeOAuthState(ctx context.Context, verifications store.VerificationStore, prefix, state string) (string, error) {
+	verification, err := tokens.Consume(ctx, verifications, tokens.OAuthState, state)
+	if errors.Is(err, tokens.ErrInvalid) {
+		return "", errInvalidOAuthState
+	} else if err != nil {
+		return "", err
+	}
+	// A state issued for another provider is spent but not accepted
+	if !strings.HasPrefix(verification.Identifier, prefix) {
+		return "", errInvalidOAuthState
+	}
+	return strings.TrimPrefix(verification.Identifier, prefix), nil
+}
+
+// appURL returns the frontend base URL
+func appURL() string {
+	if url := os.Getenv("APP_URL"); url != "" {
+		return url
+	}
+	return "http://localhost:5173"
+}
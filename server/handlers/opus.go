@@ -0,0 +1,403 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+	"viral-cuts-server/models"
+	"viral-cuts-server/opus"
+	"viral-cuts-server/storage"
+	"viral-cuts-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type OpusHandler struct {
+	db     *pgxpool.Pool
+	client *opus.Client
+	syncer *opus.Syncer
+	files  *storage.Source
+}
+
+func NewOpusHandler(db *pgxpool.Pool, client *opus.Client, syncer *opus.Syncer, files *storage.Source) *OpusHandler {
+	return &OpusHandler{db: db, client: client, syncer: syncer, files: files}
+}
+
+// CreateOpusJobRequest represents the create Opus job request body.
+// Either videoUrl (e.g. a YouTube link) or fileUrl of an uploaded file is required.
+type CreateOpusJobRequest struct {
+	VideoURL    string  `json:"videoUrl" binding:"omitempty,url"`
+	FileURL     string  `json:"fileUrl" binding:"omitempty,url"`
+	AutoEnqueue *bool   `json:"autoEnqueue"`
+	MinScore    *int    `json:"minScore" binding:"omitempty,min=0,max=100"`
+	Platform    *string `json:"platform"`
+}
+
+// EnqueueOpusClipRequest represents the manual enqueue request body
+type EnqueueOpusClipRequest struct {
+	Platform      *string    `json:"platform"`
+	ScheduledAt   *time.Time `json:"scheduledAt"`
+	PrivacyStatus string     `json:"privacyStatus" binding:"omitempty,oneof=private unlisted public"`
+}
+
+// SaveOpusAPIKeyRequest represents the save Opus API key request body. An empty key removes it.
+type SaveOpusAPIKeyRequest struct {
+	APIKey string `json:"apiKey"`
+}
+
+// APIKeyStatus handles GET /api/opus/api-key. The key itself is never returned.
+func (h *OpusHandler) APIKeyStatus(c *gin.Context) {
+	user, _ := CurrentUser(c)
+
+	_, err := h.syncer.APIKey(c.Request.Context(), user.ID)
+	if errors.Is(err, opus.ErrNoAPIKey) {
+		c.JSON(http.StatusOK, gin.H{"configured": false})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read API key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"configured": true})
+}
+
+// SaveAPIKey handles PUT /api/opus/api-key and stores the key encrypted
+func (h *OpusHandler) SaveAPIKey(c *gin.Context) {
+	var req SaveOpusAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, _ := CurrentUser(c)
+
+	var apiKey *string
+	if key := strings.TrimSpace(req.APIKey); key != "" {
+		encrypted, err := utils.EncryptSecret(key)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encrypt API key"})
+			return
+		}
+		apiKey = &encrypted
+	}
+
+	_, err := h.db.Exec(c.Request.Context(),
+		`INSERT INTO user_settings (user_id, opus_api_key, updated_at)
+		 VALUES ($1, $2, NOW())
+		 ON CONFLICT (user_id) DO UPDATE SET opus_api_key = EXCLUDED.opus_api_key, updated_at = NOW()`,
+		user.ID, apiKey,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save API key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"configured": apiKey != nil})
+}
+
+// CreateJob handles POST /api/opus/jobs
+func (h *OpusHandler) CreateJob(c *gin.Context) {
+	var req CreateOpusJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sourceType, sourceURL := "url", req.VideoURL
+	if req.FileURL != "" {
+		sourceType, sourceURL = "upload", req.FileURL
+	}
+	if sourceURL == "" || (req.VideoURL != "" && req.FileURL != "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide either videoUrl or fileUrl"})
+		return
+	}
+	if req.FileURL != "" && h.files.CheckURL(req.FileURL) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "fileUrl must point to an uploaded file"})
+		return
+	}
+
+	user, _ := CurrentUser(c)
+	ctx := c.Request.Context()
+
+	apiKey, err := h.syncer.APIKey(ctx, user.ID)
+	if errors.Is(err, opus.ErrNoAPIKey) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Opus API key not configured"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	// Unset options fall back to the user's settings
+	var autoEnqueue bool
+	var minScore int
+	err = h.db.QueryRow(ctx,
+		`SELECT COALESCE(opus_auto_enqueue, false), COALESCE(opus_min_score, 80)
+		 FROM user_settings WHERE user_id = $1`,
+		user.ID,
+	).Scan(&autoEnqueue, &minScore)
+	if err != nil && err != pgx.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if req.AutoEnqueue != nil {
+		autoEnqueue = *req.AutoEnqueue
+	}
+	if req.MinScore != nil {
+		minScore = *req.MinScore
+	}
+	platform := "YouTube Shorts"
+	if req.Platform != nil && *req.Platform != "" {
+		platform = *req.Platform
+	}
+
+	webhookToken, err := utils.GenerateSessionToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create job"})
+		return
+	}
+
+	now := time.Now()
+	job, err := models.ScanOpusJob(h.db.QueryRow(ctx,
+		`INSERT INTO opus_job (id, user_id, source_type, source_url, status, auto_enqueue, min_score, platform,
+		                       webhook_token, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		 RETURNING `+models.OpusJobColumns,
+		utils.GenerateID(), user.ID, sourceType, sourceURL, models.OpusJobProcessing, autoEnqueue, minScore, platform,
+		webhookToken, now, now,
+	))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create job"})
+		return
+	}
+
+	project, err := h.client.CreateProject(ctx, apiKey, sourceURL, webhookURL(job))
+	if err != nil {
+		fmt.Printf("Error creating Opus project: %v\n", err)
+		h.db.Exec(ctx,
+			`UPDATE opus_job SET status = $1, error_message = $2, updated_at = NOW() WHERE id = $3`,
+			models.OpusJobError, err.Error(), job.ID,
+		)
+		if errors.Is(err, opus.ErrInvalidAPIKey) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Opus rejected the API key"})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to start Opus project", "details": err.Error()})
+		return
+	}
+
+	_, err = h.db.Exec(ctx,
+		`UPDATE opus_job SET opus_project_id = $1, updated_at = NOW() WHERE id = $2`,
+		project.ID, job.ID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save Opus project"})
+		return
+	}
+	job.OpusProjectID = &project.ID
+
+	c.JSON(http.StatusCreated, job)
+}
+
+// ListJobs handles GET /api/opus/jobs
+func (h *OpusHandler) ListJobs(c *gin.Context) {
+	user, _ := CurrentUser(c)
+
+	rows, err := h.db.Query(c.Request.Context(),
+		`SELECT `+models.OpusJobColumns+`
+		 FROM opus_job
+		 WHERE user_id = $1
+		 ORDER BY created_at DESC`,
+		user.ID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch jobs", "details": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	jobs := []*models.OpusJob{}
+	for rows.Next() {
+		job, err := models.ScanOpusJob(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan job row", "details": err.Error()})
+			return
+		}
+		jobs = append(jobs, job)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs})
+}
+
+// GetJob handles GET /api/opus/jobs/:id and includes the clips sorted by virality score
+func (h *OpusHandler) GetJob(c *gin.Context) {
+	user, _ := CurrentUser(c)
+	ctx := c.Request.Context()
+
+	job, err := models.ScanOpusJob(h.db.QueryRow(ctx,
+		`SELECT `+models.OpusJobColumns+` FROM opus_job WHERE id = $1 AND user_id = $2`,
+		c.Param("id"), user.ID,
+	))
+	if err == pgx.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	rows, err := h.db.Query(ctx,
+		`SELECT `+models.OpusClipColumns+`
+		 FROM opus_clip
+		 WHERE job_id = $1
+		 ORDER BY virality_score DESC`,
+		job.ID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch clips", "details": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	clips := []*models.OpusClip{}
+	for rows.Next() {
+		clip, err := models.ScanOpusClip(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan clip row", "details": err.Error()})
+			return
+		}
+		clips = append(clips, clip)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"job": job, "clips": clips})
+}
+
+// EnqueueClip handles POST /api/opus/clips/:id/enqueue
+func (h *OpusHandler) EnqueueClip(c *gin.Context) {
+	var req EnqueueOpusClipRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, _ := CurrentUser(c)
+	ctx := c.Request.Context()
+
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var platform string
+	clip, err := models.ScanOpusClip(tx.QueryRow(ctx,
+		`SELECT `+models.OpusClipColumns+` FROM opus_clip WHERE id = $1 AND user_id = $2 FOR UPDATE`,
+		c.Param("id"), user.ID,
+	))
+	if err == pgx.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Clip not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if clip.QueueItemID != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Clip is already queued", "queueItemId": *clip.QueueItemID})
+		return
+	}
+	if clip.DownloadURL == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Clip has no download URL"})
+		return
+	}
+
+	if err := tx.QueryRow(ctx, `SELECT platform FROM opus_job WHERE id = $1`, clip.JobID).Scan(&platform); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if req.Platform != nil && *req.Platform != "" {
+		platform = *req.Platform
+	}
+	var privacyStatus *string
+	if req.PrivacyStatus != "" {
+		privacyStatus = &req.PrivacyStatus
+	}
+
+	now := time.Now()
+	item, err := models.ScanUploadQueueItem(tx.QueryRow(ctx,
+		`INSERT INTO upload_queue (id, user_id, title, source, platform, status, file_url, scheduled_at,
+		                           privacy_status, created_at, updated_at)
+		 VALUES ($1, $2, $3, 'opus', $4, $5, $6, COALESCE($7, $9),
+		         COALESCE($8, (SELECT default_visibility FROM user_settings WHERE user_id = $2), 'private'), $9, $10)
+		 RETURNING `+models.QueueItemColumns,
+		utils.GenerateID(), user.ID, clip.Title, platform, models.QueueStatusReady, *clip.DownloadURL,
+		req.ScheduledAt, privacyStatus, now, now,
+	))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create queue item"})
+		return
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE opus_clip SET queue_item_id = $1 WHERE id = $2`, item.ID, clip.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link queue item"})
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, item)
+}
+
+// Webhook handles POST /api/opus/webhook/:id?token=xxx
+// Opus calls it when a project changes state; the payload is not trusted, the job is re-synced from the API.
+func (h *OpusHandler) Webhook(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	job, err := models.ScanOpusJob(h.db.QueryRow(ctx,
+		`SELECT `+models.OpusJobColumns+` FROM opus_job WHERE id = $1`,
+		c.Param("id"),
+	))
+	if err == pgx.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(c.Query("token")), []byte(job.WebhookToken)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid webhook token"})
+		return
+	}
+
+	// Acknowledge right away; syncing lists every clip and may take a while
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+		if err := h.syncer.Sync(ctx, job); err != nil {
+			fmt.Printf("Error syncing Opus job %s from webhook: %v\n", job.ID, err)
+		}
+	}()
+
+	c.JSON(http.StatusOK, gin.H{"received": true})
+}
+
+// webhookURL is the per-job callback URL handed to Opus
+func webhookURL(job *models.OpusJob) string {
+	backendURL := os.Getenv("BACKEND_URL")
+	if backendURL == "" {
+		backendURL = "http://localhost:3000"
+	}
+	return backendURL + "/api/opus/webhook/" + job.ID + "?" + url.Values{"token": {job.WebhookToken}}.Encode()
+}
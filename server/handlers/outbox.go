@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"context"
+	"time"
+	"viral-cuts-server/mail"
+	"viral-cuts-server/models"
+	"viral-cuts-server/store"
+	"viral-cuts-server/utils"
+)
+
+// queueEmail writes msg to the outbox for the outbox worker to deliver. Call it with the stores of the
+// transaction that issued the email's token, so neither exists without the other.
+func queueEmail(ctx context.Context, outbox store.OutboxStore, msg *mail.Message) error {
+	now := time.Now()
+	return outbox.Enqueue(ctx, &models.OutboxEmail{
+		ID:            utils.GenerateID(),
+		Sender:        msg.From,
+		Recipient:     msg.To,
+		Subject:       msg.Subject,
+		HTML:          msg.HTML,
+		Text:          msg.Text,
+		Status:        models.OutboxStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	})
+}
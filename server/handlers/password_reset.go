@@ -1,24 +1,24 @@
 package handlers
 
 import (
-	"context"
-	"crypto/rand"
-	"encoding/base64"
+	"errors"
+	"fmt"
 	"net/http"
-	"time"
+	"viral-cuts-server/mail"
+	"viral-cuts-server/passwords"
+	"viral-cuts-server/store"
+	"viral-cuts-server/tokens"
 	"viral-cuts-server/utils"
 
 	"github.com/gin-gonic/gin"
-	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type PasswordResetHandler struct {
-	db *pgxpool.Pool
+	store *store.Store
 }
 
-func NewPasswordResetHandler(db *pgxpool.Pool) *PasswordResetHandler {
-	return &PasswordResetHandler{db: db}
+func NewPasswordResetHandler(s *store.Store) *PasswordResetHandler {
+	return &PasswordResetHandler{store: s}
 }
 
 // ForgotPasswordRequest represents the forgot password request body
@@ -29,7 +29,7 @@ type ForgotPasswordRequest struct {
 // ResetPasswordRequest represents the reset password request body
 type ResetPasswordRequest struct {
 	Token       string `json:"token" binding:"required"`
-	NewPassword string `json:"newPassword" binding:"required,min=6"`
+	NewPassword string `json:"newPassword" binding:"required"` // checked by the password policy
 }
 
 // ForgotPassword handles password reset requests
@@ -40,15 +40,13 @@ func (h *PasswordResetHandler) ForgotPassword(c *gin.Context) {
 		return
 	}
 
+	ctx := c.Request.Context()
+
 	// Check if user exists
-	var userID string
-	err := h.db.QueryRow(context.Background(),
-		`SELECT id FROM "user" WHERE email = $1`,
-		req.Email,
-	).Scan(&userID)
+	user, err := h.store.Users.GetByEmail(ctx, req.Email)
 
 	// Always return success to prevent email enumeration
-	if err == pgx.ErrNoRows {
+	if errors.Is(err, store.ErrNotFound) {
 		c.JSON(http.StatusOK, gin.H{"message": "Se o email existir, você receberá instruções para resetar sua senha"})
 		return
 	} else if err != nil {
@@ -56,37 +54,24 @@ func (h *PasswordResetHandler) ForgotPassword(c *gin.Context) {
 		return
 	}
 
-	// Generate reset token
-	tokenBytes := make([]byte, 32)
-	_, err = rand.Read(tokenBytes)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
-		return
-	}
-	token := base64.URLEncoding.EncodeToString(tokenBytes)
-
-	// Store token in verification table
-	verificationID := utils.GenerateID()
-	expiresAt := time.Now().Add(1 * time.Hour) // Token expires in 1 hour
-
-	_, err = h.db.Exec(context.Background(),
-		`INSERT INTO "verification" (id, identifier, value, expires_at, created_at, updated_at)
-		 VALUES ($1, $2, $3, $4, $5, $6)`,
-		verificationID, req.Email, token, expiresAt, time.Now(), time.Now(),
-	)
+	// Generate reset token (expires in 1 hour) and queue its email in one transaction
+	err = h.store.InTx(ctx, func(tx *store.Store) error {
+		token, err := tokens.Issue(ctx, tx.Verifications, tokens.PasswordReset, req.Email, tokens.PasswordResetTTL)
+		if err != nil {
+			return err
+		}
+		msg, err := mail.PasswordResetEmail(req.Email, user.Locale, token)
+		if err != nil {
+			return err
+		}
+		return queueEmail(ctx, tx.Outbox, msg)
+	})
 	if err != nil {
+		fmt.Printf("Error creating password reset: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reset token"})
 		return
 	}
 
-	// Send password reset email (async)
-	go func() {
-		err := utils.SendPasswordResetEmail(req.Email, token)
-		if err != nil {
-			println("Failed to send password reset email:", err.Error())
-		}
-	}()
-
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Se o email existir, você receberá instruções para resetar sua senha",
 	})
@@ -101,16 +86,11 @@ func (h *PasswordResetHandler) ResetPassword(c *gin.Context) {
 		return
 	}
 
-	// Verify token
-	var email string
-	var expiresAt time.Time
-	err := h.db.QueryRow(context.Background(),
-		`SELECT identifier, expires_at FROM "verification" 
-		 WHERE value = $1 AND expires_at > NOW()`,
-		req.Token,
-	).Scan(&email, &expiresAt)
+	ctx := c.Request.Context()
 
-	if err == pgx.ErrNoRows {
+	// Verify token before spending time on hashing
+	verification, err := tokens.Check(ctx, h.store.Verifications, tokens.PasswordReset, req.Token)
+	if errors.Is(err, tokens.ErrInvalid) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Token inválido ou expirado"})
 		return
 	} else if err != nil {
@@ -118,16 +98,18 @@ func (h *PasswordResetHandler) ResetPassword(c *gin.Context) {
 		return
 	}
 
-	// Get user ID
-	var userID string
-	err = h.db.QueryRow(context.Background(),
-		`SELECT id FROM "user" WHERE email = $1`,
-		email,
-	).Scan(&userID)
-
-	if err != nil {
+	// The policy needs the user's name; the token stays valid when the password is refused
+	user, err := h.store.Users.GetByEmail(ctx, verification.Identifier)
+	if errors.Is(err, store.ErrNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if err := passwords.Check(req.NewPassword, user.Email, user.Name); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	// Hash new password
@@ -137,36 +119,43 @@ func (h *PasswordResetHandler) ResetPassword(c *gin.Context) {
 		return
 	}
 
-	// Update password in account table
-	_, err = h.db.Exec(context.Background(),
-		`UPDATE "account" 
-		 SET password = $1, updated_at = $2
-		 WHERE user_id = $3 AND provider_id = 'credential'`,
-		hashedPassword, time.Now(), userID,
-	)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
-		return
-	}
+	// Consuming the token, updating the password and invalidating sessions happen atomically:
+	// a token can't be replayed, and old sessions never survive a successful reset
+	errUserNotFound := errors.New("user not found")
+	err = h.store.InTx(ctx, func(tx *store.Store) error {
+		// Delete used token (fails if a concurrent request already used it)
+		verification, err := tokens.Consume(ctx, tx.Verifications, tokens.PasswordReset, req.Token)
+		if err != nil {
+			return err
+		}
 
-	// Delete used token
-	_, err = h.db.Exec(context.Background(),
-		`DELETE FROM "verification" WHERE value = $1`,
-		req.Token,
-	)
-	if err != nil {
-		// Log error but don't fail the request
-		println("Failed to delete verification token:", err.Error())
-	}
+		user, err := tx.Users.GetByEmail(ctx, verification.Identifier)
+		if errors.Is(err, store.ErrNotFound) {
+			return errUserNotFound
+		} else if err != nil {
+			return err
+		}
 
-	// Invalidate all existing sessions for this user
-	_, err = h.db.Exec(context.Background(),
-		`DELETE FROM "session" WHERE user_id = $1`,
-		userID,
-	)
-	if err != nil {
-		// Log error but don't fail the request
-		println("Failed to invalidate sessions:", err.Error())
+		// Update password in account table
+		err = tx.Accounts.UpdatePassword(ctx, user.ID, hashedPassword)
+		if errors.Is(err, store.ErrNotFound) {
+			return errUserNotFound
+		} else if err != nil {
+			return err
+		}
+
+		// Invalidate all existing sessions for this user
+		return tx.Sessions.DeleteByUser(ctx, user.ID)
+	})
+	if errors.Is(err, tokens.ErrInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token inválido ou expirado"})
+		return
+	} else if errors.Is(err, errUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Senha resetada com sucesso"})
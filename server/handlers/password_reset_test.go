@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+	"viral-cuts-server/tokens"
+	"viral-cuts-server/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPasswordReset(t *testing.T) {
+	s := newTestServer(t)
+	session := s.signUp("Ana Souza", "ana@example.com")
+	s.deliverEmails("ana@example.com") // the verification email
+	s.mailer.Reset()
+
+	s.expect(s.do("POST", "/api/auth/forgot-password", gin.H{"email": "ana@example.com"}, ""), http.StatusOK)
+	token := s.deliverEmails("ana@example.com")
+
+	newPassword := "purple elephant dancing quietly"
+	s.expect(s.do("POST", "/api/auth/reset-password", gin.H{"token": token, "newPassword": newPassword}, ""), http.StatusOK)
+
+	account, err := s.store.Accounts.GetCredential(context.Background(), s.user("ana@example.com").ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !utils.CheckPassword(*account.Password, newPassword) {
+		t.Error("password was not changed")
+	}
+	// Sessions from before the reset are signed out
+	s.expect(s.do("GET", "/api/auth/session", nil, session), http.StatusUnauthorized)
+	s.expect(s.do("POST", "/api/auth/sign-in", gin.H{"email": "ana@example.com", "password": newPassword}, ""), http.StatusOK)
+
+	// The token is used up
+	rec := s.do("POST", "/api/auth/reset-password", gin.H{"token": token, "newPassword": "another long passphrase"}, "")
+	s.expect(rec, http.StatusBadRequest)
+}
+
+func TestPasswordResetKeepsTokenForRefusedPassword(t *testing.T) {
+	s := newTestServer(t)
+	s.signUp("Ana Souza", "ana@example.com")
+	s.mailer.Reset()
+
+	s.expect(s.do("POST", "/api/auth/forgot-password", gin.H{"email": "ana@example.com"}, ""), http.StatusOK)
+	token := s.deliverEmails("ana@example.com")
+
+	s.expect(s.do("POST", "/api/auth/reset-password", gin.H{"token": token, "newPassword": "short"}, ""), http.StatusBadRequest)
+	s.expect(s.do("POST", "/api/auth/reset-password", gin.H{"token": token, "newPassword": "password123"}, ""), http.StatusBadRequest)
+	s.expect(s.do("POST", "/api/auth/reset-password", gin.H{"token": token, "newPassword": "purple elephant dancing quietly"}, ""), http.StatusOK)
+}
+
+func TestPasswordResetRejectsOtherTokens(t *testing.T) {
+	s := newTestServer(t)
+	s.signUp("Ana Souza", "ana@example.com")
+	expired := s.seedToken(tokens.PasswordReset, "ana@example.com", -time.Minute)
+	verifyToken := s.seedToken(tokens.EmailVerify, "ana@example.com", time.Hour)
+
+	for _, token := range []string{expired, verifyToken, "not-a-token"} {
+		rec := s.do("POST", "/api/auth/reset-password", gin.H{"token": token, "newPassword": "purple elephant dancing quietly"}, "")
+		s.expect(rec, http.StatusBadRequest)
+	}
+	s.expect(s.do("POST", "/api/auth/sign-in", gin.H{"email": "ana@example.com", "password": testPassword}, ""), http.StatusOK)
+}
+
+func TestForgotPasswordUnknownEmail(t *testing.T) {
+	s := newTestServer(t)
+	s.signUp("Ana Souza", "ana@example.com")
+	s.deliverEmails("ana@example.com")
+	s.mailer.Reset()
+
+	// Same answer as for a known email, and nothing is sent
+	known := s.expect(s.do("POST", "/api/auth/forgot-password", gin.H{"email": "ana@example.com"}, ""), http.StatusOK)
+	unknown := s.expect(s.do("POST", "/api/auth/forgot-password", gin.H{"email": "nobody@example.com"}, ""), http.StatusOK)
+	if known["message"] != unknown["message"] {
+		t.Errorf("responses differ: %v vs %v", known, unknown)
+	}
+	if err := s.outbox.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if sent := s.mailer.SentTo("nobody@example.com"); len(sent) != 0 {
+		t.Errorf("sent %d emails to an unknown address, want none", len(sent))
+	}
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	"viral-cuts-server/models"
+	"viral-cuts-server/storage"
+	"viral-cuts-server/store"
+	"viral-cuts-server/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type QueueHandler struct {
+	store *store.Store
+	files *storage.Source
+}
+
+func NewQueueHandler(s *store.Store, files *storage.Source) *QueueHandler {
+	return &QueueHandler{store: s, files: files}
+}
+
+// CreateQueueItemRequest represents the create queue item request body
+type CreateQueueItemRequest struct {
+	Title         string     `json:"title" binding:"required"`
+	Description   *string    `json:"description"`
+	Source        *string    `json:"source"`
+	Platform      *string    `json:"platform"`
+	FileURL       *string    `json:"fileUrl"`
+	FileName      *string    `json:"fileName"`
+	ScheduledAt   *time.Time `json:"scheduledAt"`
+	PublishAt     *time.Time `json:"publishAt"`
+	PrivacyStatus string     `json:"privacyStatus" binding:"omitempty,oneof=private unlisted public"`
+}
+
+// UpdateQueueItemRequest represents the update queue item request body
+type UpdateQueueItemRequest struct {
+	Title         *string    `json:"title"`
+	Description   *string    `json:"description"`
+	Platform      *string    `json:"platform"`
+	ScheduledAt   *time.Time `json:"scheduledAt"`
+	PublishAt     *time.Time `json:"publishAt"`
+	PrivacyStatus *string    `json:"privacyStatus" binding:"omitempty,oneof=private unlisted public"`
+}
+
+// UpdateQueueStatusRequest represents the queue status transition request body.
+// Publishing results (uploading, done, uploadedUrl) are only written by the upload scheduler.
+type UpdateQueueStatusRequest struct {
+	Status       string  `json:"status" binding:"required"`
+	ErrorMessage *string `json:"errorMessage"`
+}
+
+// ListQueue handles GET /api/queue?status=xxx
+func (h *QueueHandler) ListQueue(c *gin.Context) {
+	user, _ := CurrentUser(c)
+	status := c.Query("status")
+
+	if status != "" && !models.IsValidQueueStatus(status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+
+	items, err := h.store.Queue.List(c.Request.Context(), user.ID, status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch queue", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+// GetQueueItem handles GET /api/queue/:id
+func (h *QueueHandler) GetQueueItem(c *gin.Context) {
+	user, _ := CurrentUser(c)
+
+	item, err := h.store.Queue.Get(c.Request.Context(), user.ID, c.Param("id"))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Queue item not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+// CreateQueueItem handles POST /api/queue
+func (h *QueueHandler) CreateQueueItem(c *gin.Context) {
+	var req CreateQueueItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The scheduler downloads the file, so it must come from our storage
+	if req.FileURL != nil && *req.FileURL != "" {
+		if err := h.files.CheckURL(*req.FileURL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "fileUrl must point to an uploaded file"})
+			return
+		}
+	}
+
+	user, _ := CurrentUser(c)
+
+	// An empty privacy status falls back to the user's default visibility from user_settings
+	item := &models.UploadQueueItem{
+		ID:            utils.GenerateID(),
+		UserID:        user.ID,
+		Title:         req.Title,
+		Description:   req.Description,
+		Source:        req.Source,
+		Platform:      req.Platform,
+		FileURL:       req.FileURL,
+		FileName:      req.FileName,
+		ScheduledAt:   req.ScheduledAt,
+		PublishAt:     req.PublishAt,
+		PrivacyStatus: req.PrivacyStatus,
+	}
+	if err := h.store.Queue.Create(c.Request.Context(), item); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create queue item"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, item)
+}
+
+// UpdateQueueItem handles PUT /api/queue/:id
+// Only items that are not uploading or done can be edited.
+func (h *QueueHandler) UpdateQueueItem(c *gin.Context) {
+	var req UpdateQueueItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, _ := CurrentUser(c)
+	ctx := c.Request.Context()
+
+	current, err := h.store.Queue.Get(ctx, user.ID, c.Param("id"))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Queue item not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	item, err := h.store.Queue.Update(ctx, user.ID, current.ID, store.QueueItemUpdate{
+		Title:         req.Title,
+		Description:   req.Description,
+		Platform:      req.Platform,
+		ScheduledAt:   req.ScheduledAt,
+		PublishAt:     req.PublishAt,
+		PrivacyStatus: req.PrivacyStatus,
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Queue item can no longer be edited", "status": current.Status})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update queue item"})
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+// UpdateQueueStatus handles PUT /api/queue/:id/status
+// Users can retry a failed item (error -> ready) or cancel a pending one (ready -> error).
+func (h *QueueHandler) UpdateQueueStatus(c *gin.Context) {
+	var req UpdateQueueStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !models.IsValidQueueStatus(req.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+
+	user, _ := CurrentUser(c)
+	ctx := c.Request.Context()
+
+	current, err := h.store.Queue.Get(ctx, user.ID, c.Param("id"))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Queue item not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	invalidTransition := gin.H{"error": "Invalid status transition", "from": current.Status, "to": req.Status}
+	if !models.CanClientTransitionQueueStatus(current.Status, req.Status) {
+		c.JSON(http.StatusConflict, invalidTransition)
+		return
+	}
+
+	errorMessage := req.ErrorMessage
+	if req.Status == models.QueueStatusError && errorMessage == nil {
+		cancelled := "Cancelled by user"
+		errorMessage = &cancelled
+	}
+
+	// SetStatus only applies while the item is still in the status checked above, so a concurrent
+	// change (e.g. the scheduler claiming it) turns into a conflict
+	item, err := h.store.Queue.SetStatus(ctx, user.ID, current.ID, current.Status, req.Status, errorMessage)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusConflict, invalidTransition)
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+// DeleteQueueItem handles DELETE /api/queue/:id
+func (h *QueueHandler) DeleteQueueItem(c *gin.Context) {
+	user, _ := CurrentUser(c)
+
+	err := h.store.Queue.Delete(c.Request.Context(), user.ID, c.Param("id"))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Queue item not found or currently uploading"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete queue item"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Queue item deleted"})
+}
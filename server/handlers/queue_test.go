@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"viral-cuts-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// createQueueItem adds a ready item for the session's user and returns its ID
+func (s *testServer) createQueueItem(session string, body gin.H) string {
+	s.t.Helper()
+	item := s.expect(s.do("POST", "/api/queue", body, session), http.StatusCreated)
+	if item["status"] != models.QueueStatusReady {
+		s.t.Fatalf("created item = %v, want ready", item)
+	}
+	return item["id"].(string)
+}
+
+func TestQueueStatusTransitions(t *testing.T) {
+	s := newTestServer(t)
+	session := s.signUp("Ana Souza", "ana@example.com")
+	id := s.createQueueItem(session, gin.H{"title": "Corte 1"})
+	path := "/api/queue/" + id + "/status"
+
+	// Cancelling moves a ready item to error with a default message
+	item := s.expect(s.do("PUT", path, gin.H{"status": "error"}, session), http.StatusOK)
+	if item["status"] != models.QueueStatusError || item["errorMessage"] != "Cancelled by user" {
+		t.Errorf("cancelled item = %v", item)
+	}
+
+	// Retrying moves it back to ready and drops the message
+	item = s.expect(s.do("PUT", path, gin.H{"status": "ready"}, session), http.StatusOK)
+	if item["status"] != models.QueueStatusReady || item["errorMessage"] != nil || item["attempts"] != float64(0) {
+		t.Errorf("retried item = %v", item)
+	}
+
+	// Publishing states belong to the scheduler
+	for _, status := range []string{models.QueueStatusUploading, models.QueueStatusDone, models.QueueStatusReady} {
+		body := s.expect(s.do("PUT", path, gin.H{"status": status}, session), http.StatusConflict)
+		if body["from"] != models.QueueStatusReady || body["to"] != status {
+			t.Errorf("ready -> %s = %v", status, body)
+		}
+	}
+	s.expect(s.do("PUT", path, gin.H{"status": "published"}, session), http.StatusBadRequest)
+}
+
+func TestQueueItemWhileUploading(t *testing.T) {
+	s := newTestServer(t)
+	session := s.signUp("Ana Souza", "ana@example.com")
+	id := s.createQueueItem(session, gin.H{"title": "Corte 1"})
+
+	// The scheduler claims the item
+	userID := s.user("ana@example.com").ID
+	if _, err := s.store.Queue.SetStatus(context.Background(), userID, id, models.QueueStatusReady, models.QueueStatusUploading, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	s.expect(s.do("PUT", "/api/queue/"+id+"/status", gin.H{"status": "error"}, session), http.StatusConflict)
+	body := s.expect(s.do("PUT", "/api/queue/"+id, gin.H{"title": "Corte 2"}, session), http.StatusConflict)
+	if body["status"] != models.QueueStatusUploading {
+		t.Errorf("edit response = %v", body)
+	}
+	s.expect(s.do("DELETE", "/api/queue/"+id, nil, session), http.StatusNotFound)
+
+	item := s.expect(s.do("GET", "/api/queue/"+id, nil, session), http.StatusOK)
+	if item["title"] != "Corte 1" || item["status"] != models.QueueStatusUploading {
+		t.Errorf("item = %v", item)
+	}
+}
+
+func TestQueueCRUD(t *testing.T) {
+	s := newTestServer(t)
+	session := s.signUp("Ana Souza", "ana@example.com")
+	first := s.createQueueItem(session, gin.H{"title": "Corte 1"})
+	second := s.createQueueItem(session, gin.H{"title": "Corte 2", "privacyStatus": "public"})
+
+	item := s.expect(s.do("GET", "/api/queue/"+first, nil, session), http.StatusOK)
+	if item["privacyStatus"] != "private" {
+		t.Errorf("default privacy = %v, want private", item["privacyStatus"])
+	}
+	item = s.expect(s.do("PUT", "/api/queue/"+second, gin.H{"title": "Corte 2b", "privacyStatus": "unlisted"}, session), http.StatusOK)
+	if item["title"] != "Corte 2b" || item["privacyStatus"] != "unlisted" {
+		t.Errorf("updated item = %v", item)
+	}
+	s.expect(s.do("PUT", "/api/queue/"+second, gin.H{"privacyStatus": "secret"}, session), http.StatusBadRequest)
+
+	s.expect(s.do("PUT", "/api/queue/"+second+"/status", gin.H{"status": "error"}, session), http.StatusOK)
+	list := s.expect(s.do("GET", "/api/queue?status=ready", nil, session), http.StatusOK)
+	if items := list["items"].([]any); len(items) != 1 || items[0].(map[string]any)["id"] != first {
+		t.Errorf("ready items = %v", items)
+	}
+	list = s.expect(s.do("GET", "/api/queue", nil, session), http.StatusOK)
+	if items := list["items"].([]any); len(items) != 2 || items[0].(map[string]any)["id"] != first {
+		t.Errorf("items = %v, want both oldest first", items)
+	}
+	s.expect(s.do("GET", "/api/queue?status=nope", nil, session), http.StatusBadRequest)
+
+	s.expect(s.do("DELETE", "/api/queue/"+first, nil, session), http.StatusOK)
+	s.expect(s.do("GET", "/api/queue/"+first, nil, session), http.StatusNotFound)
+}
+
+func TestQueueIsScopedToUser(t *testing.T) {
+	s := newTestServer(t)
+	owner := s.signUp("Ana Souza", "ana@example.com")
+	other := s.signUp("Bruno Lima", "bruno@example.com")
+	id := s.createQueueItem(owner, gin.H{"title": "Corte 1"})
+
+	s.expect(s.do("GET", "/api/queue/"+id, nil, other), http.StatusNotFound)
+	s.expect(s.do("PUT", "/api/queue/"+id, gin.H{"title": "Hijacked"}, other), http.StatusNotFound)
+	s.expect(s.do("PUT", "/api/queue/"+id+"/status", gin.H{"status": "error"}, other), http.StatusNotFound)
+	s.expect(s.do("DELETE", "/api/queue/"+id, nil, other), http.StatusNotFound)
+	if list := s.expect(s.do("GET", "/api/queue", nil, other), http.StatusOK); len(list["items"].([]any)) != 0 {
+		t.Errorf("other user's queue = %v", list)
+	}
+	s.expect(s.do("GET", "/api/queue", nil, ""), http.StatusUnauthorized)
+
+	item := s.expect(s.do("GET", "/api/queue/"+id, nil, owner), http.StatusOK)
+	if item["title"] != "Corte 1" || item["status"] != models.QueueStatusReady {
+		t.Errorf("item = %v", item)
+	}
+}
+
+func TestQueueItemFileURLMustBeInStorage(t *testing.T) {
+	s := newTestServer(t)
+	session := s.signUp("Ana Souza", "ana@example.com")
+
+	uploaded := testStorageOrigin + "/storage/v1/object/videos/ana/corte.mp4"
+	id := s.createQueueItem(session, gin.H{"title": "Corte 1", "fileUrl": uploaded})
+	if item := s.expect(s.do("GET", "/api/queue/"+id, nil, session), http.StatusOK); item["fileUrl"] != uploaded {
+		t.Errorf("fileUrl = %v, want %s", item["fileUrl"], uploaded)
+	}
+
+	for _, fileURL := range []string{
+		"https://attacker.example.com/corte.mp4",
+		"https://project.supabase.co.attacker.example.com/corte.mp4",
+		"http://project.supabase.co/storage/v1/object/videos/corte.mp4",
+		"http://169.254.169.254/latest/meta-data/",
+		"file:///etc/passwd",
+	} {
+		s.expect(s.do("POST", "/api/queue", gin.H{"title": "Corte 2", "fileUrl": fileURL}, session), http.StatusBadRequest)
+	}
+	if list := s.expect(s.do("GET", "/api/queue", nil, session), http.StatusOK); len(list["items"].([]any)) != 1 {
+		t.Errorf("items = %v, want only the uploaded file", list["items"])
+	}
+}
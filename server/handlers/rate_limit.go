@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"viral-cuts-server/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxPeekedBody bounds how much of a request body is buffered to find the email
+const maxPeekedBody = 64 << 10
+
+type RateLimitMiddleware struct {
+	limiter *ratelimit.Limiter
+}
+
+func NewRateLimitMiddleware(limiter *ratelimit.Limiter) *RateLimitMiddleware {
+	return &RateLimitMiddleware{limiter: limiter}
+}
+
+// Limit throttles the named endpoint per client IP and per the email in the JSON body.
+// Limiter errors let the request through: an outage of the backend shouldn't lock everyone out.
+func (m *RateLimitMiddleware) Limit(name string, perIP, perEmail ratelimit.Rule) gin.HandlerFunc {
+	return m.limit(name, perIP, perEmail, func(c *gin.Context) string {
+		if email := requestEmail(c); email != "" {
+			return "email:" + email
+		}
+		return ""
+	})
+}
+
+// LimitUser throttles the named endpoint per client IP and per session user.
+// It must run after RequireSession.
+func (m *RateLimitMiddleware) LimitUser(name string, perIP, perUser ratelimit.Rule) gin.HandlerFunc {
+	return m.limit(name, perIP, perUser, func(c *gin.Context) string {
+		if user, ok := CurrentUser(c); ok {
+			return "user:" + user.ID
+		}
+		return ""
+	})
+}
+
+// limit checks perIP and then, when subject returns a key, perSubject
+func (m *RateLimitMiddleware) limit(name string, perIP, perSubject ratelimit.Rule, subject func(c *gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		wait, err := m.limiter.Allow(ctx, name+":ip:"+c.ClientIP(), perIP)
+		if err == nil && wait == 0 {
+			if key := subject(c); key != "" {
+				wait, err = m.limiter.Allow(ctx, name+":"+key, perSubject)
+			}
+		}
+		if err != nil {
+			fmt.Printf("Rate limit check for %s failed: %v\n", name, err)
+		} else if wait > 0 {
+			tooManyRequests(c, wait)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Lockout locks an email out of the named endpoint after repeated 401 responses, doubling the lock
+// with each further failure. Only a response that issued a session clears the failures: passing the
+// password step of a two-factor sign-in must not reset the lock.
+func (m *RateLimitMiddleware) Lockout(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		email := requestEmail(c)
+		if email == "" {
+			c.Next()
+			return
+		}
+		key := name + ":email:" + email
+
+		wait, err := m.limiter.LockedFor(ctx, key)
+		if err != nil {
+			fmt.Printf("Lockout check for %s failed: %v\n", name, err)
+		} else if wait > 0 {
+			tooManyRequests(c, wait)
+			return
+		}
+
+		c.Next()
+
+		switch {
+		case c.Writer.Status() == http.StatusUnauthorized:
+			err = m.limiter.Fail(ctx, key)
+		case c.GetBool(sessionIssuedKey):
+			err = m.limiter.Succeed(ctx, key)
+		}
+		if err != nil {
+			fmt.Printf("Failed to record %s attempt: %v\n", name, err)
+		}
+	}
+}
+
+func tooManyRequests(c *gin.Context, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"error":      "Too many requests, try again later",
+		"retryAfter": seconds,
+	})
+}
+
+// requestEmail returns the normalised "email" field of a JSON body, leaving the body readable
+// for the handler. The result is cached on the context for the next middleware.
+func requestEmail(c *gin.Context) string {
+	const key = "ratelimit.email"
+	if email, ok := c.Get(key); ok {
+		return email.(string)
+	}
+
+	var email string
+	if c.Request.Body != nil {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPeekedBody))
+		if err == nil {
+			var req struct {
+				Email string `json:"email"`
+			}
+			if json.Unmarshal(body, &req) == nil {
+				email = strings.ToLower(strings.TrimSpace(req.Email))
+			}
+		}
+		c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+	}
+
+	c.Set(key, email)
+	return email
+}
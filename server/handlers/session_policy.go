@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// SessionPolicy controls how long sessions live.
+// A session expires after IdleTimeout without use; using it past half of that slides the expiry
+// forward, but never beyond MaxLifetime after sign-in.
+type SessionPolicy struct {
+	IdleTimeout      time.Duration
+	MaxLifetime      time.Duration
+	ActivityInterval time.Duration // last activity (updated_at) is written at most this often
+}
+
+// DefaultSessionPolicy keeps active users signed in for up to 90 days
+func DefaultSessionPolicy() SessionPolicy {
+	return SessionPolicy{
+		IdleTimeout:      30 * 24 * time.Hour,
+		MaxLifetime:      90 * 24 * time.Hour,
+		ActivityInterval: 5 * time.Minute,
+	}
+}
+
+// SessionPolicyFromEnv reads SESSION_IDLE_TIMEOUT, SESSION_MAX_LIFETIME and
+// SESSION_ACTIVITY_INTERVAL (Go durations such as "720h") over the defaults
+func SessionPolicyFromEnv() (SessionPolicy, error) {
+	policy := DefaultSessionPolicy()
+	for key, target := range map[string]*time.Duration{
+		"SESSION_IDLE_TIMEOUT":      &policy.IdleTimeout,
+		"SESSION_MAX_LIFETIME":      &policy.MaxLifetime,
+		"SESSION_ACTIVITY_INTERVAL": &policy.ActivityInterval,
+	} {
+		value := os.Getenv(key)
+		if value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration <= 0 {
+			return policy, fmt.Errorf("invalid %s %q", key, value)
+		}
+		*target = duration
+	}
+	if policy.MaxLifetime < policy.IdleTimeout {
+		return policy, fmt.Errorf("SESSION_MAX_LIFETIME must not be shorter than SESSION_IDLE_TIMEOUT")
+	}
+	return policy, nil
+}
+
+// expiry returns the expiry of a session created at createdAt and used at now
+func (p SessionPolicy) expiry(createdAt, now time.Time) time.Time {
+	expiresAt := now.Add(p.IdleTimeout)
+	if limit := createdAt.Add(p.MaxLifetime); expiresAt.After(limit) {
+		return limit
+	}
+	return expiresAt
+}
+
+// needsRenewal reports whether a session used at now should get a new expiry
+func (p SessionPolicy) needsRenewal(createdAt, expiresAt, now time.Time) bool {
+	return expiresAt.Sub(now) < p.IdleTimeout/2 && p.expiry(createdAt, now).After(expiresAt)
+}
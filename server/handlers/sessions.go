@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	"viral-cuts-server/store"
+	"viral-cuts-server/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SessionsHandler struct {
+	sessions store.SessionStore
+}
+
+func NewSessionsHandler(sessions store.SessionStore) *SessionsHandler {
+	return &SessionsHandler{sessions: sessions}
+}
+
+// SessionResponse describes a session without its token
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	Browser    string    `json:"browser"`
+	OS         string    `json:"os"`
+	Device     string    `json:"device"`
+	IPAddress  string    `json:"ipAddress,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	Current    bool      `json:"current"`
+}
+
+// ListSessions handles GET /api/auth/sessions
+func (h *SessionsHandler) ListSessions(c *gin.Context) {
+	user, _ := CurrentUser(c)
+	current, _ := CurrentSession(c)
+
+	sessions, err := h.sessions.ListByUser(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	list := []SessionResponse{}
+	for _, session := range sessions {
+		userAgent := ""
+		if session.UserAgent != nil {
+			userAgent = *session.UserAgent
+		}
+		info := utils.ParseUserAgent(userAgent)
+
+		item := SessionResponse{
+			ID:         session.ID,
+			Browser:    info.Browser,
+			OS:         info.OS,
+			Device:     info.Device,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.UpdatedAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.ID == current.ID,
+		}
+		if session.IPAddress != nil {
+			item.IPAddress = *session.IPAddress
+		}
+		list = append(list, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": list})
+}
+
+// RevokeSession handles DELETE /api/auth/sessions/:id
+func (h *SessionsHandler) RevokeSession(c *gin.Context) {
+	user, _ := CurrentUser(c)
+	current, _ := CurrentSession(c)
+	id := c.Param("id")
+
+	err := h.sessions.Delete(c.Request.Context(), user.ID, id)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	// Revoking the current session is a sign-out
+	if id == current.ID {
+		clearSessionCookie(c)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// RevokeOtherSessions handles POST /api/auth/sessions/revoke-others
+func (h *SessionsHandler) RevokeOtherSessions(c *gin.Context) {
+	user, _ := CurrentUser(c)
+	current, _ := CurrentSession(c)
+
+	revoked, err := h.sessions.DeleteOthers(c.Request.Context(), user.ID, current.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Other sessions revoked", "revoked": revoked})
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"viral-cuts-server/oauth"
+	"viral-cuts-server/store"
+	"viral-cuts-server/tiktok"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// tiktokStatePrefix namespaces OAuth state values in the verification table
+const tiktokStatePrefix = "tiktok-connect:"
+
+type TikTokHandler struct {
+	db            *pgxpool.Pool
+	verifications store.VerificationStore
+	config        *oauth.Config
+	tokens        *oauth.TokenStore
+	client        *tiktok.Client
+}
+
+func NewTikTokHandler(db *pgxpool.Pool, verifications store.VerificationStore, config *oauth.Config, tokens *oauth.TokenStore, client *tiktok.Client) *TikTokHandler {
+	return &TikTokHandler{db: db, verifications: verifications, config: config, tokens: tokens, client: client}
+}
+
+// Connect handles GET /api/tiktok/connect and redirects to TikTok's consent screen
+func (h *TikTokHandler) Connect(c *gin.Context) {
+	user, _ := CurrentUser(c)
+
+	state, err := createOAuthState(c.Request.Context(), h.verifications, tiktokStatePrefix, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start TikTok connection"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, h.config.AuthCodeURL(state, nil))
+}
+
+// Callback handles GET /api/tiktok/callback?code=xxx&state=xxx
+func (h *TikTokHandler) Callback(c *gin.Context) {
+	if oauthErr := c.Query("error"); oauthErr != "" {
+		h.redirectToApp(c, "error", oauthErr)
+		return
+	}
+
+	code := c.Query("code")
+	state := c.Query("state")
+	if code == "" || state == "" {
+		h.redirectToApp(c, "error", "missing_code")
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	userID, err := consumeOAuthState(ctx, h.verifications, tiktokStatePrefix, state)
+	if errors.Is(err, errInvalidOAuthState) {
+		h.redirectToApp(c, "error", "invalid_state")
+		return
+	} else if err != nil {
+		fmt.Printf("Error consuming TikTok OAuth state: %v\n", err)
+		h.redirectToApp(c, "error", "server_error")
+		return
+	}
+
+	token, err := h.config.Exchange(ctx, code)
+	if err != nil {
+		fmt.Printf("Error exchanging TikTok authorization code: %v\n", err)
+		h.redirectToApp(c, "error", "exchange_failed")
+		return
+	}
+	if token.OpenID == "" {
+		h.redirectToApp(c, "error", "missing_open_id")
+		return
+	}
+
+	if err := h.tokens.Save(ctx, userID, token.OpenID, token); err != nil {
+		fmt.Printf("Error saving TikTok tokens: %v\n", err)
+		h.redirectToApp(c, "error", "server_error")
+		return
+	}
+
+	h.redirectToApp(c, "connected", "")
+}
+
+// Status handles GET /api/tiktok/status
+func (h *TikTokHandler) Status(c *gin.Context) {
+	user, _ := CurrentUser(c)
+
+	account, err := h.tokens.Account(c.Request.Context(), user.ID)
+	if errors.Is(err, tiktok.ErrNotConnected) {
+		c.JSON(http.StatusOK, gin.H{"connected": false})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	response := gin.H{
+		"connected":   true,
+		"openId":      account.AccountID,
+		"connectedAt": account.CreatedAt,
+	}
+	if info, err := h.client.UserInfo(c.Request.Context(), user.ID); err == nil {
+		response["username"] = info.Username
+		response["displayName"] = info.DisplayName
+		response["avatarUrl"] = info.AvatarURL
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Disconnect handles DELETE /api/tiktok
+func (h *TikTokHandler) Disconnect(c *gin.Context) {
+	user, _ := CurrentUser(c)
+
+	if err := h.tokens.Disconnect(c.Request.Context(), user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disconnect TikTok"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "TikTok disconnected"})
+}
+
+func (h *TikTokHandler) redirectToApp(c *gin.Context, status, reason string) {
+	params := url.Values{"tiktok": {status}}
+	if reason != "" {
+		params.Set("reason", reason)
+	}
+	c.Redirect(http.StatusFound, appURL()+"/dashboard?"+params.Encode())
+}
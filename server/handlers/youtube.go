@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+	"viral-cuts-server/oauth"
+	"viral-cuts-server/store"
+	"viral-cuts-server/youtube"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// youtubeStatePrefix namespaces OAuth state values in the verification table
+const youtubeStatePrefix = "youtube-connect:"
+
+type YouTubeHandler struct {
+	db            *pgxpool.Pool
+	verifications store.VerificationStore
+	config        *oauth.Config
+	tokens        *oauth.TokenStore
+	client        *youtube.Client
+	quota         *youtube.QuotaTracker
+}
+
+func NewYouTubeHandler(db *pgxpool.Pool, verifications store.VerificationStore, config *oauth.Config, tokens *oauth.TokenStore, client *youtube.Client, quota *youtube.QuotaTracker) *YouTubeHandler {
+	return &YouTubeHandler{db: db, verifications: verifications, config: config, tokens: tokens, client: client, quota: quota}
+}
+
+// Connect handles GET /api/youtube/connect and redirects to Google's consent screen
+func (h *YouTubeHandler) Connect(c *gin.Context) {
+	user, _ := CurrentUser(c)
+
+	state, err := createOAuthState(c.Request.Context(), h.verifications, youtubeStatePrefix, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start YouTube connection"})
+		return
+	}
+
+	// offline + consent guarantees a refresh token even on reconnect
+	c.Redirect(http.StatusFound, h.config.AuthCodeURL(state, map[string]string{
+		"access_type":            "offline",
+		"prompt":                 "consent",
+		"include_granted_scopes": "true",
+	}))
+}
+
+// Callback handles GET /api/youtube/callback?code=xxx&state=xxx
+func (h *YouTubeHandler) Callback(c *gin.Context) {
+	if oauthErr := c.Query("error"); oauthErr != "" {
+		h.redirectToApp(c, "error", oauthErr)
+		return
+	}
+
+	code := c.Query("code")
+	state := c.Query("state")
+	if code == "" || state == "" {
+		h.redirectToApp(c, "error", "missing_code")
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	userID, err := consumeOAuthState(ctx, h.verifications, youtubeStatePrefix, state)
+	if errors.Is(err, errInvalidOAuthState) {
+		h.redirectToApp(c, "error", "invalid_state")
+		return
+	} else if err != nil {
+		fmt.Printf("Error consuming YouTube OAuth state: %v\n", err)
+		h.redirectToApp(c, "error", "server_error")
+		return
+	}
+
+	token, err := h.config.Exchange(ctx, code)
+	if err != nil {
+		fmt.Printf("Error exchanging YouTube authorization code: %v\n", err)
+		h.redirectToApp(c, "error", "exchange_failed")
+		return
+	}
+
+	// The ID token comes straight from Google's token endpoint, so its subject can be trusted
+	var claims struct {
+		Subject string `json:"sub"`
+	}
+	if token.IDToken == "" || oauth.UnverifiedClaims(token.IDToken, &claims) != nil || claims.Subject == "" {
+		h.redirectToApp(c, "error", "missing_id_token")
+		return
+	}
+
+	if err := h.tokens.Save(ctx, userID, claims.Subject, token); err != nil {
+		fmt.Printf("Error saving YouTube tokens: %v\n", err)
+		h.redirectToApp(c, "error", "server_error")
+		return
+	}
+
+	h.redirectToApp(c, "connected", "")
+}
+
+// Status handles GET /api/youtube/status
+func (h *YouTubeHandler) Status(c *gin.Context) {
+	user, _ := CurrentUser(c)
+
+	account, err := h.tokens.Account(c.Request.Context(), user.ID)
+	if errors.Is(err, youtube.ErrNotConnected) {
+		c.JSON(http.StatusOK, gin.H{"connected": false})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"connected":   true,
+		"accountId":   account.AccountID,
+		"connectedAt": account.CreatedAt,
+	})
+}
+
+// AccessToken handles GET /api/youtube/token and returns a fresh access token
+func (h *YouTubeHandler) AccessToken(c *gin.Context) {
+	user, _ := CurrentUser(c)
+
+	token, err := h.tokens.AccessToken(c.Request.Context(), user.ID)
+	if errors.Is(err, youtube.ErrNotConnected) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "YouTube account not connected"})
+		return
+	} else if err != nil {
+		fmt.Printf("Error getting YouTube access token: %v\n", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to refresh YouTube token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"accessToken": token})
+}
+
+// Disconnect handles DELETE /api/youtube
+func (h *YouTubeHandler) Disconnect(c *gin.Context) {
+	user, _ := CurrentUser(c)
+
+	if err := h.tokens.Disconnect(c.Request.Context(), user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disconnect YouTube"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "YouTube disconnected"})
+}
+
+// Channel handles GET /api/youtube/channel
+func (h *YouTubeHandler) Channel(c *gin.Context) {
+	user, _ := CurrentUser(c)
+
+	channel, err := h.client.MyChannel(c.Request.Context(), user.ID)
+	if err != nil {
+		respondYouTubeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, channel)
+}
+
+// Videos handles GET /api/youtube/videos?maxResults=50 and lists the channel's uploads with statistics
+func (h *YouTubeHandler) Videos(c *gin.Context) {
+	user, _ := CurrentUser(c)
+	ctx := c.Request.Context()
+	maxResults, _ := strconv.Atoi(c.DefaultQuery("maxResults", "50"))
+
+	channel, err := h.client.MyChannel(ctx, user.ID)
+	if err != nil {
+		respondYouTubeError(c, err)
+		return
+	}
+
+	items, err := h.client.PlaylistItems(ctx, user.ID, channel.ContentDetails.RelatedPlaylists.Uploads, maxResults)
+	if err != nil {
+		respondYouTubeError(c, err)
+		return
+	}
+
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.Snippet.ResourceID.VideoID)
+	}
+
+	videos, err := h.client.Videos(ctx, user.ID, ids)
+	if err != nil {
+		respondYouTubeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"videos": videos})
+}
+
+// Search handles GET /api/youtube/search?q=xxx
+func (h *YouTubeHandler) Search(c *gin.Context) {
+	query := c.Query("q")
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query is required"})
+		return
+	}
+
+	user, _ := CurrentUser(c)
+	maxResults, _ := strconv.Atoi(c.DefaultQuery("maxResults", "5"))
+
+	results, err := h.client.Search(c.Request.Context(), user.ID, query, maxResults)
+	if err != nil {
+		respondYouTubeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// Analytics handles GET /api/youtube/analytics?days=7
+func (h *YouTubeHandler) Analytics(c *gin.Context) {
+	user, _ := CurrentUser(c)
+
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if days < 1 || days > 365 {
+		days = 7
+	}
+	end := time.Now()
+	start := end.AddDate(0, 0, -days)
+
+	report, err := h.client.DailyViews(c.Request.Context(), user.ID, start, end)
+	if err != nil {
+		respondYouTubeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// Quota handles GET /api/youtube/quota
+func (h *YouTubeHandler) Quota(c *gin.Context) {
+	user, _ := CurrentUser(c)
+
+	usage, err := h.quota.Usage(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read quota usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
+
+func respondYouTubeError(c *gin.Context, err error) {
+	var apiErr *youtube.APIError
+	switch {
+	case errors.Is(err, youtube.ErrNotConnected):
+		c.JSON(http.StatusNotFound, gin.H{"error": "YouTube account not connected"})
+	case errors.Is(err, youtube.ErrQuotaExceeded):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "YouTube quota exceeded"})
+	case errors.As(err, &apiErr):
+		c.JSON(http.StatusBadGateway, gin.H{"error": "YouTube API error", "details": apiErr.Message})
+	default:
+		fmt.Printf("YouTube request failed: %v\n", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "YouTube request failed"})
+	}
+}
+
+func (h *YouTubeHandler) redirectToApp(c *gin.Context, status, reason string) {
+	params := url.Values{"youtube": {status}}
+	if reason != "" {
+		params.Set("reason", reason)
+	}
+	c.Redirect(http.StatusFound, appURL()+"/dashboard?"+params.Encode())
+}
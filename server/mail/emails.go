@@ -0,0 +1,120 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"strings"
+	texttemplate "text/template"
+	"time"
+	"viral-cuts-server/models"
+)
+
+// Each email has <locale>/<name>.html, which fills the blocks of layout.html, and <locale>/<name>.txt,
+// which defines "subject" and the plain-text "text" part.
+//
+//go:embed templates
+var templateFiles embed.FS
+
+type emailTemplate struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+// templates holds every email by locale and name, parsed once at startup
+var templates = loadTemplates("verification", "password_reset", "email_change_confirm", "email_change_notice")
+
+func loadTemplates(names ...string) map[string]map[string]*emailTemplate {
+	loaded := map[string]map[string]*emailTemplate{}
+	for _, locale := range []string{models.LocalePTBR, models.LocaleEN} {
+		loaded[locale] = map[string]*emailTemplate{}
+		for _, name := range names {
+			loaded[locale][name] = &emailTemplate{
+				html: htmltemplate.Must(htmltemplate.ParseFS(templateFiles,
+					"templates/layout.html",
+					"templates/"+locale+"/common.html",
+					"templates/"+locale+"/"+name+".html",
+				)),
+				text: texttemplate.Must(texttemplate.ParseFS(templateFiles, "templates/"+locale+"/"+name+".txt")),
+			}
+		}
+	}
+	return loaded
+}
+
+// emailData is what the templates can reference
+type emailData struct {
+	Locale   string
+	Link     string
+	NewEmail string
+	Year     int
+}
+
+// VerificationEmail builds the email verification message with its link
+func VerificationEmail(to, locale, token string) (*Message, error) {
+	return render("verification", to, locale, emailData{
+		Link: fmt.Sprintf("%s/verify-email?token=%s", appURL(), token),
+	})
+}
+
+// PasswordResetEmail builds the password reset message with its link
+func PasswordResetEmail(to, locale, token string) (*Message, error) {
+	return render("password_reset", to, locale, emailData{
+		Link: fmt.Sprintf("%s/reset-password?token=%s", appURL(), token),
+	})
+}
+
+// EmailChangeConfirmEmail builds the message sent to a new address with the link confirming the change
+func EmailChangeConfirmEmail(to, locale, token string) (*Message, error) {
+	return render("email_change_confirm", to, locale, emailData{
+		Link:     fmt.Sprintf("%s/confirm-email-change?token=%s", appURL(), token),
+		NewEmail: to,
+	})
+}
+
+// EmailChangeNoticeEmail builds the message telling the current address about a requested change,
+// with a link cancelling it
+func EmailChangeNoticeEmail(to, locale, newEmail, token string) (*Message, error) {
+	return render("email_change_notice", to, locale, emailData{
+		Link:     fmt.Sprintf("%s/cancel-email-change?token=%s", appURL(), token),
+		NewEmail: newEmail,
+	})
+}
+
+// render fills the templates of an email in the given locale, falling back to the default locale
+func render(name, to, locale string, data emailData) (*Message, error) {
+	if !models.IsValidLocale(locale) {
+		locale = models.DefaultLocale
+	}
+	tmpl := templates[locale][name]
+	data.Locale = locale
+	data.Year = time.Now().Year()
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s subject: %w", name, err)
+	}
+	if err := tmpl.text.ExecuteTemplate(&text, "text", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s text: %w", name, err)
+	}
+	if err := tmpl.html.ExecuteTemplate(&html, "layout", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s html: %w", name, err)
+	}
+
+	return &Message{
+		From:    DefaultFrom(),
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		HTML:    html.String(),
+		Text:    text.String(),
+	}, nil
+}
+
+func appURL() string {
+	if appURL := os.Getenv("APP_URL"); appURL != "" {
+		return appURL
+	}
+	return "http://localhost:5173"
+}
@@ -0,0 +1,47 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LogMailer prints messages to stdout, or writes each one as an .eml file into Dir when it is set
+// (open them with any mail client)
+type LogMailer struct {
+	Dir string
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg *Message) error {
+	if m.Dir == "" {
+		fmt.Printf("\n========== EMAIL (DEV MODE) ==========\n")
+		fmt.Printf("From: %s\n", msg.From)
+		fmt.Printf("To: %s\n", msg.To)
+		fmt.Printf("Subject: %s\n", msg.Subject)
+		if msg.Text != "" {
+			fmt.Printf("Text:\n%s\n", msg.Text)
+		}
+		fmt.Printf("HTML:\n%s\n", msg.HTML)
+		fmt.Printf("======================================\n\n")
+		return nil
+	}
+
+	data, err := msg.MIME()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create mail directory: %w", err)
+	}
+	recipient := strings.NewReplacer("/", "_", "\\", "_", "<", "", ">", "", " ", "_").Replace(msg.To)
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405.000000000"), recipient)
+	path := filepath.Join(m.Dir, name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	fmt.Printf("Email to %s written to %s\n", msg.To, path)
+	return nil
+}
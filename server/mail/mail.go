@@ -0,0 +1,88 @@
+// Package mail delivers transactional email through a configurable transport.
+//
+// MAIL_TRANSPORT selects Resend (production), SMTP (e.g. a local Mailpit or MailHog catcher),
+// files on disk or the log. Tests use a Recorder.
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// ErrRejected marks a message the provider refused outright (bad address, unverified sender).
+// Sending it again won't help.
+var ErrRejected = errors.New("message rejected")
+
+// Message is one email. Text is optional; HTML-only clients still get a readable body.
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// Mailer sends messages
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// DefaultFrom returns MAIL_FROM, the sender of every message
+func DefaultFrom() string {
+	if from := os.Getenv("MAIL_FROM"); from != "" {
+		return from
+	}
+	return "ViralCuts <noreply@viralcuts.com>"
+}
+
+// FromEnv builds the mailer selected by MAIL_TRANSPORT (resend, smtp, file or log).
+// Without MAIL_TRANSPORT, production uses Resend and everything else logs messages.
+func FromEnv() (Mailer, error) {
+	transport := os.Getenv("MAIL_TRANSPORT")
+	if transport == "" {
+		transport = "log"
+		if os.Getenv("GO_ENV") == "production" {
+			transport = "resend"
+		}
+	}
+
+	switch transport {
+	case "resend":
+		apiKey := os.Getenv("RESEND_API_KEY")
+		if apiKey == "" {
+			return nil, fmt.Errorf("RESEND_API_KEY not set in environment")
+		}
+		return NewResendMailer(apiKey), nil
+	case "smtp":
+		port := 1025
+		if value := os.Getenv("SMTP_PORT"); value != "" {
+			var err error
+			if port, err = strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("invalid SMTP_PORT %q", value)
+			}
+		}
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			host = "localhost"
+		}
+		return &SMTPMailer{
+			Host:     host,
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		}, nil
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "tmp/mail"
+		}
+		return &LogMailer{Dir: dir}, nil
+	case "log":
+		return &LogMailer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown MAIL_TRANSPORT %q", transport)
+	}
+}
@@ -0,0 +1,354 @@
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	netmail "net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testMessage() *Message {
+	return &Message{
+		From:    "ViralCuts <noreply@viralcuts.com>",
+		To:      "ana@example.com",
+		Subject: "Verificação de email",
+		HTML:    `<p>Olá! <a href="https://app.example.com/verify-email?token=abc">Verificar</a></p>`,
+		Text:    "Olá! Verifique: https://app.example.com/verify-email?token=abc",
+	}
+}
+
+func decodeQP(t *testing.T, r io.Reader) string {
+	t.Helper()
+	body, err := io.ReadAll(quotedprintable.NewReader(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestMIMEHTMLOnly(t *testing.T) {
+	msg := testMessage()
+	msg.Text = ""
+	data, err := msg.MIME()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := netmail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if subject != msg.Subject || parsed.Header.Get("To") != msg.To {
+		t.Errorf("headers = %v", parsed.Header)
+	}
+	if !strings.HasPrefix(parsed.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("Content-Type = %q", parsed.Header.Get("Content-Type"))
+	}
+	if body := decodeQP(t, parsed.Body); body != msg.HTML {
+		t.Errorf("body = %q, want the HTML", body)
+	}
+}
+
+func TestMIMEMultipart(t *testing.T) {
+	msg := testMessage()
+	data, err := msg.MIME()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := netmail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q", parsed.Header.Get("Content-Type"))
+	}
+
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	want := []struct{ contentType, body string }{
+		{"text/plain", msg.Text},
+		{"text/html", msg.HTML},
+	}
+	for _, part := range want {
+		p, err := reader.NextRawPart()
+		if err != nil {
+			t.Fatalf("NextRawPart: %v", err)
+		}
+		if !strings.HasPrefix(p.Header.Get("Content-Type"), part.contentType) {
+			t.Errorf("part Content-Type = %q, want %s", p.Header.Get("Content-Type"), part.contentType)
+		}
+		if body := decodeQP(t, p); body != part.body {
+			t.Errorf("%s part = %q, want %q", part.contentType, body, part.body)
+		}
+	}
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Errorf("extra part: %v", err)
+	}
+}
+
+func TestResendMailer(t *testing.T) {
+	var got map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/emails" || r.Header.Get("Authorization") != "Bearer re_test" {
+			t.Errorf("unexpected request %s with Authorization %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"id":"email-1"}`))
+	}))
+	defer server.Close()
+
+	mailer := &ResendMailer{APIKey: "re_test", BaseURL: server.URL, HTTPClient: server.Client()}
+	msg := testMessage()
+	if err := mailer.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if got["to"] != msg.To || got["subject"] != msg.Subject || got["html"] != msg.HTML || got["text"] != msg.Text {
+		t.Errorf("payload = %v", got)
+	}
+}
+
+func TestResendMailerErrors(t *testing.T) {
+	cases := []struct {
+		status   int
+		rejected bool
+	}{
+		{http.StatusUnprocessableEntity, true},
+		{http.StatusForbidden, true},
+		{http.StatusTooManyRequests, false},
+		{http.StatusInternalServerError, false},
+	}
+	for _, tc := range cases {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.status)
+			w.Write([]byte(`{"message":"nope"}`))
+		}))
+		mailer := &ResendMailer{APIKey: "re_test", BaseURL: server.URL, HTTPClient: server.Client()}
+
+		err := mailer.Send(context.Background(), testMessage())
+		if err == nil || errors.Is(err, ErrRejected) != tc.rejected {
+			t.Errorf("status %d: err = %v, want rejected %v", tc.status, err, tc.rejected)
+		}
+		server.Close()
+	}
+}
+
+// fakeSMTPServer accepts one message per connection and answers RCPT TO with rcptReply
+type fakeSMTPServer struct {
+	listener  net.Listener
+	rcptReply string
+	messages  chan string
+}
+
+func newFakeSMTPServer(t *testing.T, rcptReply string) *fakeSMTPServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fakeSMTPServer{listener: listener, rcptReply: rcptReply, messages: make(chan string, 1)}
+	go server.serve()
+	t.Cleanup(func() { listener.Close() })
+	return server
+}
+
+func (s *fakeSMTPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM"):
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO"):
+			reply(s.rcptReply)
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			s.messages <- data.String()
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func (s *fakeSMTPServer) mailer() *SMTPMailer {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return &SMTPMailer{Host: "127.0.0.1", Port: addr.Port}
+}
+
+func TestSMTPMailer(t *testing.T) {
+	server := newFakeSMTPServer(t, "250 OK")
+	msg := testMessage()
+
+	if err := server.mailer().Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	data := <-server.messages
+	parsed, err := netmail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	if parsed.Header.Get("To") != msg.To || parsed.Header.Get("From") != msg.From {
+		t.Errorf("headers = %v", parsed.Header)
+	}
+}
+
+func TestSMTPMailerRejected(t *testing.T) {
+	server := newFakeSMTPServer(t, "550 No such user")
+
+	err := server.mailer().Send(context.Background(), testMessage())
+	if !errors.Is(err, ErrRejected) {
+		t.Fatalf("err = %v, want ErrRejected", err)
+	}
+}
+
+func TestSMTPMailerTemporaryFailure(t *testing.T) {
+	server := newFakeSMTPServer(t, "451 Try again later")
+
+	err := server.mailer().Send(context.Background(), testMessage())
+	if err == nil || errors.Is(err, ErrRejected) {
+		t.Fatalf("err = %v, want a retryable error", err)
+	}
+}
+
+func TestLogMailerWritesFiles(t *testing.T) {
+	dir := t.TempDir()
+	mailer := &LogMailer{Dir: dir}
+
+	if err := mailer.Send(context.Background(), testMessage()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*-ana@example.com.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("files = %v, %v", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := netmail.ReadMessage(bytes.NewReader(data)); err != nil {
+		t.Errorf("written file is not a valid message: %v", err)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	cases := []struct {
+		env  map[string]string
+		want string
+	}{
+		{map[string]string{}, "*mail.LogMailer"},
+		{map[string]string{"GO_ENV": "production", "RESEND_API_KEY": "re_test"}, "*mail.ResendMailer"},
+		{map[string]string{"GO_ENV": "production", "MAIL_TRANSPORT": "smtp"}, "*mail.SMTPMailer"},
+		{map[string]string{"MAIL_TRANSPORT": "file", "MAIL_DIR": t.TempDir()}, "*mail.LogMailer"},
+	}
+	for _, tc := range cases {
+		for _, key := range []string{"GO_ENV", "MAIL_TRANSPORT", "RESEND_API_KEY", "MAIL_DIR", "SMTP_HOST", "SMTP_PORT"} {
+			t.Setenv(key, tc.env[key])
+		}
+		mailer, err := FromEnv()
+		if err != nil {
+			t.Errorf("%v: %v", tc.env, err)
+			continue
+		}
+		if got := fmt.Sprintf("%T", mailer); got != tc.want {
+			t.Errorf("%v: mailer = %s, want %s", tc.env, got, tc.want)
+		}
+	}
+
+	t.Setenv("MAIL_TRANSPORT", "smtp")
+	t.Setenv("SMTP_PORT", "")
+	mailer, _ := FromEnv()
+	if smtp := mailer.(*SMTPMailer); smtp.Host != "localhost" || smtp.Port != 1025 {
+		t.Errorf("smtp defaults = %s:%d, want localhost:1025", smtp.Host, smtp.Port)
+	}
+
+	t.Setenv("MAIL_TRANSPORT", "resend")
+	t.Setenv("RESEND_API_KEY", "")
+	if _, err := FromEnv(); err == nil {
+		t.Error("resend without RESEND_API_KEY was accepted")
+	}
+	t.Setenv("MAIL_TRANSPORT", "pigeon")
+	if _, err := FromEnv(); err == nil {
+		t.Error("unknown transport was accepted")
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	recorder := NewRecorder()
+	var mailer Mailer = recorder
+	if _, ok := recorder.Last(); ok {
+		t.Fatal("Last on an empty recorder")
+	}
+
+	first := testMessage()
+	second := testMessage()
+	second.To = "bruno@example.com"
+	mailer.Send(context.Background(), first)
+	mailer.Send(context.Background(), second)
+
+	if sent := recorder.SentTo("ana@example.com"); len(sent) != 1 || sent[0].Subject != first.Subject {
+		t.Errorf("SentTo = %+v", sent)
+	}
+	if last, ok := recorder.Last(); !ok || last.To != "bruno@example.com" {
+		t.Errorf("Last = %+v", last)
+	}
+	// Recorded messages are copies
+	first.Subject = "changed"
+	if sent := recorder.Sent(); len(sent) != 2 || sent[0].Subject == "changed" {
+		t.Errorf("Sent = %+v", sent)
+	}
+
+	recorder.Reset()
+	if len(recorder.Sent()) != 0 {
+		t.Error("Reset kept messages")
+	}
+}
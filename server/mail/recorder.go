@@ -0,0 +1,58 @@
+package mail
+
+import (
+	"context"
+	"sync"
+)
+
+// Recorder keeps sent messages in memory so tests can assert on them
+type Recorder struct {
+	mu   sync.Mutex
+	sent []Message
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+func (r *Recorder) Send(ctx context.Context, msg *Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, *msg)
+	return nil
+}
+
+// Sent returns a copy of every message sent so far
+func (r *Recorder) Sent() []Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Message(nil), r.sent...)
+}
+
+// SentTo returns the messages sent to one address
+func (r *Recorder) SentTo(to string) []Message {
+	var messages []Message
+	for _, msg := range r.Sent() {
+		if msg.To == to {
+			messages = append(messages, msg)
+		}
+	}
+	return messages
+}
+
+// Last returns the most recent message, or false when nothing was sent
+func (r *Recorder) Last() (Message, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.sent) == 0 {
+		return Message{}, false
+	}
+	return r.sent[len(r.sent)-1], true
+}
+
+// Reset forgets the recorded messages
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = nil
+}
@@ -0,0 +1,62 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// ResendMailer sends through the Resend HTTP API
+type ResendMailer struct {
+	APIKey     string
+	BaseURL    string // RESEND_API_BASE_URL, for tests against a local fake
+	HTTPClient *http.Client
+}
+
+func NewResendMailer(apiKey string) *ResendMailer {
+	baseURL := os.Getenv("RESEND_API_BASE_URL")
+	if baseURL == "" {
+		baseURL = "https://api.resend.com"
+	}
+	return &ResendMailer{APIKey: apiKey, BaseURL: baseURL, HTTPClient: &http.Client{Timeout: 30 * time.Second}}
+}
+
+func (m *ResendMailer) Send(ctx context.Context, msg *Message) error {
+	body, err := json.Marshal(map[string]string{
+		"from":    msg.From,
+		"to":      msg.To,
+		"subject": msg.Subject,
+		"html":    msg.HTML,
+		"text":    msg.Text,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal email data: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", m.BaseURL+"/emails", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+m.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := m.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return fmt.Errorf("%w: email service returned status %d: %s", ErrRejected, resp.StatusCode, detail)
+		}
+		return fmt.Errorf("email service returned status %d: %s", resp.StatusCode, detail)
+	}
+	return nil
+}
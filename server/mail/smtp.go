@@ -0,0 +1,60 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+)
+
+// SMTPMailer sends through an SMTP server. STARTTLS is used when the server offers it; credentials
+// are only sent over TLS or to localhost, which suits local catchers such as Mailpit (port 1025).
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg *Message) error {
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender %q: %w", msg.From, err)
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient %q: %w", msg.To, err)
+	}
+	data, err := msg.MIME()
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	// net/smtp has no context support; run it aside so a cancelled request doesn't wait on the server
+	done := make(chan error, 1)
+	go func() {
+		addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+		done <- smtp.SendMail(addr, auth, from.Address, []string{to.Address}, data)
+	}()
+	select {
+	case err := <-done:
+		var reply *textproto.Error
+		if errors.As(err, &reply) && reply.Code >= 500 {
+			return fmt.Errorf("%w: %v", ErrRejected, err)
+		} else if err != nil {
+			return fmt.Errorf("failed to send email: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
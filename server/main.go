@@ -6,7 +6,21 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
+	"time"
+	"viral-cuts-server/google"
 	"viral-cuts-server/handlers"
+	"viral-cuts-server/mail"
+	"viral-cuts-server/migrations"
+	"viral-cuts-server/models"
+	"viral-cuts-server/opus"
+	"viral-cuts-server/ratelimit"
+	"viral-cuts-server/storage"
+	"viral-cuts-server/store"
+	"viral-cuts-server/tiktok"
+	"viral-cuts-server/utils"
+	"viral-cuts-server/worker"
+	"viral-cuts-server/youtube"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
@@ -54,18 +68,71 @@ func main() {
 
 	fmt.Println("Connected to Supabase PostgreSQL successfully (via pgxpool)!")
 
+	// Third-party secrets (OAuth tokens, API keys) are encrypted with ENCRYPTION_KEYS
+	keyring, err := utils.SecretsKeyring()
+	if err != nil {
+		log.Fatalf("Invalid encryption keys: %v\n", err)
+	}
+	if keyring == nil {
+		log.Println("WARNING: ENCRYPTION_KEYS is not set, third-party secrets are stored in plaintext")
+	}
+
+	// Maintenance commands run against the database and exit
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			if err := runMigrate(context.Background(), db, os.Args[2:]); err != nil {
+				log.Fatalf("Migration failed: %v\n", err)
+			}
+		case "rotate-keys":
+			if err := runRotateKeys(context.Background(), db, os.Args[2:]); err != nil {
+				log.Fatalf("Key rotation failed: %v\n", err)
+			}
+		default:
+			log.Fatalf("Unknown command %q\n", os.Args[1])
+		}
+		return
+	}
+
+	// Refuse to serve against a schema older than the handlers expect
+	// (AUTO_MIGRATE=true applies pending migrations instead, e.g. for local development)
+	migrator := migrations.NewRunner(db)
+	migrator.Logf = log.Printf
+	if os.Getenv("AUTO_MIGRATE") == "true" {
+		if _, err := migrator.Up(context.Background(), 0); err != nil {
+			log.Fatalf("Failed to apply migrations: %v\n", err)
+		}
+	}
+	if err := migrator.Check(context.Background()); err != nil {
+		log.Fatalf("Database schema check failed: %v\n", err)
+	}
+
 	// Initialize Gin router
 	r := gin.Default()
+	if os.Getenv("FLY_APP_NAME") != "" {
+		// Behind Fly's proxy X-Forwarded-For can be forged; Fly-Client-IP can't
+		r.TrustedPlatform = "Fly-Client-IP"
+	}
+	// Only take X-Forwarded-For from the listed proxies, so clients can't pick the IP rate limits key on
+	var trustedProxies []string
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		trustedProxies = strings.Split(proxies, ",")
+	}
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v\n", err)
+	}
+
+	// Frontends allowed to call the API with credentials
+	allowedOrigins := []string{
+		"http://localhost:5173",
+		"http://localhost:5174",
+		"http://localhost:3000",
+		"https://viral-cut-kappa.vercel.app",
+	}
 
 	// CORS Middleware
 	r.Use(func(c *gin.Context) {
 		origin := c.Request.Header.Get("Origin")
-		allowedOrigins := []string{
-			"http://localhost:5173",
-			"http://localhost:5174",
-			"http://localhost:3000",
-			"https://viral-cut-kappa.vercel.app",
-		}
 
 		isAllowed := false
 		for _, allowed := range allowedOrigins {
@@ -81,7 +148,7 @@ func main() {
 
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -91,31 +158,199 @@ func main() {
 		c.Next()
 	})
 
+	// Reject state-changing requests from other sites, since cookies may be SameSite=None
+	r.Use(handlers.RequireTrustedOrigin(allowedOrigins))
+
+	cookieConfig, err := handlers.CookieConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid cookie settings: %v\n", err)
+	}
+	handlers.SetCookieConfig(cookieConfig)
+
+	sessionPolicy, err := handlers.SessionPolicyFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid session settings: %v\n", err)
+	}
+
+	mailer, err := mail.FromEnv()
+	if err != nil {
+		log.Fatalf("Invalid mail settings: %v\n", err)
+	}
+
+	// Uploaded videos live in Supabase Storage; queue items may only reference files there
+	videoSource := storage.NewSource()
+	if videoSource.Origin == "" {
+		log.Println("SUPABASE_URL is not set, queue items with a fileUrl will be rejected")
+	}
+
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(db)
-	passwordResetHandler := handlers.NewPasswordResetHandler(db)
-	emailVerificationHandler := handlers.NewEmailVerificationHandler(db)
-	adminHandler := handlers.NewAdminHandler(db)
+	stores := store.NewPostgres(db)
+	authHandler := handlers.NewAuthHandler(stores, sessionPolicy)
+	passwordResetHandler := handlers.NewPasswordResetHandler(stores)
+	emailVerificationHandler := handlers.NewEmailVerificationHandler(stores)
+	emailChangeHandler := handlers.NewEmailChangeHandler(stores)
+	adminHandler := handlers.NewAdminHandler(stores)
+	mfaHandler := handlers.NewMFAHandler(stores)
+	sessionsHandler := handlers.NewSessionsHandler(stores.Sessions)
+	googleAuthHandler := handlers.NewGoogleAuthHandler(authHandler, stores, google.NewOAuthConfig(), google.NewVerifier())
+	queueHandler := handlers.NewQueueHandler(stores, videoSource)
+	youtubeOAuth := youtube.NewOAuthConfig()
+	youtubeTokens := youtube.NewTokenStore(db, youtubeOAuth)
+	youtubeQuota := youtube.NewQuotaTrackerFromEnv(db)
+	youtubeClient := youtube.NewClient(youtubeTokens, youtubeQuota)
+	youtubeHandler := handlers.NewYouTubeHandler(db, stores.Verifications, youtubeOAuth, youtubeTokens, youtubeClient, youtubeQuota)
+	tiktokOAuth := tiktok.NewOAuthConfig()
+	tiktokTokens := tiktok.NewTokenStore(db, tiktokOAuth)
+	tiktokClient := tiktok.NewClient(tiktokTokens)
+	tiktokHandler := handlers.NewTikTokHandler(db, stores.Verifications, tiktokOAuth, tiktokTokens, tiktokClient)
+	opusClient := opus.NewClient()
+	opusSyncer := opus.NewSyncer(db, opusClient)
+	opusHandler := handlers.NewOpusHandler(db, opusClient, opusSyncer, videoSource)
+
+	// Start background upload scheduler (publishers are registered per platform)
+	scheduler := worker.NewScheduler(db, worker.DefaultSchedulerConfig())
+	youtubeUploader := youtube.NewUploader(db, youtubeClient, videoSource)
+	scheduler.Register("YouTube Shorts", youtubeUploader)
+	scheduler.Register("YouTube", youtubeUploader)
+	scheduler.Register("TikTok", tiktok.NewPublisher(db, tiktokClient, videoSource))
+	if os.Getenv("DISABLE_UPLOAD_SCHEDULER") != "true" {
+		go scheduler.Start(context.Background())
+	}
+
+	// Poll Opus for processing clip projects (webhooks only shorten the wait)
+	if os.Getenv("DISABLE_OPUS_SYNCER") != "true" {
+		go opusSyncer.Start(context.Background())
+	}
+	authMiddleware := handlers.NewAuthMiddleware(stores.Sessions, sessionPolicy)
+
+	// Delete expired sessions in the background
+	if os.Getenv("DISABLE_SESSION_GC") != "true" {
+		go worker.NewSessionCollector(stores.Sessions, sessionPolicy.MaxLifetime).Start(context.Background())
+	}
+
+	// Deliver queued transactional emails (verification, password reset)
+	if os.Getenv("DISABLE_EMAIL_OUTBOX") != "true" {
+		go worker.NewEmailOutbox(stores.Outbox, mailer, worker.DefaultOutboxConfig()).Start(context.Background())
+	}
+
+	// Throttle auth endpoints (use the Postgres backend when running more than one machine)
+	var rateLimitBackend ratelimit.Backend = ratelimit.NewMemoryBackend()
+	if os.Getenv("RATE_LIMIT_BACKEND") == "postgres" {
+		rateLimitBackend = ratelimit.NewPostgresBackend(db)
+	}
+	limiter := ratelimit.New(rateLimitBackend)
+	go limiter.Start(context.Background())
+	rateLimit := handlers.NewRateLimitMiddleware(limiter)
 
 	// Auth routes
 	r.POST("/api/auth/sign-up", authHandler.SignUp)
-	r.POST("/api/auth/sign-in", authHandler.SignIn)
-	r.GET("/api/auth/session", authHandler.GetSession)
+	r.POST("/api/auth/sign-in",
+		rateLimit.Limit("sign-in", ratelimit.Rule{Limit: 30, Window: 10 * time.Minute}, ratelimit.Rule{Limit: 10, Window: 10 * time.Minute}),
+		rateLimit.Lockout("sign-in"),
+		authHandler.SignIn)
+	r.GET("/api/auth/session", authMiddleware.RequireSession(), authHandler.GetSession)
 	r.POST("/api/auth/sign-out", authHandler.SignOut)
+	r.PUT("/api/auth/locale", authMiddleware.RequireSession(), authHandler.UpdateLocale)
+	r.POST("/api/auth/change-password",
+		authMiddleware.RequireSession(),
+		rateLimit.LimitUser("change-password", ratelimit.Rule{Limit: 10, Window: 10 * time.Minute}, ratelimit.Rule{Limit: 10, Window: 10 * time.Minute}),
+		authHandler.ChangePassword)
+
+	// Session management routes
+	sessions := r.Group("/api/auth/sessions", authMiddleware.RequireSession())
+	sessions.GET("", sessionsHandler.ListSessions)
+	sessions.DELETE("/:id", sessionsHandler.RevokeSession)
+	sessions.POST("/revoke-others", sessionsHandler.RevokeOtherSessions)
+
+	// Sign in with Google (OpenID Connect)
+	r.GET("/api/auth/google", googleAuthHandler.Start)
+	r.GET("/api/auth/google/callback", googleAuthHandler.Callback)
+
+	// Two-factor authentication routes (verify is the second sign-in step and has no session yet)
+	r.POST("/api/auth/mfa/verify",
+		rateLimit.Limit("mfa-verify", ratelimit.Rule{Limit: 30, Window: 10 * time.Minute}, ratelimit.Rule{}),
+		authHandler.VerifyMFA)
+	mfaRoutes := r.Group("/api/auth/mfa", authMiddleware.RequireSession())
+	mfaRoutes.GET("", mfaHandler.Status)
+	mfaRoutes.POST("/enroll", mfaHandler.Enroll)
+	mfaRoutes.POST("/confirm", mfaHandler.Confirm)
+	mfaRoutes.POST("/recovery-codes",
+		rateLimit.LimitUser("mfa-recovery-codes", ratelimit.Rule{Limit: 30, Window: 10 * time.Minute}, ratelimit.Rule{Limit: 10, Window: 10 * time.Minute}),
+		mfaHandler.RegenerateRecoveryCodes)
+	mfaRoutes.POST("/disable",
+		rateLimit.LimitUser("mfa-disable", ratelimit.Rule{Limit: 30, Window: 10 * time.Minute}, ratelimit.Rule{Limit: 10, Window: 10 * time.Minute}),
+		mfaHandler.Disable)
 
 	// Password reset routes
-	r.POST("/api/auth/forgot-password", passwordResetHandler.ForgotPassword)
+	r.POST("/api/auth/forgot-password",
+		rateLimit.Limit("forgot-password", ratelimit.Rule{Limit: 10, Window: time.Hour}, ratelimit.Rule{Limit: 3, Window: time.Hour}),
+		passwordResetHandler.ForgotPassword)
 	r.POST("/api/auth/reset-password", passwordResetHandler.ResetPassword)
 
 	// Email verification routes
 	r.GET("/api/auth/verify-email", emailVerificationHandler.VerifyEmail)
-	r.POST("/api/auth/resend-verification", emailVerificationHandler.ResendVerification)
+	r.POST("/api/auth/resend-verification",
+		rateLimit.Limit("resend-verification", ratelimit.Rule{Limit: 10, Window: time.Hour}, ratelimit.Rule{Limit: 3, Window: time.Hour}),
+		emailVerificationHandler.ResendVerification)
+
+	// Email change routes (confirm and cancel come from emailed links, without a session)
+	r.POST("/api/auth/change-email",
+		authMiddleware.RequireSession(),
+		rateLimit.LimitUser("change-email", ratelimit.Rule{Limit: 10, Window: time.Hour}, ratelimit.Rule{Limit: 3, Window: time.Hour}),
+		emailChangeHandler.RequestChange)
+	r.POST("/api/auth/change-email/confirm", emailChangeHandler.ConfirmChange)
+	r.POST("/api/auth/change-email/cancel", emailChangeHandler.CancelChange)
 
 	// Admin routes
-	r.GET("/api/admin/users", adminHandler.GetUsers)
+	admin := r.Group("/api/admin", authMiddleware.RequireSession(), authMiddleware.RequireRole(models.RoleAdmin))
+	admin.GET("/users", adminHandler.GetUsers)
+	admin.PUT("/users/:id/role", adminHandler.UpdateUserRole)
+	admin.DELETE("/users/:id/mfa", adminHandler.ResetUserMFA)
+	admin.GET("/role-changes", adminHandler.GetRoleChanges)
+	admin.GET("/emails", adminHandler.GetOutboxEmails)
+	admin.POST("/emails/:id/retry", adminHandler.RetryOutboxEmail)
+
+	// Upload queue routes
+	queue := r.Group("/api/queue", authMiddleware.RequireSession())
+	queue.GET("", queueHandler.ListQueue)
+	queue.POST("", queueHandler.CreateQueueItem)
+	queue.GET("/:id", queueHandler.GetQueueItem)
+	queue.PUT("/:id", queueHandler.UpdateQueueItem)
+	queue.PUT("/:id/status", queueHandler.UpdateQueueStatus)
+	queue.DELETE("/:id", queueHandler.DeleteQueueItem)
+
+	// YouTube connection routes (callback is reached by Google's redirect and resolves the user from state)
+	r.GET("/api/youtube/callback", youtubeHandler.Callback)
+	yt := r.Group("/api/youtube", authMiddleware.RequireSession())
+	yt.GET("/connect", youtubeHandler.Connect)
+	yt.GET("/status", youtubeHandler.Status)
+	yt.GET("/token", youtubeHandler.AccessToken)
+	yt.DELETE("", youtubeHandler.Disconnect)
+	yt.GET("/channel", youtubeHandler.Channel)
+	yt.GET("/videos", youtubeHandler.Videos)
+	yt.GET("/search", youtubeHandler.Search)
+	yt.GET("/analytics", youtubeHandler.Analytics)
+	yt.GET("/quota", youtubeHandler.Quota)
+
+	// TikTok connection routes
+	r.GET("/api/tiktok/callback", tiktokHandler.Callback)
+	tt := r.Group("/api/tiktok", authMiddleware.RequireSession())
+	tt.GET("/connect", tiktokHandler.Connect)
+	tt.GET("/status", tiktokHandler.Status)
+	tt.DELETE("", tiktokHandler.Disconnect)
+
+	// Opus Clip routes (the webhook is authenticated by a per-job token)
+	r.POST("/api/opus/webhook/:id", opusHandler.Webhook)
+	op := r.Group("/api/opus", authMiddleware.RequireSession())
+	op.POST("/jobs", opusHandler.CreateJob)
+	op.GET("/jobs", opusHandler.ListJobs)
+	op.GET("/jobs/:id", opusHandler.GetJob)
+	op.POST("/clips/:id/enqueue", opusHandler.EnqueueClip)
+	op.GET("/api-key", opusHandler.APIKeyStatus)
+	op.PUT("/api-key", opusHandler.SaveAPIKey)
 
 	// Test user table endpoint
-	r.GET("/test-users", func(c *gin.Context) {
+	r.GET("/test-users", authMiddleware.RequireSession(), func(c *gin.Context) {
 		var count int
 		err := db.QueryRow(context.Background(), `SELECT COUNT(*) FROM "user"`).Scan(&count)
 		if err != nil {
@@ -134,7 +369,7 @@ func main() {
 	})
 
 	// Test DB endpoint
-	r.GET("/test-db", func(c *gin.Context) {
+	r.GET("/test-db", authMiddleware.RequireSession(), func(c *gin.Context) {
 		var version string
 		err := db.QueryRow(context.Background(), "SELECT version()").Scan(&version)
 		if err != nil {
@@ -0,0 +1,104 @@
+package mfa
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 seed of the RFC 6238 test vectors, "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeRFC6238Vectors(t *testing.T) {
+	// The RFC lists 8-digit codes; 6-digit codes are their last six digits
+	cases := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tc := range cases {
+		code, err := Code(rfcSecret, Step(time.Unix(tc.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code at %d: %v", tc.unix, err)
+		}
+		if code != tc.code {
+			t.Errorf("Code at %d = %s, want %s", tc.unix, code, tc.code)
+		}
+	}
+
+	lower, _ := Code(strings.ToLower(rfcSecret)+"=", 1)
+	upper, _ := Code(rfcSecret, 1)
+	if lower != upper {
+		t.Errorf("lowercase padded secret gave %s, want %s", lower, upper)
+	}
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("invalid secret was accepted")
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+
+	for offset := int64(-3); offset <= 3; offset++ {
+		code, _ := Code(rfcSecret, current+offset)
+		step, ok := Validate(rfcSecret, code, now)
+		want := offset >= -Skew && offset <= Skew
+		if ok != want {
+			t.Errorf("code of step %+d: ok = %t, want %t", offset, ok, want)
+		}
+		if ok && step != current+offset {
+			t.Errorf("code of step %+d matched step %d, want %d", offset, step, current+offset)
+		}
+	}
+}
+
+func TestValidateInput(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code, _ := Code(rfcSecret, Step(now))
+
+	if _, ok := Validate(rfcSecret, " "+code[:3]+" "+code[3:]+" ", now); !ok {
+		t.Error("code with spaces was rejected")
+	}
+	for _, bad := range []string{"", code[:5], code + "0", "abcdef"} {
+		if _, ok := Validate(rfcSecret, bad, now); ok {
+			t.Errorf("Validate(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(RecoveryCodeCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != RecoveryCodeCount {
+		t.Fatalf("got %d codes, want %d", len(codes), RecoveryCodeCount)
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("code %q is not formatted as xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q was issued twice", code)
+		}
+		seen[code] = true
+	}
+
+	hash := HashRecoveryCode("ab12c-de34f")
+	for _, typed := range []string{"AB12C-DE34F", "ab12cde34f", " ab12c de34f "} {
+		if got := HashRecoveryCode(typed); got != hash {
+			t.Errorf("HashRecoveryCode(%q) differs from the issued form", typed)
+		}
+	}
+	if HashRecoveryCode("ab12c-de340") == hash {
+		t.Error("different codes share a hash")
+	}
+}
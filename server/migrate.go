@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"viral-cuts-server/migrations"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// runMigrate implements `server migrate up [version]`, `server migrate down [steps]` and `server migrate status`
+func runMigrate(ctx context.Context, db *pgxpool.Pool, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up [version] | down [steps] | status")
+	}
+
+	runner := migrations.NewRunner(db)
+	runner.Logf = log.Printf
+
+	switch args[0] {
+	case "up":
+		target := 0
+		if len(args) > 1 {
+			version, err := strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("invalid version %q", args[1])
+			}
+			target = version
+		}
+		count, err := runner.Up(ctx, target)
+		if err != nil {
+			return err
+		}
+		log.Printf("%d migration(s) applied", count)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid step count %q", args[1])
+			}
+			steps = n
+		}
+		count, err := runner.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		log.Printf("%d migration(s) rolled back", count)
+
+	case "status":
+		statuses, err := runner.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-24s %s\n", status.Version, status.Name, applied)
+		}
+
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+	return nil
+}
@@ -0,0 +1,4 @@
+drop table if exists "verification";
+drop table if exists "account";
+drop table if exists "session";
+drop table if exists "user";
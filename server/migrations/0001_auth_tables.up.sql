@@ -0,0 +1,64 @@
+-- Better Auth tables (previously drizzle/0000_omniscient_marrow.sql).
+-- Written with "if not exists" so databases created by drizzle are adopted as-is.
+
+create table if not exists "user" (
+  id text primary key not null,
+  name text not null,
+  email text not null,
+  email_verified boolean default false not null,
+  image text,
+  created_at timestamp default now() not null,
+  updated_at timestamp default now() not null,
+  constraint user_email_unique unique(email)
+);
+
+create table if not exists "session" (
+  id text primary key not null,
+  expires_at timestamp not null,
+  token text not null,
+  created_at timestamp default now() not null,
+  updated_at timestamp default now() not null,
+  ip_address text,
+  user_agent text,
+  user_id text not null,
+  constraint session_token_unique unique(token)
+);
+
+create table if not exists "account" (
+  id text primary key not null,
+  account_id text not null,
+  provider_id text not null,
+  user_id text not null,
+  access_token text,
+  refresh_token text,
+  id_token text,
+  access_token_expires_at timestamp,
+  refresh_token_expires_at timestamp,
+  scope text,
+  password text,
+  created_at timestamp default now() not null,
+  updated_at timestamp default now() not null
+);
+
+create table if not exists "verification" (
+  id text primary key not null,
+  identifier text not null,
+  value text not null,
+  expires_at timestamp not null,
+  created_at timestamp default now(),
+  updated_at timestamp default now()
+);
+
+do $$
+begin
+  alter table "account" add constraint account_user_id_user_id_fk
+    foreign key (user_id) references "user"(id) on delete no action on update no action;
+exception when duplicate_object then null;
+end $$;
+
+do $$
+begin
+  alter table "session" add constraint session_user_id_user_id_fk
+    foreign key (user_id) references "user"(id) on delete no action on update no action;
+exception when duplicate_object then null;
+end $$;
@@ -0,0 +1,4 @@
+drop table if exists youtube_tokens;
+drop table if exists video_history;
+drop table if exists upload_queue;
+drop table if exists user_settings;
@@ -0,0 +1,101 @@
+-- Dashboard tables (previously supabase-schema.sql + supabase-migration-better-auth.sql).
+-- user_id is text and RLS stays disabled: users come from Better Auth and the server handles authorization.
+
+create table if not exists user_settings (
+  id uuid primary key default gen_random_uuid(),
+  user_id text not null,
+  channel_name text,
+  timezone text default 'America/Manaus',
+  upload_targets jsonb default '["YouTube Shorts", "TikTok"]'::jsonb,
+  daily_goal integer default 4,
+  cta_text text,
+  cta_link text,
+  opus_api_key text,
+  default_visibility text default 'public',
+  default_category text default '24',
+  default_tags text default 'shorts, viral, clips',
+  notify_upload boolean default true,
+  notify_error boolean default true,
+  notify_weekly boolean default false,
+  created_at timestamptz default now(),
+  updated_at timestamptz default now(),
+  unique(user_id)
+);
+
+create table if not exists upload_queue (
+  id uuid primary key default gen_random_uuid(),
+  user_id text not null,
+  title text not null,
+  description text,
+  source text, -- 'upload', 'scheduled', 'opus'
+  platform text, -- 'YouTube Shorts', 'TikTok'
+  status text default 'ready', -- 'ready', 'uploading', 'done', 'error'
+  file_url text, -- Supabase Storage URL
+  file_name text,
+  scheduled_at timestamptz,
+  publish_at timestamptz,
+  privacy_status text default 'private',
+  uploaded_url text, -- YouTube/TikTok URL after upload
+  error_message text,
+  created_at timestamptz default now(),
+  updated_at timestamptz default now()
+);
+
+create table if not exists video_history (
+  id uuid primary key default gen_random_uuid(),
+  user_id text not null,
+  date date not null,
+  title text not null,
+  platform text,
+  url text,
+  views integer default 0,
+  likes integer default 0,
+  created_at timestamptz default now(),
+  updated_at timestamptz default now()
+);
+
+create table if not exists youtube_tokens (
+  id uuid primary key default gen_random_uuid(),
+  user_id text not null,
+  account_id text not null,
+  account_name text,
+  account_avatar text,
+  access_token text not null,
+  refresh_token text,
+  expires_at timestamptz,
+  is_active boolean default false,
+  created_at timestamptz default now(),
+  updated_at timestamptz default now(),
+  unique(user_id, account_id)
+);
+
+-- Databases created from supabase-schema.sql still reference auth.users with uuid user ids
+alter table user_settings drop constraint if exists user_settings_user_id_fkey;
+alter table upload_queue drop constraint if exists upload_queue_user_id_fkey;
+alter table video_history drop constraint if exists video_history_user_id_fkey;
+alter table youtube_tokens drop constraint if exists youtube_tokens_user_id_fkey;
+
+alter table user_settings alter column user_id type text;
+alter table upload_queue alter column user_id type text;
+alter table video_history alter column user_id type text;
+alter table youtube_tokens alter column user_id type text;
+
+alter table user_settings disable row level security;
+alter table upload_queue disable row level security;
+alter table video_history disable row level security;
+alter table youtube_tokens disable row level security;
+
+create index if not exists idx_upload_queue_user on upload_queue(user_id);
+create index if not exists idx_upload_queue_status on upload_queue(user_id, status);
+create index if not exists idx_video_history_user_date on video_history(user_id, date desc);
+create index if not exists idx_youtube_tokens_user on youtube_tokens(user_id);
+
+-- Storage bucket for video files (only on Supabase, where the storage schema exists)
+do $$
+begin
+  if exists (select 1 from information_schema.schemata where schema_name = 'storage') then
+    insert into storage.buckets (id, name, public)
+    values ('video-uploads', 'video-uploads', false)
+    on conflict (id) do nothing;
+  end if;
+end $$;
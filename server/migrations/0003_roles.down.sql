@@ -0,0 +1,3 @@
+drop table if exists role_change;
+alter table "user" drop constraint if exists user_role_check;
+alter table "user" drop column if exists role;
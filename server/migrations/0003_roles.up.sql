@@ -0,0 +1,22 @@
+-- Role-based access control: a role on every user and an audit trail of role changes
+
+-- Role column on "user" ('user' or 'admin')
+alter table "user" add column if not exists role text not null default 'user';
+
+alter table "user" drop constraint if exists user_role_check;
+alter table "user" add constraint user_role_check check (role in ('user', 'admin'));
+
+-- Audit log of role changes
+create table if not exists role_change (
+  id text primary key,
+  user_id text not null references "user"(id) on delete cascade,
+  changed_by text references "user"(id) on delete set null,
+  old_role text not null,
+  new_role text not null,
+  created_at timestamp not null default now()
+);
+
+create index if not exists idx_role_change_user on role_change(user_id, created_at desc);
+
+-- Promote the first admin manually, e.g.
+-- update "user" set role = 'admin' where email = 'you@example.com';
@@ -0,0 +1,4 @@
+drop index if exists idx_upload_queue_due;
+alter table upload_queue drop column if exists claimed_at;
+alter table upload_queue drop column if exists next_attempt_at;
+alter table upload_queue drop column if exists attempts;
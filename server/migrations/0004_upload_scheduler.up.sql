@@ -0,0 +1,8 @@
+-- Bookkeeping columns the Go scheduler needs to claim and retry queue items
+
+alter table upload_queue add column if not exists attempts integer not null default 0;
+alter table upload_queue add column if not exists next_attempt_at timestamptz;
+alter table upload_queue add column if not exists claimed_at timestamptz;
+
+-- Due items are looked up across all users
+create index if not exists idx_upload_queue_due on upload_queue(status, scheduled_at);
@@ -0,0 +1 @@
+drop table if exists youtube_quota_usage;
@@ -0,0 +1,14 @@
+-- Daily YouTube Data API quota units spent per Google project and per user.
+-- usage_date is the quota day in America/Los_Angeles, when YouTube resets quotas.
+
+create table if not exists youtube_quota_usage (
+  project_id text not null,
+  user_id text not null references "user"(id) on delete cascade,
+  usage_date date not null,
+  units integer not null default 0,
+  calls integer not null default 0,
+  updated_at timestamptz not null default now(),
+  primary key (project_id, user_id, usage_date)
+);
+
+create index if not exists idx_youtube_quota_usage_project_date on youtube_quota_usage(project_id, usage_date);
@@ -0,0 +1,3 @@
+alter table upload_queue drop column if exists upload_size;
+alter table upload_queue drop column if exists upload_offset;
+alter table upload_queue drop column if exists upload_session_uri;
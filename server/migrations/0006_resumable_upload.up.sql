@@ -0,0 +1,5 @@
+-- Persists the resumable upload session so an interrupted upload continues after a restart
+
+alter table upload_queue add column if not exists upload_session_uri text;
+alter table upload_queue add column if not exists upload_offset bigint not null default 0;
+alter table upload_queue add column if not exists upload_size bigint;
@@ -0,0 +1 @@
+alter table upload_queue drop column if exists platform_publish_id;
@@ -0,0 +1,3 @@
+-- Keeps the TikTok publish_id so a restarted worker polls the existing post instead of posting twice
+
+alter table upload_queue add column if not exists platform_publish_id text;
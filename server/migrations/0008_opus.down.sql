@@ -0,0 +1,4 @@
+drop table if exists opus_clip;
+drop table if exists opus_job;
+alter table user_settings drop column if exists opus_min_score;
+alter table user_settings drop column if exists opus_auto_enqueue;
@@ -0,0 +1,43 @@
+-- Opus Clip projects started by the server and the clips they produced
+
+-- Auto-enqueue preferences
+alter table user_settings add column if not exists opus_auto_enqueue boolean default false;
+alter table user_settings add column if not exists opus_min_score integer default 80;
+
+-- Jobs (one per Opus project)
+create table if not exists opus_job (
+  id text primary key,
+  user_id text not null references "user"(id) on delete cascade,
+  opus_project_id text,
+  source_type text not null, -- 'url', 'upload'
+  source_url text not null,
+  status text not null default 'processing', -- 'processing', 'done', 'error'
+  error_message text,
+  auto_enqueue boolean not null default false,
+  min_score integer not null default 80,
+  platform text not null default 'YouTube Shorts',
+  webhook_token text not null,
+  last_polled_at timestamptz,
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now()
+);
+
+create index if not exists idx_opus_job_user on opus_job(user_id, created_at desc);
+create index if not exists idx_opus_job_status on opus_job(status, last_polled_at);
+
+-- Clips
+create table if not exists opus_clip (
+  id text primary key,
+  job_id text not null references opus_job(id) on delete cascade,
+  user_id text not null references "user"(id) on delete cascade,
+  opus_clip_id text not null,
+  title text not null,
+  virality_score integer not null default 0,
+  download_url text,
+  thumbnail_url text,
+  queue_item_id uuid references upload_queue(id) on delete set null,
+  created_at timestamptz not null default now(),
+  unique(job_id, opus_clip_id)
+);
+
+create index if not exists idx_opus_clip_job on opus_clip(job_id, virality_score desc);
@@ -0,0 +1,5 @@
+-- Hashed tokens can't be turned back into raw values, so outstanding links are dropped
+drop index if exists idx_verification_purpose_identifier;
+drop index if exists idx_verification_purpose_value;
+delete from verification where purpose is not null;
+alter table verification drop column if exists purpose;
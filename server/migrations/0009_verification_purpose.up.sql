@@ -0,0 +1,18 @@
+-- Scopes verification tokens to a purpose and replaces raw token values with their SHA-256 hash.
+-- Links already sent keep working: the handlers hash the presented token before looking it up.
+
+alter table verification add column if not exists purpose text;
+
+update verification
+set purpose = case
+      when identifier like 'youtube-connect:%' or identifier like 'tiktok-connect:%' then 'oauth_state'
+      when value ~ '^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$' then 'email_verify'
+      else 'password_reset'
+  end,
+  value = encode(sha256(convert_to(value, 'UTF8')), 'hex')
+where purpose is null;
+
+alter table verification alter column purpose set not null;
+
+create index if not exists idx_verification_purpose_value on verification(purpose, value);
+create index if not exists idx_verification_purpose_identifier on verification(purpose, identifier);
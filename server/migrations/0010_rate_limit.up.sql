@@ -0,0 +1,10 @@
+-- Attempt log of the Postgres rate limit backend (RATE_LIMIT_BACKEND=postgres)
+
+create table if not exists rate_limit_event (
+  id bigserial primary key,
+  key text not null,
+  at timestamptz not null default now()
+);
+
+create index if not exists idx_rate_limit_event_key on rate_limit_event(key, at);
+create index if not exists idx_rate_limit_event_at on rate_limit_event(at);
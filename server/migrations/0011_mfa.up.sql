@@ -0,0 +1,22 @@
+-- TOTP two-factor authentication: one enrollment per user and its one-time recovery codes
+
+create table if not exists user_mfa (
+  id text primary key,
+  user_id text not null unique references "user"(id) on delete cascade,
+  secret text not null,
+  enabled_at timestamptz,
+  last_used_step bigint not null default 0,
+  failed_attempts integer not null default 0,
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now()
+);
+
+create table if not exists mfa_recovery_code (
+  id text primary key,
+  user_id text not null references "user"(id) on delete cascade,
+  code_hash text not null,
+  used_at timestamptz,
+  created_at timestamptz not null default now()
+);
+
+create index if not exists idx_mfa_recovery_code_user on mfa_recovery_code(user_id);
@@ -0,0 +1 @@
+drop index if exists idx_account_google_subject;
@@ -0,0 +1,2 @@
+-- One user per Google account: concurrent first sign-ins can't create two users for the same subject
+create unique index if not exists idx_account_google_subject on "account"(account_id) where provider_id = 'google';
@@ -0,0 +1,2 @@
+drop index if exists idx_session_expires_at;
+drop index if exists idx_session_user;
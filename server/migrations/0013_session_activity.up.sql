@@ -0,0 +1,3 @@
+-- Sessions are listed per user and garbage-collected by expiry
+create index if not exists idx_session_user on "session"(user_id, updated_at desc);
+create index if not exists idx_session_expires_at on "session"(expires_at);
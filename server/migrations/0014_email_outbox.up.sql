@@ -0,0 +1,22 @@
+-- Transactional email outbox: emails are written in the transaction that issued their token
+-- and delivered by the outbox worker, which retries with backoff
+
+create table if not exists email_outbox (
+  id text primary key,
+  sender text not null,
+  recipient text not null,
+  subject text not null,
+  html text,
+  text_body text,
+  status text not null default 'pending',
+  attempts integer not null default 0,
+  next_attempt_at timestamptz not null default now(),
+  claimed_at timestamptz,
+  last_error text,
+  sent_at timestamptz,
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now()
+);
+
+-- Due emails are claimed by status and time; admins list by status
+create index if not exists idx_email_outbox_due on email_outbox(status, next_attempt_at);
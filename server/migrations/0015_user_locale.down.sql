@@ -0,0 +1,2 @@
+alter table "user" drop constraint if exists user_locale_check;
+alter table "user" drop column if exists locale;
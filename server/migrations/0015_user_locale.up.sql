@@ -0,0 +1,6 @@
+-- Language of the emails sent to a user ('pt-BR' or 'en')
+
+alter table "user" add column if not exists locale text not null default 'pt-BR';
+
+alter table "user" drop constraint if exists user_locale_check;
+alter table "user" add constraint user_locale_check check (locale in ('pt-BR', 'en'));
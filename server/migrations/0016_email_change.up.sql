@@ -0,0 +1,10 @@
+-- Pending email changes: the new address is only written to "user" and "account" once the link
+-- sent to it is confirmed. One pending change per user; a new request replaces it.
+
+create table if not exists email_change (
+  id text primary key,
+  user_id text not null unique references "user"(id) on delete cascade,
+  new_email text not null,
+  expires_at timestamptz not null,
+  created_at timestamptz not null default now()
+);
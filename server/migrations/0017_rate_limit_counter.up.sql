@@ -0,0 +1,11 @@
+-- Per-window attempt counters of the Postgres rate limit backend. Counting with an upsert keeps
+-- concurrent requests from all passing the limit; rate_limit_event now only holds sign-in failures.
+
+create table if not exists rate_limit_counter (
+  key text not null,
+  window_start timestamptz not null,
+  count integer not null,
+  primary key (key, window_start)
+);
+
+create index if not exists idx_rate_limit_counter_window on rate_limit_counter(window_start);
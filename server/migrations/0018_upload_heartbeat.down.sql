@@ -0,0 +1 @@
+alter table upload_queue drop column if exists heartbeat_at;
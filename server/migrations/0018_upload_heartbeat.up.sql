@@ -0,0 +1,4 @@
+-- claimed_at identifies the scheduler's claim on an uploading item and stays fixed until the result
+-- is recorded; heartbeat_at is refreshed while the upload runs so long uploads don't look stale.
+
+alter table upload_queue add column if not exists heartbeat_at timestamptz;
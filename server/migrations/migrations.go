@@ -0,0 +1,346 @@
+// Package migrations applies the versioned SQL files embedded in the binary.
+//
+// Files are named <version>_<name>.up.sql and <version>_<name>.down.sql. Applied versions are
+// recorded in schema_migrations. Every migration runs in its own transaction holding a
+// transaction-level advisory lock, so concurrent machines never apply the same migration twice
+// (session-level locks are unreliable behind Supabase's transaction pooler).
+package migrations
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// lockID is the pg_advisory_xact_lock key shared by every migration run
+const lockID = 7_234_910_118
+
+// Migration is one embedded schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes a migration and whether it has been applied
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// All returns the embedded migrations sorted by version
+func All() ([]Migration, error) {
+	return Load(files)
+}
+
+// Load reads the migrations in the root of fsys sorted by version
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		name := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("migration %s must end in .up.sql or .down.sql", name)
+		}
+
+		versionText, rest, ok := strings.Cut(strings.TrimSuffix(name, "."+direction+".sql"), "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s must be named <version>_<name>", name)
+		}
+		version, err := strconv.Atoi(versionText)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("migration %s has an invalid version", name)
+		}
+
+		body, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: rest}
+			byVersion[version] = m
+		} else if m.Name != rest {
+			return nil, fmt.Errorf("migration version %d is used by %s and %s", version, m.Name, rest)
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Latest returns the highest embedded version, i.e. the schema version the handlers expect
+func Latest() int {
+	migrations, err := All()
+	if err != nil {
+		return 0
+	}
+	return latest(migrations)
+}
+
+func latest(migrations []Migration) int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// Runner applies migrations to a database
+type Runner struct {
+	db *pgxpool.Pool
+
+	// Files holds the migrations; defaults to the embedded files
+	Files fs.FS
+	// Logf reports progress; defaults to no output
+	Logf func(format string, args ...any)
+}
+
+func NewRunner(db *pgxpool.Pool) *Runner {
+	return &Runner{db: db, Files: files, Logf: func(string, ...any) {}}
+}
+
+// Current returns the highest applied version, 0 on an empty database
+func (r *Runner) Current(ctx context.Context) (int, error) {
+	if err := r.ensureTable(ctx); err != nil {
+		return 0, err
+	}
+	var version int
+	err := r.db.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
+}
+
+// Status lists every embedded migration with its applied time
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	migrations, err := Load(r.Files)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := r.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(migrations))
+	for _, m := range migrations {
+		status := Status{Version: m.Version, Name: m.Name}
+		if at, ok := applied[m.Version]; ok {
+			status.AppliedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Up applies pending migrations up to and including target (0 means all) and returns how many ran
+func (r *Runner) Up(ctx context.Context, target int) (int, error) {
+	migrations, err := Load(r.Files)
+	if err != nil {
+		return 0, err
+	}
+	if err := r.ensureTable(ctx); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if target > 0 && m.Version > target {
+			break
+		}
+		ran, err := r.apply(ctx, m, true)
+		if err != nil {
+			return count, err
+		}
+		if ran {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Down rolls back the latest steps applied migrations and returns how many ran
+func (r *Runner) Down(ctx context.Context, steps int) (int, error) {
+	migrations, err := Load(r.Files)
+	if err != nil {
+		return 0, err
+	}
+	applied, err := r.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if m.Down == "" {
+			return count, fmt.Errorf("migration %d_%s cannot be rolled back", m.Version, m.Name)
+		}
+		ran, err := r.apply(ctx, m, false)
+		if err != nil {
+			return count, err
+		}
+		if ran {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Check returns an error when the database is behind the embedded migrations.
+// A newer schema is accepted so a rolling deploy can keep serving from the previous release.
+func (r *Runner) Check(ctx context.Context) error {
+	current, err := r.Current(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	migrations, err := Load(r.Files)
+	if err != nil {
+		return err
+	}
+	if latest := latest(migrations); current < latest {
+		return fmt.Errorf("schema version is %d but this build expects %d, run `migrate up`", current, latest)
+	}
+	return nil
+}
+
+// apply runs one migration under the advisory lock. It re-checks schema_migrations after taking
+// the lock and reports false when another machine already did the work.
+func (r *Runner) apply(ctx context.Context, m Migration, up bool) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, lockID); err != nil {
+		return false, fmt.Errorf("failed to take migration lock: %w", err)
+	}
+
+	var isApplied bool
+	err = tx.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`,
+		m.Version,
+	).Scan(&isApplied)
+	if err != nil {
+		return false, err
+	}
+	if isApplied == up {
+		return false, nil
+	}
+
+	script := m.Up
+	if !up {
+		script = m.Down
+	}
+	if _, err := tx.Exec(ctx, script); err != nil {
+		return false, fmt.Errorf("migration %d_%s failed: %w", m.Version, m.Name, err)
+	}
+
+	if up {
+		_, err = tx.Exec(ctx,
+			`INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, NOW())`,
+			m.Version, m.Name,
+		)
+	} else {
+		_, err = tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+
+	if up {
+		r.Logf("Applied migration %d_%s", m.Version, m.Name)
+	} else {
+		r.Logf("Rolled back migration %d_%s", m.Version, m.Name)
+	}
+	return true, nil
+}
+
+func (r *Runner) applied(ctx context.Context) (map[int]time.Time, error) {
+	if err := r.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+func (r *Runner) ensureTable(ctx context.Context) error {
+	// Taken under the lock too: concurrent CREATE TABLE IF NOT EXISTS can still collide
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("failed to take migration lock: %w", err)
+	}
+	_, err = tx.Exec(ctx,
+		`CREATE TABLE IF NOT EXISTS schema_migrations (
+		     version integer PRIMARY KEY,
+		     name text NOT NULL,
+		     applied_at timestamptz NOT NULL DEFAULT NOW()
+		 )`,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,196 @@
+package migrations
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func file(body string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(body)}
+}
+
+func TestLoadOrdersByVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0010_tenth.up.sql":    file("SELECT 10"),
+		"0002_second.up.sql":   file("SELECT 2"),
+		"0002_second.down.sql": file("SELECT -2"),
+		"0001_first.up.sql":    file("SELECT 1"),
+	}
+
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	var versions []int
+	for _, m := range migrations {
+		versions = append(versions, m.Version)
+	}
+	if len(versions) != 3 || versions[0] != 1 || versions[1] != 2 || versions[2] != 10 {
+		t.Fatalf("versions = %v, want [1 2 10]", versions)
+	}
+	if migrations[1].Name != "second" || migrations[1].Up != "SELECT 2" || migrations[1].Down != "SELECT -2" {
+		t.Errorf("migration 2 = %+v", migrations[1])
+	}
+	if latest(migrations) != 10 {
+		t.Errorf("latest = %d, want 10", latest(migrations))
+	}
+}
+
+func TestLoadRejectsBadFiles(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"wrong suffix":      {"0001_first.sql": file("SELECT 1")},
+		"no name":           {"0001.up.sql": file("SELECT 1")},
+		"invalid version":   {"abc_first.up.sql": file("SELECT 1")},
+		"zero version":      {"0000_first.up.sql": file("SELECT 1")},
+		"duplicate version": {"0001_first.up.sql": file("SELECT 1"), "0001_other.up.sql": file("SELECT 1")},
+		"down without up":   {"0001_first.down.sql": file("SELECT 1")},
+	}
+	for name, fsys := range cases {
+		if _, err := Load(fsys); err == nil {
+			t.Errorf("%s: Load accepted %v", name, fsys)
+		}
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := All()
+	if err != nil {
+		t.Fatalf("All: %v", err)
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %d_%s: versions must be consecutive from 1", m.Version, m.Name)
+		}
+		if strings.TrimSpace(m.Down) == "" {
+			t.Errorf("migration %d_%s has no down file", m.Version, m.Name)
+		}
+	}
+	if Latest() != len(migrations) {
+		t.Errorf("Latest = %d, want %d", Latest(), len(migrations))
+	}
+}
+
+// testRunner connects to TEST_DATABASE_URL with a throwaway schema as search_path
+func testRunner(t *testing.T, fsys fstest.MapFS) (*Runner, *pgxpool.Pool) {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+
+	admin, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	schema := "migrations_test_" + strings.ToLower(strings.ReplaceAll(t.Name(), "/", "_"))
+	if _, err := admin.Exec(ctx, `DROP SCHEMA IF EXISTS `+schema+` CASCADE; CREATE SCHEMA `+schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec(context.Background(), `DROP SCHEMA IF EXISTS `+schema+` CASCADE`)
+		admin.Close()
+	})
+
+	config, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatalf("parse url: %v", err)
+	}
+	config.ConnConfig.RuntimeParams["search_path"] = schema
+	db, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(db.Close)
+
+	runner := NewRunner(db)
+	runner.Files = fsys
+	return runner, db
+}
+
+func appliedVersions(t *testing.T, db *pgxpool.Pool) []int {
+	t.Helper()
+	rows, err := db.Query(context.Background(), `SELECT version FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		t.Fatalf("query schema_migrations: %v", err)
+	}
+	defer rows.Close()
+	versions := []int{}
+	for rows.Next() {
+		var version int
+		rows.Scan(&version)
+		versions = append(versions, version)
+	}
+	return versions
+}
+
+func TestRunnerUpSkipsAppliedVersions(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_widgets.up.sql":   file("CREATE TABLE widgets (id integer PRIMARY KEY)"),
+		"0001_widgets.down.sql": file("DROP TABLE widgets"),
+		"0002_gadgets.up.sql":   file("CREATE TABLE gadgets (id integer PRIMARY KEY)"),
+		"0002_gadgets.down.sql": file("DROP TABLE gadgets"),
+	}
+	runner, db := testRunner(t, fsys)
+	ctx := context.Background()
+
+	if ran, err := runner.Up(ctx, 1); err != nil || ran != 1 {
+		t.Fatalf("Up(1) = %d, %v", ran, err)
+	}
+	if err := runner.Check(ctx); err == nil {
+		t.Error("Check passed with a pending migration")
+	}
+
+	// 0001 is already applied; re-running its CREATE TABLE would fail
+	if ran, err := runner.Up(ctx, 0); err != nil || ran != 1 {
+		t.Fatalf("Up(0) = %d, %v, want only 0002 to run", ran, err)
+	}
+	if ran, err := runner.Up(ctx, 0); err != nil || ran != 0 {
+		t.Fatalf("second Up = %d, %v, want nothing to run", ran, err)
+	}
+	if got := appliedVersions(t, db); len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("applied = %v, want [1 2]", got)
+	}
+	if err := runner.Check(ctx); err != nil {
+		t.Errorf("Check: %v", err)
+	}
+
+	if ran, err := runner.Down(ctx, 1); err != nil || ran != 1 {
+		t.Fatalf("Down(1) = %d, %v", ran, err)
+	}
+	if got := appliedVersions(t, db); len(got) != 1 || got[0] != 1 {
+		t.Errorf("applied after Down = %v, want [1]", got)
+	}
+}
+
+func TestRunnerFailingMigrationLeavesNoRow(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_widgets.up.sql": file("CREATE TABLE widgets (id integer PRIMARY KEY)"),
+		"0002_broken.up.sql":  file("CREATE TABLE gadgets (id integer PRIMARY KEY); SELECT * FROM missing_table"),
+		"0003_later.up.sql":   file("CREATE TABLE later (id integer PRIMARY KEY)"),
+	}
+	runner, db := testRunner(t, fsys)
+	ctx := context.Background()
+
+	ran, err := runner.Up(ctx, 0)
+	if err == nil || !strings.Contains(err.Error(), "0002_broken") {
+		t.Fatalf("err = %v, want migration 0002_broken to fail", err)
+	}
+	if ran != 1 {
+		t.Errorf("ran = %d, want 1", ran)
+	}
+	if got := appliedVersions(t, db); len(got) != 1 || got[0] != 1 {
+		t.Errorf("applied = %v, want [1]", got)
+	}
+
+	var gadgets *string
+	db.QueryRow(ctx, `SELECT to_regclass('gadgets')::text`).Scan(&gadgets)
+	if gadgets != nil {
+		t.Error("the failed migration's CREATE TABLE was not rolled back")
+	}
+}
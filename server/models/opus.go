@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+)
+
+// Opus job statuses
+const (
+	OpusJobProcessing = "processing"
+	OpusJobDone       = "done"
+	OpusJobError      = "error"
+)
+
+// OpusJob tracks an Opus Clip project started by the server
+type OpusJob struct {
+	ID            string     `json:"id" db:"id"`
+	UserID        string     `json:"userId" db:"user_id"`
+	OpusProjectID *string    `json:"opusProjectId,omitempty" db:"opus_project_id"`
+	SourceType    string     `json:"sourceType" db:"source_type"`
+	SourceURL     string     `json:"sourceUrl" db:"source_url"`
+	Status        string     `json:"status" db:"status"`
+	ErrorMessage  *string    `json:"errorMessage,omitempty" db:"error_message"`
+	AutoEnqueue   bool       `json:"autoEnqueue" db:"auto_enqueue"`
+	MinScore      int        `json:"minScore" db:"min_score"`
+	Platform      string     `json:"platform" db:"platform"`
+	WebhookToken  string     `json:"-" db:"webhook_token"`
+	LastPolledAt  *time.Time `json:"lastPolledAt,omitempty" db:"last_polled_at"`
+	CreatedAt     time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updatedAt" db:"updated_at"`
+}
+
+// OpusClip is a clip produced by an Opus job
+type OpusClip struct {
+	ID            string    `json:"id" db:"id"`
+	JobID         string    `json:"jobId" db:"job_id"`
+	UserID        string    `json:"userId" db:"user_id"`
+	OpusClipID    string    `json:"opusClipId" db:"opus_clip_id"`
+	Title         string    `json:"title" db:"title"`
+	ViralityScore int       `json:"viralityScore" db:"virality_score"`
+	DownloadURL   *string   `json:"downloadUrl,omitempty" db:"download_url"`
+	ThumbnailURL  *string   `json:"thumbnailUrl,omitempty" db:"thumbnail_url"`
+	QueueItemID   *string   `json:"queueItemId,omitempty" db:"queue_item_id"`
+	CreatedAt     time.Time `json:"createdAt" db:"created_at"`
+}
+
+// OpusJobColumns lists the opus_job columns in the order ScanOpusJob expects
+const OpusJobColumns = `id, user_id, opus_project_id, source_type, source_url, status, error_message,
+	auto_enqueue, min_score, platform, webhook_token, last_polled_at, created_at, updated_at`
+
+// ScanOpusJob scans a row selected with OpusJobColumns
+func ScanOpusJob(row RowScanner) (*OpusJob, error) {
+	var job OpusJob
+	err := row.Scan(
+		&job.ID, &job.UserID, &job.OpusProjectID, &job.SourceType, &job.SourceURL, &job.Status, &job.ErrorMessage,
+		&job.AutoEnqueue, &job.MinScore, &job.Platform, &job.WebhookToken, &job.LastPolledAt, &job.CreatedAt, &job.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// OpusClipColumns lists the opus_clip columns in the order ScanOpusClip expects
+const OpusClipColumns = `id, job_id, user_id, opus_clip_id, title, virality_score, download_url, thumbnail_url,
+	queue_item_id, created_at`
+
+// ScanOpusClip scans a row selected with OpusClipColumns
+func ScanOpusClip(row RowScanner) (*OpusClip, error) {
+	var clip OpusClip
+	err := row.Scan(
+		&clip.ID, &clip.JobID, &clip.UserID, &clip.OpusClipID, &clip.Title, &clip.ViralityScore, &clip.DownloadURL,
+		&clip.ThumbnailURL, &clip.QueueItemID, &clip.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &clip, nil
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+)
+
+// Email outbox statuses
+const (
+	OutboxStatusPending = "pending"
+	OutboxStatusSending = "sending"
+	OutboxStatusSent    = "sent"
+	OutboxStatusDead    = "dead" // out of attempts or rejected; retried only by an admin
+)
+
+// IsValidOutboxStatus reports whether status is one of the known outbox statuses
+func IsValidOutboxStatus(status string) bool {
+	switch status {
+	case OutboxStatusPending, OutboxStatusSending, OutboxStatusSent, OutboxStatusDead:
+		return true
+	}
+	return false
+}
+
+// OutboxEmail is an email waiting in (or delivered from) the transactional outbox.
+// The bodies carry token links, so they are encrypted at rest, never serialised and dropped once sent.
+type OutboxEmail struct {
+	ID            string     `json:"id" db:"id"`
+	Sender        string     `json:"sender" db:"sender"`
+	Recipient     string     `json:"recipient" db:"recipient"`
+	Subject       string     `json:"subject" db:"subject"`
+	HTML          string     `json:"-" db:"html"`
+	Text          string     `json:"-" db:"text_body"`
+	Status        string     `json:"status" db:"status"`
+	Attempts      int        `json:"attempts" db:"attempts"`
+	NextAttemptAt time.Time  `json:"nextAttemptAt" db:"next_attempt_at"`
+	ClaimedAt     *time.Time `json:"claimedAt,omitempty" db:"claimed_at"`
+	LastError     *string    `json:"lastError,omitempty" db:"last_error"`
+	SentAt        *time.Time `json:"sentAt,omitempty" db:"sent_at"`
+	CreatedAt     time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updatedAt" db:"updated_at"`
+}
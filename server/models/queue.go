@@ -0,0 +1,99 @@
+package models
+
+import (
+	"time"
+)
+
+// Upload queue statuses (mirrors upload_queue.status in supabase-schema.sql)
+const (
+	QueueStatusReady     = "ready"
+	QueueStatusUploading = "uploading"
+	QueueStatusDone      = "done"
+	QueueStatusError     = "error"
+)
+
+// queueTransitions lists the statuses each status may move to.
+// ready -> uploading and uploading -> done/error belong to the upload scheduler.
+var queueTransitions = map[string][]string{
+	QueueStatusReady:     {QueueStatusUploading},
+	QueueStatusUploading: {QueueStatusDone, QueueStatusError},
+	QueueStatusError:     {QueueStatusReady},
+	QueueStatusDone:      {},
+}
+
+// clientQueueTransitions lists the transitions users may request through the API:
+// retrying a failed item (error -> ready) and cancelling a pending one (ready -> error)
+var clientQueueTransitions = map[string][]string{
+	QueueStatusReady: {QueueStatusError},
+	QueueStatusError: {QueueStatusReady},
+}
+
+// IsValidQueueStatus reports whether status is one of the known queue statuses
+func IsValidQueueStatus(status string) bool {
+	_, ok := queueTransitions[status]
+	return ok
+}
+
+// CanTransitionQueueStatus reports whether a queue item may move from one status to another
+func CanTransitionQueueStatus(from, to string) bool {
+	return hasTransition(queueTransitions, from, to)
+}
+
+// CanClientTransitionQueueStatus reports whether a user may move a queue item from one status to another
+func CanClientTransitionQueueStatus(from, to string) bool {
+	return hasTransition(clientQueueTransitions, from, to)
+}
+
+func hasTransition(transitions map[string][]string, from, to string) bool {
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// UploadQueueItem represents a video waiting to be published
+type UploadQueueItem struct {
+	ID            string     `json:"id" db:"id"`
+	UserID        string     `json:"userId" db:"user_id"`
+	Title         string     `json:"title" db:"title"`
+	Description   *string    `json:"description,omitempty" db:"description"`
+	Source        *string    `json:"source,omitempty" db:"source"`
+	Platform      *string    `json:"platform,omitempty" db:"platform"`
+	Status        string     `json:"status" db:"status"`
+	FileURL       *string    `json:"fileUrl,omitempty" db:"file_url"`
+	FileName      *string    `json:"fileName,omitempty" db:"file_name"`
+	ScheduledAt   *time.Time `json:"scheduledAt,omitempty" db:"scheduled_at"`
+	PublishAt     *time.Time `json:"publishAt,omitempty" db:"publish_at"`
+	PrivacyStatus string     `json:"privacyStatus" db:"privacy_status"`
+	UploadedURL   *string    `json:"uploadedUrl,omitempty" db:"uploaded_url"`
+	ErrorMessage  *string    `json:"errorMessage,omitempty" db:"error_message"`
+	Attempts      int        `json:"attempts" db:"attempts"`
+	NextAttemptAt *time.Time `json:"nextAttemptAt,omitempty" db:"next_attempt_at"`
+	CreatedAt     time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updatedAt" db:"updated_at"`
+}
+
+// QueueItemColumns lists the upload_queue columns in the order ScanUploadQueueItem expects
+const QueueItemColumns = `id, user_id, title, description, source, platform, status, file_url, file_name,
+	scheduled_at, publish_at, privacy_status, uploaded_url, error_message, attempts, next_attempt_at, created_at, updated_at`
+
+// RowScanner is satisfied by pgx.Row and pgx.Rows
+type RowScanner interface {
+	Scan(dest ...any) error
+}
+
+// ScanUploadQueueItem scans a row selected with QueueItemColumns
+func ScanUploadQueueItem(row RowScanner) (*UploadQueueItem, error) {
+	var item UploadQueueItem
+	err := row.Scan(
+		&item.ID, &item.UserID, &item.Title, &item.Description, &item.Source, &item.Platform, &item.Status,
+		&item.FileURL, &item.FileName, &item.ScheduledAt, &item.PublishAt, &item.PrivacyStatus,
+		&item.UploadedURL, &item.ErrorMessage, &item.Attempts, &item.NextAttemptAt, &item.CreatedAt, &item.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
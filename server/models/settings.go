@@ -0,0 +1,41 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// UserSettings represents the dashboard settings of a user (user_settings table)
+type UserSettings struct {
+	ID                string    `json:"id" db:"id"`
+	UserID            string    `json:"userId" db:"user_id"`
+	ChannelName       *string   `json:"channelName,omitempty" db:"channel_name"`
+	Timezone          string    `json:"timezone" db:"timezone"`
+	DefaultVisibility string    `json:"defaultVisibility" db:"default_visibility"`
+	DefaultCategory   string    `json:"defaultCategory" db:"default_category"`
+	DefaultTags       string    `json:"defaultTags" db:"default_tags"`
+	CreatedAt         time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt         time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+// DefaultUserSettings mirrors the column defaults in supabase-schema.sql
+func DefaultUserSettings(userID string) *UserSettings {
+	return &UserSettings{
+		UserID:            userID,
+		Timezone:          "America/Manaus",
+		DefaultVisibility: "public",
+		DefaultCategory:   "24",
+		DefaultTags:       "shorts, viral, clips",
+	}
+}
+
+// Tags splits the comma separated default_tags column
+func (s *UserSettings) Tags() []string {
+	tags := []string{}
+	for _, tag := range strings.Split(s.DefaultTags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
@@ -1,9 +1,41 @@
 package models
 
 import (
+	"strings"
 	"time"
 )
 
+// User roles
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Locales users can choose for emails
+const (
+	LocalePTBR    = "pt-BR"
+	LocaleEN      = "en"
+	DefaultLocale = LocalePTBR
+)
+
+// IsValidLocale reports whether locale is one of the supported locales
+func IsValidLocale(locale string) bool {
+	return locale == LocalePTBR || locale == LocaleEN
+}
+
+// MatchLocale returns the supported locale closest to a language tag ("en-US" gives "en",
+// "pt" and "pt-PT" give "pt-BR") or "" when none matches
+func MatchLocale(tag string) string {
+	language, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+	switch language {
+	case "pt":
+		return LocalePTBR
+	case "en":
+		return LocaleEN
+	}
+	return ""
+}
+
 // User represents a user in the system
 type User struct {
 	ID            string    `json:"id" db:"id"`
@@ -11,6 +43,8 @@ type User struct {
 	Email         string    `json:"email" db:"email"`
 	EmailVerified bool      `json:"emailVerified" db:"email_verified"`
 	Image         *string   `json:"image,omitempty" db:"image"`
+	Role          string    `json:"role" db:"role"`
+	Locale        string    `json:"locale" db:"locale"` // language of emails sent to the user
 	CreatedAt     time.Time `json:"createdAt" db:"created_at"`
 	UpdatedAt     time.Time `json:"updatedAt" db:"updated_at"`
 }
@@ -53,9 +87,46 @@ type SessionWithUser struct {
 // Verification represents email/password verification tokens
 type Verification struct {
 	ID         string    `json:"id" db:"id"`
-	Identifier string    `json:"identifier" db:"identifier"` // email address
-	Value      string    `json:"value" db:"value"`           // token
+	Identifier string    `json:"identifier" db:"identifier"` // email address, or provider prefix + user ID for OAuth state
+	Purpose    string    `json:"purpose" db:"purpose"`       // see the tokens package
+	Value      string    `json:"-" db:"value"`               // SHA-256 of the token
 	ExpiresAt  time.Time `json:"expiresAt" db:"expires_at"`
 	CreatedAt  time.Time `json:"createdAt" db:"created_at"`
 	UpdatedAt  time.Time `json:"updatedAt" db:"updated_at"`
 }
+
+// RoleChange records a role promotion or demotion
+type RoleChange struct {
+	ID        string    `json:"id" db:"id"`
+	UserID    string    `json:"userId" db:"user_id"`
+	ChangedBy *string   `json:"changedBy,omitempty" db:"changed_by"`
+	OldRole   string    `json:"oldRole" db:"old_role"`
+	NewRole   string    `json:"newRole" db:"new_role"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+// EmailChange is a requested email change waiting for the new address to be confirmed
+type EmailChange struct {
+	ID        string    `json:"id" db:"id"`
+	UserID    string    `json:"userId" db:"user_id"`
+	NewEmail  string    `json:"newEmail" db:"new_email"`
+	ExpiresAt time.Time `json:"expiresAt" db:"expires_at"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+// UserMFA is a user's TOTP enrollment. EnabledAt stays nil until the first code is confirmed.
+type UserMFA struct {
+	ID             string     `json:"id" db:"id"`
+	UserID         string     `json:"userId" db:"user_id"`
+	Secret         string     `json:"-" db:"secret"` // base32 TOTP secret, encrypted at rest
+	EnabledAt      *time.Time `json:"enabledAt" db:"enabled_at"`
+	LastUsedStep   int64      `json:"-" db:"last_used_step"`  // rejects replayed codes
+	FailedAttempts int        `json:"-" db:"failed_attempts"` // wrong codes since the last accepted one
+	CreatedAt      time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updatedAt" db:"updated_at"`
+}
+
+// Enabled reports whether sign-in requires a second factor
+func (m *UserMFA) Enabled() bool {
+	return m != nil && m.EnabledAt != nil
+}
@@ -0,0 +1,196 @@
+package oauth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Config describes an OAuth2 authorization-code client.
+// Endpoints are plain fields so tests can point them at a local fake server.
+type Config struct {
+	ClientID     string
+	ClientSecret string
+	AuthURL      string
+	TokenURL     string
+	RedirectURL  string
+	Scopes       []string
+	HTTPClient   *http.Client
+
+	// Non-standard providers (TikTok) name the client id "client_key" and join scopes with commas
+	ClientIDParam  string
+	ScopeSeparator string
+}
+
+// Token is the token endpoint response
+type Token struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresIn    int64     `json:"expires_in"`
+	IDToken      string    `json:"id_token"`
+	Scope        string    `json:"scope"`
+	Expiry       time.Time `json:"-"`
+
+	// Provider specific fields (TikTok)
+	OpenID           string    `json:"open_id"`
+	RefreshExpiresIn int64     `json:"refresh_expires_in"`
+	RefreshExpiry    time.Time `json:"-"`
+}
+
+// Error is an OAuth2 error response (RFC 6749 section 5.2)
+type Error struct {
+	StatusCode  int
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *Error) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("oauth: %s: %s", e.Code, e.Description)
+	}
+	return fmt.Sprintf("oauth: %s (status %d)", e.Code, e.StatusCode)
+}
+
+// IsInvalidGrant reports whether err means the refresh token or code is no longer usable
+func IsInvalidGrant(err error) bool {
+	var oauthErr *Error
+	return errors.As(err, &oauthErr) && oauthErr.Code == "invalid_grant"
+}
+
+// AuthCodeURL builds the authorization redirect URL
+func (c *Config) AuthCodeURL(state string, extra map[string]string) string {
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set(c.clientIDParam(), c.ClientID)
+	params.Set("redirect_uri", c.RedirectURL)
+	params.Set("state", state)
+	if len(c.Scopes) > 0 {
+		separator := c.ScopeSeparator
+		if separator == "" {
+			separator = " "
+		}
+		params.Set("scope", strings.Join(c.Scopes, separator))
+	}
+	for key, value := range extra {
+		params.Set(key, value)
+	}
+
+	separator := "?"
+	if strings.Contains(c.AuthURL, "?") {
+		separator = "&"
+	}
+	return c.AuthURL + separator + params.Encode()
+}
+
+// Exchange trades an authorization code for tokens
+func (c *Config) Exchange(ctx context.Context, code string) (*Token, error) {
+	return c.tokenRequest(ctx, url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {c.RedirectURL},
+	})
+}
+
+// Refresh obtains a new access token. The returned token keeps refreshToken
+// when the provider does not rotate it.
+func (c *Config) Refresh(ctx context.Context, refreshToken string) (*Token, error) {
+	token, err := c.tokenRequest(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+	return token, nil
+}
+
+func (c *Config) tokenRequest(ctx context.Context, form url.Values) (*Token, error) {
+	form.Set(c.clientIDParam(), c.ClientID)
+	form.Set("client_secret", c.ClientSecret)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		oauthErr := &Error{StatusCode: resp.StatusCode}
+		json.NewDecoder(resp.Body).Decode(oauthErr)
+		if oauthErr.Code == "" {
+			oauthErr.Code = "token_request_failed"
+		}
+		return nil, oauthErr
+	}
+
+	// Some providers report errors with a 200 status
+	var payload struct {
+		Token
+		Error
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if payload.Error.Code != "" {
+		payload.Error.StatusCode = resp.StatusCode
+		return nil, &payload.Error
+	}
+
+	token := payload.Token
+	if token.AccessToken == "" {
+		return nil, errors.New("token response has no access_token")
+	}
+	now := time.Now()
+	if token.ExpiresIn > 0 {
+		token.Expiry = now.Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	if token.RefreshExpiresIn > 0 {
+		token.RefreshExpiry = now.Add(time.Duration(token.RefreshExpiresIn) * time.Second)
+	}
+	return &token, nil
+}
+
+func (c *Config) clientIDParam() string {
+	if c.ClientIDParam != "" {
+		return c.ClientIDParam
+	}
+	return "client_id"
+}
+
+func (c *Config) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return &http.Client{Timeout: 30 * time.Second}
+}
+
+// UnverifiedClaims decodes the payload of a JWT without checking its signature.
+// Only use it for ID tokens received directly from the token endpoint over TLS.
+func UnverifiedClaims(jwt string, claims any) error {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return errors.New("malformed JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return fmt.Errorf("failed to decode JWT payload: %w", err)
+	}
+	return json.Unmarshal(payload, claims)
+}
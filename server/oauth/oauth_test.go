@@ -0,0 +1,215 @@
+package oauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	"viral-cuts-server/models"
+)
+
+// fakeTokenServer answers token requests with respond and keeps the last form it received
+type fakeTokenServer struct {
+	*httptest.Server
+	form url.Values
+}
+
+func newFakeTokenServer(t *testing.T, respond func(w http.ResponseWriter, form url.Values)) *fakeTokenServer {
+	t.Helper()
+	fake := &fakeTokenServer{}
+	fake.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+			t.Errorf("unexpected %s request with Content-Type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("ParseForm: %v", err)
+		}
+		fake.form = r.PostForm
+		w.Header().Set("Content-Type", "application/json")
+		respond(w, r.PostForm)
+	}))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+func (f *fakeTokenServer) config() *Config {
+	return &Config{
+		ClientID:     "client-1",
+		ClientSecret: "secret-1",
+		AuthURL:      f.URL + "/auth",
+		TokenURL:     f.URL + "/token",
+		RedirectURL:  "http://localhost:3000/api/youtube/callback",
+		HTTPClient:   f.Client(),
+	}
+}
+
+func TestRefreshKeepsRefreshToken(t *testing.T) {
+	server := newFakeTokenServer(t, func(w http.ResponseWriter, form url.Values) {
+		w.Write([]byte(`{"access_token":"access-2","token_type":"Bearer","expires_in":3599,"scope":"openid"}`))
+	})
+
+	token, err := server.config().Refresh(context.Background(), "refresh-1")
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if got := server.form; got.Get("grant_type") != "refresh_token" || got.Get("refresh_token") != "refresh-1" ||
+		got.Get("client_id") != "client-1" || got.Get("client_secret") != "secret-1" {
+		t.Errorf("form = %v", got)
+	}
+	if token.AccessToken != "access-2" || token.RefreshToken != "refresh-1" {
+		t.Errorf("token = %+v, want access-2 and the old refresh token", token)
+	}
+	if until := time.Until(token.Expiry); until < 59*time.Minute || until > time.Hour {
+		t.Errorf("expiry in %s, want about an hour", until)
+	}
+}
+
+func TestRefreshRotatesRefreshToken(t *testing.T) {
+	server := newFakeTokenServer(t, func(w http.ResponseWriter, form url.Values) {
+		w.Write([]byte(`{"access_token":"access-2","refresh_token":"refresh-2","expires_in":86400,"refresh_expires_in":31536000,"open_id":"tt-1"}`))
+	})
+	config := server.config()
+	config.ClientIDParam = "client_key"
+
+	token, err := config.Refresh(context.Background(), "refresh-1")
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if server.form.Get("client_key") != "client-1" || server.form.Has("client_id") {
+		t.Errorf("form = %v, want client_key only", server.form)
+	}
+	if token.RefreshToken != "refresh-2" || token.OpenID != "tt-1" || token.RefreshExpiry.IsZero() {
+		t.Errorf("token = %+v", token)
+	}
+}
+
+func TestRefreshInvalidGrant(t *testing.T) {
+	server := newFakeTokenServer(t, func(w http.ResponseWriter, form url.Values) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant","error_description":"Token has been expired or revoked."}`))
+	})
+
+	_, err := server.config().Refresh(context.Background(), "revoked")
+	if !IsInvalidGrant(err) {
+		t.Fatalf("err = %v, want invalid_grant", err)
+	}
+	if !strings.Contains(err.Error(), "expired or revoked") {
+		t.Errorf("err = %v, want the description", err)
+	}
+}
+
+func TestTokenErrorWithOKStatus(t *testing.T) {
+	// TikTok reports some errors with a 200 status
+	server := newFakeTokenServer(t, func(w http.ResponseWriter, form url.Values) {
+		w.Write([]byte(`{"error":"invalid_grant","error_description":"Refresh token is invalid or expired."}`))
+	})
+
+	if _, err := server.config().Refresh(context.Background(), "refresh-1"); !IsInvalidGrant(err) {
+		t.Fatalf("err = %v, want invalid_grant", err)
+	}
+}
+
+func TestTokenServerFailure(t *testing.T) {
+	server := newFakeTokenServer(t, func(w http.ResponseWriter, form url.Values) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	_, err := server.config().Refresh(context.Background(), "refresh-1")
+	oauthErr, ok := err.(*Error)
+	if !ok || oauthErr.StatusCode != http.StatusBadGateway || IsInvalidGrant(err) {
+		t.Fatalf("err = %#v, want a non invalid_grant *Error with status 502", err)
+	}
+}
+
+func TestTokenResponseWithoutAccessToken(t *testing.T) {
+	server := newFakeTokenServer(t, func(w http.ResponseWriter, form url.Values) {
+		w.Write([]byte(`{"token_type":"Bearer"}`))
+	})
+
+	if _, err := server.config().Refresh(context.Background(), "refresh-1"); err == nil {
+		t.Fatal("accepted a token response without access_token")
+	}
+}
+
+func TestExchange(t *testing.T) {
+	server := newFakeTokenServer(t, func(w http.ResponseWriter, form url.Values) {
+		w.Write([]byte(`{"access_token":"access-1","refresh_token":"refresh-1","expires_in":3600,"id_token":"a.b.c"}`))
+	})
+	config := server.config()
+
+	token, err := config.Exchange(context.Background(), "code-1")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if server.form.Get("grant_type") != "authorization_code" || server.form.Get("code") != "code-1" ||
+		server.form.Get("redirect_uri") != config.RedirectURL {
+		t.Errorf("form = %v", server.form)
+	}
+	if token.AccessToken != "access-1" || token.RefreshToken != "refresh-1" || token.IDToken != "a.b.c" {
+		t.Errorf("token = %+v", token)
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	config := &Config{
+		ClientID:       "client-1",
+		AuthURL:        "https://www.tiktok.com/v2/auth/authorize/",
+		RedirectURL:    "http://localhost:3000/cb",
+		Scopes:         []string{"user.info.basic", "video.publish"},
+		ClientIDParam:  "client_key",
+		ScopeSeparator: ",",
+	}
+
+	parsed, err := url.Parse(config.AuthCodeURL("state-1", map[string]string{"access_type": "offline"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	if query.Get("client_key") != "client-1" || query.Get("scope") != "user.info.basic,video.publish" ||
+		query.Get("state") != "state-1" || query.Get("access_type") != "offline" || query.Get("response_type") != "code" {
+		t.Errorf("query = %v", query)
+	}
+}
+
+func TestUnverifiedClaims(t *testing.T) {
+	// {"sub":"123","email":"ana@example.com"}
+	jwt := "eyJhbGciOiJSUzI1NiJ9.eyJzdWIiOiIxMjMiLCJlbWFpbCI6ImFuYUBleGFtcGxlLmNvbSJ9.c2ln"
+	var claims struct {
+		Subject string `json:"sub"`
+		Email   string `json:"email"`
+	}
+	if err := UnverifiedClaims(jwt, &claims); err != nil {
+		t.Fatalf("UnverifiedClaims: %v", err)
+	}
+	if claims.Subject != "123" || claims.Email != "ana@example.com" {
+		t.Errorf("claims = %+v", claims)
+	}
+	if err := UnverifiedClaims("not-a-jwt", &claims); err == nil {
+		t.Error("accepted a malformed JWT")
+	}
+}
+
+func TestIsFresh(t *testing.T) {
+	token := "access-1"
+	soon := time.Now().Add(refreshSkew / 2)
+	later := time.Now().Add(time.Hour)
+
+	cases := []struct {
+		name    string
+		account models.Account
+		want    bool
+	}{
+		{"no token", models.Account{}, false},
+		{"no expiry", models.Account{AccessToken: &token}, true},
+		{"expires within the skew", models.Account{AccessToken: &token, AccessTokenExpiresAt: &soon}, false},
+		{"valid", models.Account{AccessToken: &token, AccessTokenExpiresAt: &later}, true},
+	}
+	for _, tc := range cases {
+		if got := isFresh(&tc.account); got != tc.want {
+			t.Errorf("%s: isFresh = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
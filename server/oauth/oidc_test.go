@@ -0,0 +1,248 @@
+package oauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testIssuer   = "https://accounts.google.com"
+	testClientID = "client-1.apps.googleusercontent.com"
+)
+
+// fakeIssuer serves a JWKS and signs ID tokens with its current keys
+type fakeIssuer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	keys     map[string]*rsa.PrivateKey
+	requests atomic.Int32
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	t.Helper()
+	issuer := &fakeIssuer{keys: map[string]*rsa.PrivateKey{}}
+	issuer.addKey(t, "key-1")
+	issuer.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		issuer.requests.Add(1)
+		issuer.mu.Lock()
+		defer issuer.mu.Unlock()
+
+		type jwk struct {
+			KeyType string `json:"kty"`
+			KeyID   string `json:"kid"`
+			N       string `json:"n"`
+			E       string `json:"e"`
+		}
+		jwks := struct {
+			Keys []jwk `json:"keys"`
+		}{}
+		for kid, key := range issuer.keys {
+			jwks.Keys = append(jwks.Keys, jwk{
+				KeyType: "RSA",
+				KeyID:   kid,
+				N:       base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:       base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		json.NewEncoder(w).Encode(jwks)
+	}))
+	t.Cleanup(issuer.Close)
+	return issuer
+}
+
+func (i *fakeIssuer) addKey(t *testing.T, kid string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.mu.Lock()
+	i.keys[kid] = key
+	i.mu.Unlock()
+}
+
+func (i *fakeIssuer) verifier() *Verifier {
+	verifier := NewVerifier([]string{testIssuer, "accounts.google.com"}, testClientID, i.URL)
+	verifier.HTTPClient = i.Client()
+	return verifier
+}
+
+// sign builds a JWT with the given header fields and claims, signed by the key kid
+func (i *fakeIssuer) sign(t *testing.T, header map[string]any, claims map[string]any) string {
+	t.Helper()
+	encode := func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signingInput := encode(header) + "." + encode(claims)
+
+	i.mu.Lock()
+	key, ok := i.keys[header["kid"].(string)]
+	i.mu.Unlock()
+	if !ok {
+		return signingInput + ".c2lnbmF0dXJl"
+	}
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func validClaims() map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"iss":            testIssuer,
+		"sub":            "1234567890",
+		"aud":            testClientID,
+		"exp":            now.Add(time.Hour).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          "nonce-1",
+		"email":          "ana@example.com",
+		"email_verified": true,
+		"name":           "Ana",
+		"locale":         "pt-BR",
+	}
+}
+
+func rs256(kid string) map[string]any {
+	return map[string]any{"alg": "RS256", "kid": kid, "typ": "JWT"}
+}
+
+func TestVerifyValidToken(t *testing.T) {
+	issuer := newFakeIssuer(t)
+
+	claims, err := issuer.verifier().Verify(context.Background(), issuer.sign(t, rs256("key-1"), validClaims()), "nonce-1")
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if claims.Subject != "1234567890" || claims.Email != "ana@example.com" || !bool(claims.EmailVerified) || claims.Locale != "pt-BR" {
+		t.Errorf("claims = %+v", claims)
+	}
+}
+
+func TestVerifyAcceptsClaimVariants(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	claims := validClaims()
+	claims["iss"] = "accounts.google.com"
+	claims["aud"] = []string{"other-client", testClientID}
+	claims["email_verified"] = "true"
+
+	verified, err := issuer.verifier().Verify(context.Background(), issuer.sign(t, rs256("key-1"), claims), "nonce-1")
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !bool(verified.EmailVerified) {
+		t.Error("email_verified \"true\" was not accepted")
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	issuer := newFakeIssuer(t)
+
+	cases := []struct {
+		name   string
+		header map[string]any
+		claims func(claims map[string]any)
+		nonce  string
+	}{
+		{name: "alg none", header: map[string]any{"alg": "none", "kid": "key-1"}},
+		{name: "alg HS256", header: map[string]any{"alg": "HS256", "kid": "key-1"}},
+		{name: "unknown kid", header: rs256("key-unknown")},
+		{name: "other issuer", claims: func(c map[string]any) { c["iss"] = "https://evil.example.com" }},
+		{name: "other audience", claims: func(c map[string]any) { c["aud"] = "other-client" }},
+		{name: "expired", claims: func(c map[string]any) { c["exp"] = time.Now().Add(-2 * clockSkew).Unix() }},
+		{name: "issued in the future", claims: func(c map[string]any) { c["iat"] = time.Now().Add(2 * clockSkew).Unix() }},
+		{name: "nonce mismatch", nonce: "nonce-2"},
+		{name: "missing nonce", claims: func(c map[string]any) { delete(c, "nonce") }},
+		{name: "no subject", claims: func(c map[string]any) { delete(c, "sub") }},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			header := tc.header
+			if header == nil {
+				header = rs256("key-1")
+			}
+			claims := validClaims()
+			if tc.claims != nil {
+				tc.claims(claims)
+			}
+			nonce := tc.nonce
+			if nonce == "" {
+				nonce = "nonce-1"
+			}
+
+			_, err := issuer.verifier().Verify(context.Background(), issuer.sign(t, header, claims), nonce)
+			if !errors.Is(err, ErrInvalidIDToken) {
+				t.Fatalf("err = %v, want ErrInvalidIDToken", err)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsTamperedPayload(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	token := issuer.sign(t, rs256("key-1"), validClaims())
+
+	claims := validClaims()
+	claims["email"] = "attacker@example.com"
+	forged := strings.Split(issuer.sign(t, rs256("key-1"), claims), ".")
+	parts := strings.Split(token, ".")
+	tampered := parts[0] + "." + forged[1] + "." + parts[2]
+
+	if _, err := issuer.verifier().Verify(context.Background(), tampered, "nonce-1"); !errors.Is(err, ErrInvalidIDToken) {
+		t.Fatalf("err = %v, want ErrInvalidIDToken", err)
+	}
+}
+
+func TestVerifyRefetchesRotatedKeys(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	verifier := issuer.verifier()
+	ctx := context.Background()
+
+	if _, err := verifier.Verify(ctx, issuer.sign(t, rs256("key-1"), validClaims()), "nonce-1"); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if _, err := verifier.Verify(ctx, issuer.sign(t, rs256("key-1"), validClaims()), "nonce-1"); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if got := issuer.requests.Load(); got != 1 {
+		t.Fatalf("JWKS fetched %d times, want the keys cached", got)
+	}
+
+	// A key published after the last fetch is picked up once the refresh interval has passed
+	issuer.addKey(t, "key-2")
+	verifier.fetchedAt = time.Now().Add(-jwksRefreshInterval)
+	if _, err := verifier.Verify(ctx, issuer.sign(t, rs256("key-2"), validClaims()), "nonce-1"); err != nil {
+		t.Fatalf("Verify with the rotated key: %v", err)
+	}
+	if got := issuer.requests.Load(); got != 2 {
+		t.Fatalf("JWKS fetched %d times, want 2", got)
+	}
+
+	// Unknown key ids don't refetch again within the interval
+	if _, err := verifier.Verify(ctx, issuer.sign(t, rs256("key-3"), validClaims()), "nonce-1"); !errors.Is(err, ErrInvalidIDToken) {
+		t.Fatalf("err = %v, want ErrInvalidIDToken", err)
+	}
+	if got := issuer.requests.Load(); got != 2 {
+		t.Fatalf("JWKS fetched %d times, want no refetch within the interval", got)
+	}
+}
@@ -0,0 +1,186 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"viral-cuts-server/models"
+	"viral-cuts-server/utils"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrNotConnected is returned when the user has no usable account for the provider
+var ErrNotConnected = errors.New("account not connected")
+
+// refreshSkew refreshes access tokens slightly before they expire
+const refreshSkew = 2 * time.Minute
+
+// TokenStore keeps a provider's OAuth tokens in the account table and refreshes them on demand
+type TokenStore struct {
+	db         *pgxpool.Pool
+	providerID string
+	config     *Config
+
+	// refreshMu serialises refreshes so concurrent callers don't burn the refresh token twice
+	refreshMu sync.Mutex
+}
+
+func NewTokenStore(db *pgxpool.Pool, providerID string, config *Config) *TokenStore {
+	return &TokenStore{db: db, providerID: providerID, config: config}
+}
+
+// Save upserts the tokens of a connected account
+func (s *TokenStore) Save(ctx context.Context, userID, accountID string, token *Token) error {
+	var expiresAt *time.Time
+	if !token.Expiry.IsZero() {
+		expiresAt = &token.Expiry
+	}
+	var refreshToken *string
+	if token.RefreshToken != "" {
+		refreshToken = &token.RefreshToken
+	}
+	var refreshExpiresAt *time.Time
+	if !token.RefreshExpiry.IsZero() {
+		refreshExpiresAt = &token.RefreshExpiry
+	}
+	var idToken *string
+	if token.IDToken != "" {
+		idToken = &token.IDToken
+	}
+
+	// Tokens are encrypted at rest; expiry and scope stay readable for queries
+	accessToken, err := utils.EncryptSecret(token.AccessToken)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt %s access token: %w", s.providerID, err)
+	}
+	if refreshToken, err = utils.EncryptSecretPtr(refreshToken); err != nil {
+		return fmt.Errorf("failed to encrypt %s refresh token: %w", s.providerID, err)
+	}
+	if idToken, err = utils.EncryptSecretPtr(idToken); err != nil {
+		return fmt.Errorf("failed to encrypt %s id token: %w", s.providerID, err)
+	}
+
+	now := time.Now()
+	result, err := s.db.Exec(ctx,
+		`UPDATE "account"
+		 SET access_token = $1,
+		     refresh_token = COALESCE($2, refresh_token),
+		     id_token = COALESCE($3, id_token),
+		     access_token_expires_at = $4,
+		     refresh_token_expires_at = COALESCE($5, refresh_token_expires_at),
+		     scope = $6,
+		     updated_at = $7
+		 WHERE user_id = $8 AND provider_id = $9 AND account_id = $10`,
+		accessToken, refreshToken, idToken, expiresAt, refreshExpiresAt, token.Scope, now,
+		userID, s.providerID, accountID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update %s account: %w", s.providerID, err)
+	}
+	if result.RowsAffected() > 0 {
+		return nil
+	}
+
+	_, err = s.db.Exec(ctx,
+		`INSERT INTO "account" (id, account_id, provider_id, user_id, access_token, refresh_token, id_token,
+		                        access_token_expires_at, refresh_token_expires_at, scope, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		utils.GenerateID(), accountID, s.providerID, userID, accessToken, refreshToken, idToken,
+		expiresAt, refreshExpiresAt, token.Scope, now, now,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create %s account: %w", s.providerID, err)
+	}
+	return nil
+}
+
+// Account returns the most recently connected account of a user for the provider
+func (s *TokenStore) Account(ctx context.Context, userID string) (*models.Account, error) {
+	var account models.Account
+	err := s.db.QueryRow(ctx,
+		`SELECT id, account_id, provider_id, user_id, access_token, refresh_token, access_token_expires_at,
+		        scope, created_at, updated_at
+		 FROM "account"
+		 WHERE user_id = $1 AND provider_id = $2
+		 ORDER BY updated_at DESC
+		 LIMIT 1`,
+		userID, s.providerID,
+	).Scan(&account.ID, &account.AccountID, &account.ProviderID, &account.UserID, &account.AccessToken,
+		&account.RefreshToken, &account.AccessTokenExpiresAt, &account.Scope, &account.CreatedAt, &account.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, ErrNotConnected
+	} else if err != nil {
+		return nil, err
+	}
+
+	if account.AccessToken, err = utils.DecryptSecretPtr(account.AccessToken); err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s access token: %w", s.providerID, err)
+	}
+	if account.RefreshToken, err = utils.DecryptSecretPtr(account.RefreshToken); err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s refresh token: %w", s.providerID, err)
+	}
+	return &account, nil
+}
+
+// AccessToken returns a valid access token for the user, refreshing it first when it is about to expire
+func (s *TokenStore) AccessToken(ctx context.Context, userID string) (string, error) {
+	account, err := s.Account(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if isFresh(account) {
+		return *account.AccessToken, nil
+	}
+
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	// Another caller may have refreshed while we waited
+	account, err = s.Account(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if isFresh(account) {
+		return *account.AccessToken, nil
+	}
+
+	if account.RefreshToken == nil || *account.RefreshToken == "" {
+		return "", ErrNotConnected
+	}
+
+	token, err := s.config.Refresh(ctx, *account.RefreshToken)
+	if IsInvalidGrant(err) {
+		// Access was revoked on the provider's side, the user has to reconnect
+		return "", ErrNotConnected
+	} else if err != nil {
+		return "", fmt.Errorf("failed to refresh %s token: %w", s.providerID, err)
+	}
+
+	if err := s.Save(ctx, userID, account.AccountID, token); err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+// Disconnect removes all accounts of a user for the provider
+func (s *TokenStore) Disconnect(ctx context.Context, userID string) error {
+	_, err := s.db.Exec(ctx,
+		`DELETE FROM "account" WHERE user_id = $1 AND provider_id = $2`,
+		userID, s.providerID,
+	)
+	return err
+}
+
+func isFresh(account *models.Account) bool {
+	if account.AccessToken == nil || *account.AccessToken == "" {
+		return false
+	}
+	if account.AccessTokenExpiresAt == nil {
+		return true
+	}
+	return time.Now().Add(refreshSkew).Before(*account.AccessTokenExpiresAt)
+}
@@ -0,0 +1,163 @@
+package opus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrInvalidAPIKey is returned when Opus rejects the user's API key
+var ErrInvalidAPIKey = errors.New("invalid opus api key")
+
+// APIError is an error response of the Opus Clip API
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("opus api error %d: %s", e.StatusCode, e.Message)
+}
+
+// Client wraps the Opus Clip API. BaseURL is a field so tests can point it at a local stub.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewClient creates a client for the public API, overridable with OPUS_API_BASE_URL
+func NewClient() *Client {
+	baseURL := os.Getenv("OPUS_API_BASE_URL")
+	if baseURL == "" {
+		baseURL = "https://api.opus.pro/api"
+	}
+	return &Client{
+		BaseURL:    baseURL,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Project is a clip project
+type Project struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// Clip is a clip of a finished project
+type Clip struct {
+	ID            string  `json:"id"`
+	Title         string  `json:"title"`
+	ViralityScore float64 `json:"viralityScore"`
+	DownloadURL   string  `json:"downloadUrl"`
+	ThumbnailURL  string  `json:"thumbnailUrl"`
+}
+
+type createProjectRequest struct {
+	VideoURL     string `json:"videoUrl"`
+	CurationPref struct {
+		ClipDurations [][]int `json:"clipDurations"`
+		Genre         string  `json:"genre"`
+	} `json:"curationPref"`
+	WebhookURL string `json:"webhookUrl,omitempty"`
+}
+
+// CreateProject starts a clip project for a publicly reachable video URL.
+// webhookURL is optional; Opus calls it when the project changes state.
+func (c *Client) CreateProject(ctx context.Context, apiKey, videoURL, webhookURL string) (*Project, error) {
+	body := createProjectRequest{VideoURL: videoURL, WebhookURL: webhookURL}
+	body.CurationPref.ClipDurations = [][]int{{0, 60}}
+	body.CurationPref.Genre = "Auto"
+
+	var project Project
+	if err := c.call(ctx, apiKey, "POST", "/clip-projects", body, &project); err != nil {
+		return nil, err
+	}
+	if project.ID == "" {
+		return nil, errors.New("opus did not return a project id")
+	}
+	return &project, nil
+}
+
+// GetProject returns the project status
+func (c *Client) GetProject(ctx context.Context, apiKey, projectID string) (*Project, error) {
+	var project Project
+	if err := c.call(ctx, apiKey, "GET", "/clip-projects/"+projectID, nil, &project); err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+// ListClips returns the clips of a finished project
+func (c *Client) ListClips(ctx context.Context, apiKey, projectID string) ([]Clip, error) {
+	var resp struct {
+		Clips []Clip `json:"clips"`
+	}
+	if err := c.call(ctx, apiKey, "GET", "/clip-projects/"+projectID+"/clips", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Clips, nil
+}
+
+func (c *Client) call(ctx context.Context, apiKey, method, path string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("opus request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return ErrInvalidAPIKey
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var payload struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&payload)
+		if payload.Message == "" {
+			payload.Message = resp.Status
+		}
+		return &APIError{StatusCode: resp.StatusCode, Message: payload.Message}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode opus response: %w", err)
+	}
+	return nil
+}
+
+// NormalizeStatus maps Opus project statuses onto job statuses
+func NormalizeStatus(status string) string {
+	switch strings.ToLower(status) {
+	case "done", "completed", "complete", "finished", "success":
+		return "done"
+	case "failed", "error", "cancelled", "canceled":
+		return "error"
+	default:
+		return "processing"
+	}
+}
@@ -0,0 +1,120 @@
+package opus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := NewClient()
+	client.BaseURL = server.URL
+	client.HTTPClient = server.Client()
+	return client
+}
+
+func TestCreateProject(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/clip-projects" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer key-1" {
+			t.Errorf("Authorization = %q", got)
+		}
+		var body createProjectRequest
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.VideoURL != "https://youtu.be/abc" || body.WebhookURL != "https://api.example.com/hook" ||
+			body.CurationPref.Genre != "Auto" || len(body.CurationPref.ClipDurations) != 1 {
+			t.Errorf("body = %+v", body)
+		}
+		w.Write([]byte(`{"id":"proj-1","status":"queued"}`))
+	})
+
+	project, err := client.CreateProject(context.Background(), "key-1", "https://youtu.be/abc", "https://api.example.com/hook")
+	if err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+	if project.ID != "proj-1" || NormalizeStatus(project.Status) != "processing" {
+		t.Errorf("project = %+v", project)
+	}
+}
+
+func TestCreateProjectWithoutID(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"queued"}`))
+	})
+
+	if _, err := client.CreateProject(context.Background(), "key-1", "https://youtu.be/abc", ""); err == nil {
+		t.Fatal("accepted a project without id")
+	}
+}
+
+func TestListClips(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/clip-projects/proj-1/clips" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`{"clips":[{"id":"c1","title":"Goal","viralityScore":91.6,"downloadUrl":"https://cdn.opus.pro/c1.mp4"}]}`))
+	})
+
+	clips, err := client.ListClips(context.Background(), "key-1", "proj-1")
+	if err != nil {
+		t.Fatalf("ListClips: %v", err)
+	}
+	if len(clips) != 1 || clips[0].ID != "c1" || clips[0].ViralityScore != 91.6 || clips[0].DownloadURL != "https://cdn.opus.pro/c1.mp4" {
+		t.Errorf("clips = %+v", clips)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		status int
+		body   string
+		check  func(error) bool
+	}{
+		{"unauthorized", http.StatusUnauthorized, `{}`, func(err error) bool { return errors.Is(err, ErrInvalidAPIKey) }},
+		{"forbidden", http.StatusForbidden, `{}`, func(err error) bool { return errors.Is(err, ErrInvalidAPIKey) }},
+		{"not found", http.StatusNotFound, `{"message":"project not found"}`, func(err error) bool {
+			var apiErr *APIError
+			return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound && apiErr.Message == "project not found"
+		}},
+		{"no message", http.StatusBadGateway, `oops`, func(err error) bool {
+			var apiErr *APIError
+			return errors.As(err, &apiErr) && apiErr.Message == "502 Bad Gateway"
+		}},
+	}
+
+	for _, tc := range cases {
+		client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.status)
+			w.Write([]byte(tc.body))
+		})
+		if _, err := client.GetProject(context.Background(), "key-1", "proj-1"); !tc.check(err) {
+			t.Errorf("%s: err = %v", tc.name, err)
+		}
+	}
+}
+
+func TestNormalizeStatus(t *testing.T) {
+	cases := map[string]string{
+		"COMPLETED": "done",
+		"success":   "done",
+		"failed":    "error",
+		"Canceled":  "error",
+		"queued":    "processing",
+		"":          "processing",
+	}
+	for status, want := range cases {
+		if got := NormalizeStatus(status); got != want {
+			t.Errorf("NormalizeStatus(%q) = %q, want %q", status, got, want)
+		}
+	}
+}
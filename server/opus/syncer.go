@@ -0,0 +1,225 @@
+package opus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+	"viral-cuts-server/models"
+	"viral-cuts-server/utils"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrNoAPIKey is returned when the user has not configured an Opus API key
+var ErrNoAPIKey = errors.New("opus api key not configured")
+
+// Syncer polls Opus for processing jobs, stores finished clips and auto-enqueues the best ones
+type Syncer struct {
+	db     *pgxpool.Pool
+	client *Client
+
+	PollInterval time.Duration
+	BatchSize    int
+}
+
+func NewSyncer(db *pgxpool.Pool, client *Client) *Syncer {
+	return &Syncer{
+		db:           db,
+		client:       client,
+		PollInterval: time.Minute,
+		BatchSize:    10,
+	}
+}
+
+// APIKey returns the user's decrypted Opus API key from user_settings
+func (s *Syncer) APIKey(ctx context.Context, userID string) (string, error) {
+	var apiKey *string
+	err := s.db.QueryRow(ctx,
+		`SELECT opus_api_key FROM user_settings WHERE user_id = $1`,
+		userID,
+	).Scan(&apiKey)
+	if err == pgx.ErrNoRows || (err == nil && (apiKey == nil || *apiKey == "")) {
+		return "", ErrNoAPIKey
+	} else if err != nil {
+		return "", err
+	}
+	return utils.DecryptSecret(*apiKey)
+}
+
+// Start polls processing jobs until ctx is cancelled
+func (s *Syncer) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.PollInterval)
+	defer ticker.Stop()
+
+	log.Printf("Opus syncer started (interval %s)", s.PollInterval)
+
+	for {
+		if err := s.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Opus syncer: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Opus syncer stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce claims processing jobs that were not polled recently and syncs them
+func (s *Syncer) RunOnce(ctx context.Context) error {
+	rows, err := s.db.Query(ctx,
+		`UPDATE opus_job
+		 SET last_polled_at = NOW()
+		 WHERE id IN (
+		     SELECT id FROM opus_job
+		     WHERE status = 'processing' AND opus_project_id IS NOT NULL
+		       AND (last_polled_at IS NULL OR last_polled_at < NOW() - make_interval(secs => $2))
+		     ORDER BY last_polled_at NULLS FIRST
+		     LIMIT $1
+		     FOR UPDATE SKIP LOCKED
+		 )
+		 RETURNING `+models.OpusJobColumns,
+		s.BatchSize, s.PollInterval.Seconds(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to claim opus jobs: %w", err)
+	}
+
+	jobs := []*models.OpusJob{}
+	for rows.Next() {
+		job, err := models.ScanOpusJob(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+	rows.Close()
+
+	for _, job := range jobs {
+		if err := s.Sync(ctx, job); err != nil {
+			log.Printf("Opus syncer: job %s: %v", job.ID, err)
+		}
+	}
+	return nil
+}
+
+// Sync refreshes one job from Opus. It is also called by the webhook handler.
+func (s *Syncer) Sync(ctx context.Context, job *models.OpusJob) error {
+	if job.Status != models.OpusJobProcessing || job.OpusProjectID == nil {
+		return nil
+	}
+
+	apiKey, err := s.APIKey(ctx, job.UserID)
+	if errors.Is(err, ErrNoAPIKey) || errors.Is(err, ErrInvalidAPIKey) {
+		return s.fail(ctx, job, err)
+	} else if err != nil {
+		return err
+	}
+
+	project, err := s.client.GetProject(ctx, apiKey, *job.OpusProjectID)
+	if errors.Is(err, ErrInvalidAPIKey) {
+		return s.fail(ctx, job, err)
+	} else if err != nil {
+		return err
+	}
+
+	switch NormalizeStatus(project.Status) {
+	case models.OpusJobError:
+		return s.fail(ctx, job, fmt.Errorf("opus project failed with status %q", project.Status))
+	case models.OpusJobProcessing:
+		return nil
+	}
+
+	clips, err := s.client.ListClips(ctx, apiKey, *job.OpusProjectID)
+	if err != nil {
+		return err
+	}
+	return s.complete(ctx, job, clips)
+}
+
+// complete stores the clips, enqueues those above the score threshold and marks the job as done atomically
+func (s *Syncer) complete(ctx context.Context, job *models.OpusJob, clips []Clip) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the job so a webhook and the poller don't complete it twice
+	var status string
+	err = tx.QueryRow(ctx, `SELECT status FROM opus_job WHERE id = $1 FOR UPDATE`, job.ID).Scan(&status)
+	if err != nil {
+		return err
+	}
+	if status != models.OpusJobProcessing {
+		return nil
+	}
+
+	now := time.Now()
+	enqueued := 0
+	for _, clip := range clips {
+		score := int(math.Round(clip.ViralityScore))
+		title := clip.Title
+		if title == "" {
+			title = "Untitled Clip"
+		}
+
+		var queueItemID *string
+		if job.AutoEnqueue && score >= job.MinScore && clip.DownloadURL != "" {
+			id := utils.GenerateID()
+			_, err := tx.Exec(ctx,
+				`INSERT INTO upload_queue (id, user_id, title, source, platform, status, file_url, scheduled_at,
+				                           privacy_status, created_at, updated_at)
+				 VALUES ($1, $2, $3, 'opus', $4, $5, $6, $7,
+				         COALESCE((SELECT default_visibility FROM user_settings WHERE user_id = $2), 'private'), $7, $8)`,
+				id, job.UserID, title, job.Platform, models.QueueStatusReady, clip.DownloadURL, now, now,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to enqueue clip: %w", err)
+			}
+			queueItemID = &id
+			enqueued++
+		}
+
+		_, err := tx.Exec(ctx,
+			`INSERT INTO opus_clip (id, job_id, user_id, opus_clip_id, title, virality_score, download_url,
+			                        thumbnail_url, queue_item_id, created_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''), $9, $10)
+			 ON CONFLICT (job_id, opus_clip_id) DO NOTHING`,
+			utils.GenerateID(), job.ID, job.UserID, clip.ID, title, score, clip.DownloadURL,
+			clip.ThumbnailURL, queueItemID, now,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to store clip: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(ctx,
+		`UPDATE opus_job SET status = $1, error_message = NULL, updated_at = $2 WHERE id = $3`,
+		models.OpusJobDone, now, job.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	log.Printf("Opus syncer: job %s done with %d clip(s), %d enqueued", job.ID, len(clips), enqueued)
+	return nil
+}
+
+func (s *Syncer) fail(ctx context.Context, job *models.OpusJob, cause error) error {
+	_, err := s.db.Exec(ctx,
+		`UPDATE opus_job SET status = $1, error_message = $2, updated_at = NOW() WHERE id = $3 AND status = $4`,
+		models.OpusJobError, cause.Error(), job.ID, models.OpusJobProcessing,
+	)
+	return err
+}
@@ -0,0 +1,103 @@
+// Package passwords decides which new passwords are acceptable.
+//
+// The policy follows NIST SP 800-63B: a minimum length, no composition rules, and rejection of
+// passwords found in breaches or derived from the account's own email or name.
+package passwords
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Length limits. bcrypt ignores everything past 72 bytes, so longer passwords are refused
+// rather than silently truncated.
+const (
+	MinLength   = 8
+	MaxBytes    = 72
+	minInfoPart = 4 // email and name parts shorter than this aren't checked, to avoid false positives
+)
+
+var (
+	ErrTooShort     = fmt.Errorf("password must be at least %d characters", MinLength)
+	ErrTooLong      = fmt.Errorf("password must be at most %d bytes", MaxBytes)
+	ErrBreached     = errors.New("password is too common, it appears in known data breaches")
+	ErrPersonalInfo = errors.New("password must not contain your email or name")
+)
+
+//go:embed breached.txt
+var breachedList string
+
+// breached holds the bundled list, lowercased
+var breached = loadBreached(breachedList)
+
+func loadBreached(list string) map[string]bool {
+	set := map[string]bool{}
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			set[strings.ToLower(line)] = true
+		}
+	}
+	return set
+}
+
+// Check returns one of the Err values when password may not be set for the account with the given
+// email and name, or nil when it is acceptable
+func Check(password, email, name string) error {
+	if utf8.RuneCountInString(password) < MinLength {
+		return ErrTooShort
+	}
+	if len(password) > MaxBytes {
+		return ErrTooLong
+	}
+
+	normalized := normalize(password)
+	if breached[strings.ToLower(password)] || breached[normalized] {
+		return ErrBreached
+	}
+	for _, part := range personalInfo(email, name) {
+		if strings.Contains(normalized, part) {
+			return ErrPersonalInfo
+		}
+	}
+	return nil
+}
+
+// personalInfo returns the normalized pieces of an email and name that a password must not contain:
+// the whole address, its local part and every word of the name
+func personalInfo(email, name string) []string {
+	parts := []string{}
+	add := func(value string) {
+		if value = normalize(value); utf8.RuneCountInString(value) >= minInfoPart {
+			parts = append(parts, value)
+		}
+	}
+
+	email = strings.TrimSpace(email)
+	if email != "" {
+		add(email)
+		local, _, _ := strings.Cut(email, "@")
+		add(local)
+	}
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		add(word)
+	}
+	return parts
+}
+
+// normalize lowercases and drops spaces and punctuation, so "John.Smith" and "john smith" compare equal
+func normalize(value string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(value) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '@' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,55 @@
+package passwords
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	cases := []struct {
+		name     string
+		password string
+		email    string
+		userName string
+		want     error
+	}{
+		{"minimum length", "zq7#vk2m", "", "", nil},
+		{"one short", "zq7#vk2", "", "", ErrTooShort},
+		{"empty", "", "", "", ErrTooShort},
+		{"length counts characters, not bytes", "çãõéíóúà", "", "", nil},
+		{"maximum bytes", strings.Repeat("zq7#", MaxBytes/4), "", "", nil},
+		{"one byte too many", strings.Repeat("zq7#", MaxBytes/4) + "x", "", "", ErrTooLong},
+		{"multibyte over the byte limit", strings.Repeat("ç", MaxBytes/2+1), "", "", ErrTooLong},
+
+		{"breached", "password123", "", "", ErrBreached},
+		{"breached in another case", "IloveYou", "", "", ErrBreached},
+		{"breached with punctuation", "qwerty-uiop", "", "", ErrBreached},
+		{"passphrase", "correct horse battery staple", "", "", nil},
+
+		{"whole email", "ana.souza@example.com", "ana.souza@example.com", "", ErrPersonalInfo},
+		{"email local part", "xx-anasouza-2024", "ana.souza@example.com", "", ErrPersonalInfo},
+		{"email local part with case and spaces", "Ana Souza rocks!", "ana.souza@example.com", "", ErrPersonalInfo},
+		{"short local part is not checked", "bob is my uncle", "bob@example.com", "", nil},
+		{"name word", "mariana forever", "", "Mariana Lima", ErrPersonalInfo},
+		{"accented name word", "joãozinho2024", "", "João Pedro", ErrPersonalInfo},
+		{"short name word is not checked", "lima beans rock", "", "Jo Lim", nil},
+		{"unrelated to the account", "purple elephant dancing", "ana.souza@example.com", "Ana Souza", nil},
+	}
+	for _, tc := range cases {
+		if got := Check(tc.password, tc.email, tc.userName); !errors.Is(got, tc.want) {
+			t.Errorf("%s: Check(%q) = %v, want %v", tc.name, tc.password, got, tc.want)
+		}
+	}
+}
+
+func TestBreachedListLoaded(t *testing.T) {
+	if len(breached) < 100 {
+		t.Fatalf("breached list has %d entries", len(breached))
+	}
+	for entry := range breached {
+		if entry != strings.ToLower(entry) || strings.TrimSpace(entry) != entry || strings.HasPrefix(entry, "#") {
+			t.Errorf("entry %q is not normalized", entry)
+		}
+	}
+}
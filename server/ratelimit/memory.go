@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryBackend keeps counters and events in process memory. Counts are per machine and reset on restart.
+type MemoryBackend struct {
+	mu       sync.Mutex
+	counters map[counterKey]int
+	events   map[string][]time.Time
+}
+
+type counterKey struct {
+	key         string
+	windowStart time.Time
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{counters: map[counterKey]int{}, events: map[string][]time.Time{}}
+}
+
+func (b *MemoryBackend) Take(ctx context.Context, key string, windowStart time.Time, limit int) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	counter := counterKey{key: key, windowStart: windowStart.UTC()}
+	if b.counters[counter] >= limit {
+		return false, nil
+	}
+	b.counters[counter]++
+	return true, nil
+}
+
+func (b *MemoryBackend) Count(ctx context.Context, key string, windowStart time.Time) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.counters[counterKey{key: key, windowStart: windowStart.UTC()}], nil
+}
+
+func (b *MemoryBackend) Record(ctx context.Context, key string, at time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.events[key] = append(b.events[key], at)
+	return nil
+}
+
+func (b *MemoryBackend) Events(ctx context.Context, key string, since time.Time) ([]time.Time, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	events := []time.Time{}
+	for _, at := range b.events[key] {
+		if at.After(since) {
+			events = append(events, at)
+		}
+	}
+	return events, nil
+}
+
+func (b *MemoryBackend) Clear(ctx context.Context, key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.events, key)
+	return nil
+}
+
+func (b *MemoryBackend) Prune(ctx context.Context, before time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for counter := range b.counters {
+		if counter.windowStart.Before(before) {
+			delete(b.counters, counter)
+		}
+	}
+
+	for key, events := range b.events {
+		kept := events[:0]
+		for _, at := range events {
+			if !at.Before(before) {
+				kept = append(kept, at)
+			}
+		}
+		if len(kept) == 0 {
+			delete(b.events, key)
+		} else {
+			b.events[key] = kept
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresBackend keeps counters in rate_limit_counter and events in rate_limit_event so every machine
+// sees the same counts
+type PostgresBackend struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresBackend(db *pgxpool.Pool) *PostgresBackend {
+	return &PostgresBackend{db: db}
+}
+
+// Take counts the attempt in a single upsert: the conflicting row is locked, so concurrent attempts
+// are counted one after another and the ones past limit update nothing
+func (b *PostgresBackend) Take(ctx context.Context, key string, windowStart time.Time, limit int) (bool, error) {
+	var count int
+	err := b.db.QueryRow(ctx,
+		`INSERT INTO rate_limit_counter (key, window_start, count) VALUES ($1, $2, 1)
+		 ON CONFLICT (key, window_start) DO UPDATE SET count = rate_limit_counter.count + 1
+		 WHERE rate_limit_counter.count < $3
+		 RETURNING count`,
+		key, windowStart, limit,
+	).Scan(&count)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (b *PostgresBackend) Count(ctx context.Context, key string, windowStart time.Time) (int, error) {
+	var count int
+	err := b.db.QueryRow(ctx,
+		`SELECT count FROM rate_limit_counter WHERE key = $1 AND window_start = $2`,
+		key, windowStart,
+	).Scan(&count)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	return count, err
+}
+
+func (b *PostgresBackend) Record(ctx context.Context, key string, at time.Time) error {
+	_, err := b.db.Exec(ctx, `INSERT INTO rate_limit_event (key, at) VALUES ($1, $2)`, key, at)
+	return err
+}
+
+func (b *PostgresBackend) Events(ctx context.Context, key string, since time.Time) ([]time.Time, error) {
+	rows, err := b.db.Query(ctx,
+		`SELECT at FROM rate_limit_event WHERE key = $1 AND at > $2 ORDER BY at`,
+		key, since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []time.Time{}
+	for rows.Next() {
+		var at time.Time
+		if err := rows.Scan(&at); err != nil {
+			return nil, err
+		}
+		events = append(events, at)
+	}
+	return events, rows.Err()
+}
+
+func (b *PostgresBackend) Clear(ctx context.Context, key string) error {
+	_, err := b.db.Exec(ctx, `DELETE FROM rate_limit_event WHERE key = $1`, key)
+	return err
+}
+
+func (b *PostgresBackend) Prune(ctx context.Context, before time.Time) error {
+	if _, err := b.db.Exec(ctx, `DELETE FROM rate_limit_counter WHERE window_start < $1`, before); err != nil {
+		return err
+	}
+	_, err := b.db.Exec(ctx, `DELETE FROM rate_limit_event WHERE at < $1`, before)
+	return err
+}
@@ -0,0 +1,175 @@
+// Package ratelimit throttles requests with sliding windows and locks out keys after repeated failures.
+//
+// Attempts are counted per fixed window and the previous window's count is weighted by how much of it
+// still overlaps the sliding window, so a burst can't straddle a window boundary. Failures are kept as timestamped events in a Backend. The in-memory
+// backend is enough for a single machine; the Postgres backend shares counts between machines.
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"math"
+	"time"
+)
+
+// Retention is how long counters and events are kept. Lockout windows must not be longer and rule
+// windows not longer than half of it, since the previous window is still read.
+const Retention = 24 * time.Hour
+
+// Backend stores attempt counters and failure timestamps per key
+type Backend interface {
+	// Take counts an attempt for key in the window starting at windowStart unless the window already
+	// holds limit attempts, and reports whether it was counted. Concurrent calls never exceed limit.
+	Take(ctx context.Context, key string, windowStart time.Time, limit int) (bool, error)
+	// Count returns the attempts counted for key in the window starting at windowStart
+	Count(ctx context.Context, key string, windowStart time.Time) (int, error)
+	Record(ctx context.Context, key string, at time.Time) error
+	// Events returns the timestamps of key after since, oldest first
+	Events(ctx context.Context, key string, since time.Time) ([]time.Time, error)
+	Clear(ctx context.Context, key string) error
+	// Prune deletes counters and events older than before
+	Prune(ctx context.Context, before time.Time) error
+}
+
+// Rule allows Limit attempts in any sliding Window, estimated from the counts of the current and previous
+// fixed windows, which are aligned to multiples of Window. A zero Limit disables the rule.
+type Rule struct {
+	Limit  int
+	Window time.Duration
+}
+
+// Lockout locks a key once it has Threshold failures within Window. The first lock lasts Base and each
+// further failure doubles it, up to Max. Locks run from the latest failure.
+type Lockout struct {
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+	Window    time.Duration
+}
+
+// DefaultLockout locks after 5 failures for 1 minute, doubling up to 1 hour
+func DefaultLockout() Lockout {
+	return Lockout{Threshold: 5, Base: time.Minute, Max: time.Hour, Window: Retention}
+}
+
+type Limiter struct {
+	backend Backend
+	Lockout Lockout
+	// PruneInterval is how often Start deletes expired events
+	PruneInterval time.Duration
+	now           func() time.Time
+}
+
+func New(backend Backend) *Limiter {
+	return &Limiter{backend: backend, Lockout: DefaultLockout(), PruneInterval: 10 * time.Minute, now: time.Now}
+}
+
+// Allow records an attempt for key and returns zero, or, when rule is exceeded, how long to wait
+// before retrying. Rejected attempts are not recorded.
+func (l *Limiter) Allow(ctx context.Context, key string, rule Rule) (time.Duration, error) {
+	if rule.Limit <= 0 {
+		return 0, nil
+	}
+
+	now := l.now()
+	windowStart := now.Truncate(rule.Window)
+	previous, err := l.backend.Count(ctx, key, windowStart.Add(-rule.Window))
+	if err != nil {
+		return 0, err
+	}
+
+	// The previous window counts for the part of it the sliding window still covers. Its count no longer
+	// changes, so only the current window needs the atomic Take.
+	elapsed := now.Sub(windowStart)
+	weight := 1 - float64(elapsed)/float64(rule.Window)
+	budget := int(math.Ceil(float64(rule.Limit) - float64(previous)*weight))
+	if budget > 0 {
+		counted, err := l.backend.Take(ctx, key, windowStart, budget)
+		if err != nil || counted {
+			return 0, err
+		}
+	}
+	// A rejected Take means the current window holds exactly budget attempts
+	return retryAfter(rule, previous, max(budget, 0), elapsed), nil
+}
+
+// retryAfter returns how long until the weighted count of the sliding window drops below rule.Limit,
+// elapsed into a window whose previous window holds previous attempts and which holds current
+func retryAfter(rule Rule, previous, current int, elapsed time.Duration) time.Duration {
+	window := float64(rule.Window)
+	limit := float64(rule.Limit)
+
+	var at float64
+	if current < rule.Limit {
+		// Later in this window, once enough of the previous one has slid out
+		at = window * (1 - (limit-float64(current))/float64(previous))
+	} else {
+		// In the next window, once enough of this one has slid out
+		at = window + window*(1-limit/float64(current))
+	}
+	// At that moment the estimate equals the limit, so the attempt fits just after it
+	wait := time.Duration(math.Floor(at)) + 1 - elapsed
+	if wait <= 0 {
+		wait = 1
+	}
+	return wait
+}
+
+// LockedFor returns how long key stays locked out, or zero
+func (l *Limiter) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	if l.Lockout.Threshold <= 0 {
+		return 0, nil
+	}
+
+	now := l.now()
+	failures, err := l.backend.Events(ctx, failureKey(key), now.Add(-l.Lockout.Window))
+	if err != nil {
+		return 0, err
+	}
+	if len(failures) < l.Lockout.Threshold {
+		return 0, nil
+	}
+
+	lock := l.Lockout.Base
+	for i := l.Lockout.Threshold; i < len(failures) && lock < l.Lockout.Max; i++ {
+		lock *= 2
+	}
+	if lock > l.Lockout.Max {
+		lock = l.Lockout.Max
+	}
+	if remaining := failures[len(failures)-1].Add(lock).Sub(now); remaining > 0 {
+		return remaining, nil
+	}
+	return 0, nil
+}
+
+// Fail records a failed attempt for key
+func (l *Limiter) Fail(ctx context.Context, key string) error {
+	return l.backend.Record(ctx, failureKey(key), l.now())
+}
+
+// Succeed forgets the failures of key
+func (l *Limiter) Succeed(ctx context.Context, key string) error {
+	return l.backend.Clear(ctx, failureKey(key))
+}
+
+// Start deletes expired events every PruneInterval until ctx is cancelled
+func (l *Limiter) Start(ctx context.Context) {
+	ticker := time.NewTicker(l.PruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.backend.Prune(ctx, l.now().Add(-Retention)); err != nil {
+				log.Printf("Rate limit prune failed: %v", err)
+			}
+		}
+	}
+}
+
+func failureKey(key string) string {
+	return "fail:" + key
+}
@@ -0,0 +1,170 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAllowConcurrent(t *testing.T) {
+	limiter := New(NewMemoryBackend())
+	rule := Rule{Limit: 5, Window: time.Hour}
+
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wait, err := limiter.Allow(context.Background(), "sign-in:ip:1.2.3.4", rule)
+			if err != nil {
+				t.Error(err)
+			}
+			if wait == 0 {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := allowed.Load(); got != int32(rule.Limit) {
+		t.Fatalf("allowed %d attempts, want %d", got, rule.Limit)
+	}
+}
+
+func TestAllowWaitsForNextWindow(t *testing.T) {
+	limiter := New(NewMemoryBackend())
+	rule := Rule{Limit: 1, Window: time.Hour}
+	ctx := context.Background()
+
+	if wait, _ := limiter.Allow(ctx, "k", rule); wait != 0 {
+		t.Fatalf("first attempt waited %s", wait)
+	}
+	wait, err := limiter.Allow(ctx, "k", rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait <= 0 || wait > rule.Window {
+		t.Fatalf("wait = %s, want within the window", wait)
+	}
+	if wait, _ := limiter.Allow(ctx, "other", rule); wait != 0 {
+		t.Fatalf("other key waited %s", wait)
+	}
+}
+
+// clock is a settable time source for the limiter
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time { return c.now }
+
+func TestAllowSlidesAcrossWindows(t *testing.T) {
+	ctx := context.Background()
+	rule := Rule{Limit: 10, Window: time.Hour}
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	c := &clock{now: start.Add(59 * time.Minute)}
+	limiter := New(NewMemoryBackend())
+	limiter.now = c.Now
+
+	// A burst at the end of one window
+	for i := 0; i < rule.Limit; i++ {
+		if wait, _ := limiter.Allow(ctx, "k", rule); wait != 0 {
+			t.Fatalf("attempt %d waited %s", i+1, wait)
+		}
+	}
+
+	// Right after the boundary almost all of it is still inside the sliding window
+	c.now = start.Add(61 * time.Minute)
+	allowed := 0
+	for i := 0; i < rule.Limit; i++ {
+		if wait, _ := limiter.Allow(ctx, "k", rule); wait == 0 {
+			allowed++
+		}
+	}
+	if allowed != 1 {
+		t.Errorf("allowed %d attempts right after the window boundary, want 1", allowed)
+	}
+
+	// Halfway through the next window half of the previous count remains
+	c.now = start.Add(90 * time.Minute)
+	allowed = 0
+	for i := 0; i < rule.Limit; i++ {
+		if wait, _ := limiter.Allow(ctx, "k", rule); wait == 0 {
+			allowed++
+		}
+	}
+	if allowed != 4 {
+		t.Errorf("allowed %d attempts halfway through the window, want 4", allowed)
+	}
+}
+
+func TestAllowRetryAfter(t *testing.T) {
+	ctx := context.Background()
+	rule := Rule{Limit: 4, Window: time.Hour}
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	c := &clock{now: start.Add(time.Hour)}
+	backend := NewMemoryBackend()
+	limiter := New(backend)
+	limiter.now = c.Now
+
+	// Six attempts in the previous window, counted before the limit was lowered
+	for i := 0; i < 6; i++ {
+		backend.Take(ctx, "k", start, 6)
+	}
+
+	// They fit in the limit once a third of them has slid out
+	wait, err := limiter.Allow(ctx, "k", rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 20*time.Minute + 1; wait != want {
+		t.Fatalf("wait = %s, want %s", wait, want)
+	}
+
+	c.now = c.now.Add(wait - time.Second)
+	if wait, _ := limiter.Allow(ctx, "k", rule); wait == 0 {
+		t.Fatal("allowed before the wait was over")
+	}
+	c.now = c.now.Add(time.Second)
+	if wait, _ := limiter.Allow(ctx, "k", rule); wait != 0 {
+		t.Fatalf("waited %s after the wait was over", wait)
+	}
+
+	// With the current window full the wait runs into the next one
+	c.now = start.Add(2*time.Hour - time.Minute)
+	for i := 0; i < rule.Limit; i++ {
+		limiter.Allow(ctx, "k", rule)
+	}
+	if wait, _ := limiter.Allow(ctx, "k", rule); wait <= time.Minute || wait > time.Minute+rule.Window {
+		t.Errorf("wait = %s, want into the next window", wait)
+	}
+}
+
+func TestLockout(t *testing.T) {
+	limiter := New(NewMemoryBackend())
+	ctx := context.Background()
+
+	for i := 0; i < limiter.Lockout.Threshold; i++ {
+		if wait, _ := limiter.LockedFor(ctx, "k"); wait != 0 {
+			t.Fatalf("locked after %d failures", i)
+		}
+		if err := limiter.Fail(ctx, "k"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wait, err := limiter.LockedFor(ctx, "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait <= 0 || wait > limiter.Lockout.Base {
+		t.Fatalf("wait = %s, want up to %s", wait, limiter.Lockout.Base)
+	}
+
+	if err := limiter.Succeed(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if wait, _ := limiter.LockedFor(ctx, "k"); wait != 0 {
+		t.Fatalf("still locked for %s after a success", wait)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"viral-cuts-server/utils"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// encryptedColumns lists every column holding a third-party secret, keyed by table.
+// youtube_tokens is left out on purpose: the frontend still reads it directly from Supabase as plaintext.
+var encryptedColumns = []struct {
+	table   string
+	columns []string
+}{
+	{`"account"`, []string{"access_token", "refresh_token", "id_token"}},
+	{"email_outbox", []string{"html", "text_body"}},
+	{"user_settings", []string{"opus_api_key"}},
+	{"user_mfa", []string{"secret"}},
+}
+
+// rotateKeysBatchSize bounds how many rows are loaded per query
+const rotateKeysBatchSize = 500
+
+// runRotateKeys implements `server rotate-keys [-dry-run]`.
+// It re-encrypts every secret that is plaintext or sealed under an older key with the current key,
+// so old versions can be dropped from ENCRYPTION_KEYS afterwards.
+func runRotateKeys(ctx context.Context, db *pgxpool.Pool, args []string) error {
+	flags := flag.NewFlagSet("rotate-keys", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "count the values that need rotation without writing")
+	flags.Parse(args)
+
+	keyring, err := utils.SecretsKeyring()
+	if err != nil {
+		return err
+	}
+	if keyring == nil {
+		return errors.New("ENCRYPTION_KEYS is not set")
+	}
+
+	log.Printf("Rotating secrets to key version %d", keyring.CurrentVersion())
+
+	for _, target := range encryptedColumns {
+		rotated, err := rotateTable(ctx, db, keyring, target.table, target.columns, *dryRun)
+		if err != nil {
+			return fmt.Errorf("%s: %w", target.table, err)
+		}
+		if *dryRun {
+			log.Printf("%s: %d row(s) need rotation", target.table, rotated)
+		} else {
+			log.Printf("%s: %d row(s) rotated", target.table, rotated)
+		}
+	}
+	return nil
+}
+
+// rotateTable walks the table by id and rewrites rows whose secrets need rotation.
+// Each update only applies if the row still holds the values that were read, so concurrent writes win.
+func rotateTable(ctx context.Context, db *pgxpool.Pool, keyring *utils.Keyring, table string, columns []string, dryRun bool) (int, error) {
+	notNull := make([]string, len(columns))
+	for i, column := range columns {
+		notNull[i] = column + " IS NOT NULL"
+	}
+	query := fmt.Sprintf(
+		`SELECT id::text, %s FROM %s WHERE id::text > $1 AND (%s) ORDER BY id::text LIMIT %d`,
+		strings.Join(columns, ", "), table, strings.Join(notNull, " OR "), rotateKeysBatchSize,
+	)
+
+	sets := make([]string, len(columns))
+	guards := make([]string, len(columns))
+	for i, column := range columns {
+		sets[i] = fmt.Sprintf("%s = $%d", column, i+1)
+		guards[i] = fmt.Sprintf("%s IS NOT DISTINCT FROM $%d", column, len(columns)+i+1)
+	}
+	update := fmt.Sprintf(
+		`UPDATE %s SET %s WHERE id::text = $%d AND %s`,
+		table, strings.Join(sets, ", "), 2*len(columns)+1, strings.Join(guards, " AND "),
+	)
+
+	rotated := 0
+	lastID := ""
+	for {
+		type row struct {
+			id     string
+			values []*string
+		}
+
+		rows, err := db.Query(ctx, query, lastID)
+		if err != nil {
+			return rotated, err
+		}
+		batch := []row{}
+		for rows.Next() {
+			r := row{values: make([]*string, len(columns))}
+			dest := []any{&r.id}
+			for i := range r.values {
+				dest = append(dest, &r.values[i])
+			}
+			if err := rows.Scan(dest...); err != nil {
+				rows.Close()
+				return rotated, err
+			}
+			batch = append(batch, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return rotated, err
+		}
+		if len(batch) == 0 {
+			return rotated, nil
+		}
+
+		for _, r := range batch {
+			lastID = r.id
+
+			next, changed, err := rotateValues(keyring, columns, r.values)
+			if err != nil {
+				return rotated, fmt.Errorf("row %s, %w", r.id, err)
+			}
+			if !changed {
+				continue
+			}
+			if dryRun {
+				rotated++
+				continue
+			}
+
+			params := []any{}
+			for _, value := range next {
+				params = append(params, value)
+			}
+			for _, value := range r.values {
+				params = append(params, value)
+			}
+			params = append(params, r.id)
+
+			result, err := db.Exec(ctx, update, params...)
+			if err != nil {
+				return rotated, fmt.Errorf("row %s: %w", r.id, err)
+			}
+			if result.RowsAffected() > 0 {
+				rotated++
+			}
+		}
+	}
+}
+
+// rotateValues re-encrypts the values of one row that need rotation; NULLs and current values are kept
+func rotateValues(keyring *utils.Keyring, columns []string, values []*string) ([]*string, bool, error) {
+	changed := false
+	next := make([]*string, len(values))
+	for i, value := range values {
+		next[i] = value
+		if value == nil || !keyring.NeedsRotation(*value) {
+			continue
+		}
+		encrypted, err := keyring.Rotate(*value)
+		if err != nil {
+			return nil, false, fmt.Errorf("%s: %w", columns[i], err)
+		}
+		next[i] = &encrypted
+		changed = true
+	}
+	return next, changed, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"viral-cuts-server/utils"
+)
+
+func TestRotateValues(t *testing.T) {
+	keys := map[int][]byte{1: bytes.Repeat([]byte{1}, 32), 2: bytes.Repeat([]byte{2}, 32)}
+	v1, _ := utils.NewKeyring(1, keys)
+	keyring, _ := utils.NewKeyring(2, keys)
+
+	old, _ := v1.Encrypt("refresh-1")
+	current, _ := keyring.Encrypt("id-token")
+	plaintext := "access-1"
+	columns := []string{"access_token", "refresh_token", "id_token", "scope"}
+
+	next, changed, err := rotateValues(keyring, columns, []*string{&plaintext, &old, &current, nil})
+	if err != nil || !changed {
+		t.Fatalf("rotateValues = %v, %v", changed, err)
+	}
+	if next[2] != &current || next[3] != nil {
+		t.Error("values that don't need rotation were rewritten")
+	}
+	for i, want := range []string{"access-1", "refresh-1"} {
+		if !strings.HasPrefix(*next[i], "enc:v2:") {
+			t.Errorf("%s = %q, want a v2 value", columns[i], *next[i])
+		}
+		if got, _ := keyring.Decrypt(*next[i]); got != want {
+			t.Errorf("%s decrypts to %q, want %q", columns[i], got, want)
+		}
+	}
+
+	if _, changed, _ := rotateValues(keyring, columns[:1], []*string{&current}); changed {
+		t.Error("a row already on the current key was reported as changed")
+	}
+
+	unknown, _ := utils.NewKeyring(3, map[int][]byte{3: bytes.Repeat([]byte{3}, 32)})
+	if _, _, err := rotateValues(unknown, columns[:1], []*string{&old}); err == nil || !strings.Contains(err.Error(), "access_token") {
+		t.Errorf("err = %v, want an error naming the column", err)
+	}
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when the video file no longer exists in storage
+var ErrNotFound = errors.New("video file not found in storage")
+
+// ErrUntrustedURL is returned for file URLs that are not on the storage origin
+var ErrUntrustedURL = errors.New("file url is not in the video storage")
+
+// Source reads uploaded videos from Supabase Storage (or any HTTP server supporting range requests)
+type Source struct {
+	HTTPClient *http.Client
+	// Origin is the storage server (e.g. https://<project>.supabase.co) that user-supplied file URLs
+	// must point to. Other URLs, such as Opus clip downloads, are fetched without credentials.
+	Origin string
+	// Token is sent as a bearer token to Origin when downloading from a private bucket
+	Token string
+}
+
+// NewSource reads SUPABASE_URL, and SUPABASE_SERVICE_ROLE_KEY for private buckets
+func NewSource() *Source {
+	s := &Source{
+		Origin: os.Getenv("SUPABASE_URL"),
+		Token:  os.Getenv("SUPABASE_SERVICE_ROLE_KEY"),
+	}
+	s.HTTPClient = &http.Client{Timeout: 10 * time.Minute, CheckRedirect: s.checkRedirect}
+	return s
+}
+
+// CheckURL returns ErrUntrustedURL unless fileURL is on Origin, so users can't make the server
+// fetch arbitrary URLs
+func (s *Source) CheckURL(fileURL string) error {
+	u, err := url.Parse(fileURL)
+	if err != nil || !s.trusted(u) {
+		return ErrUntrustedURL
+	}
+	return nil
+}
+
+// Size returns the size in bytes of the file at fileURL
+func (s *Source) Size(ctx context.Context, fileURL string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", fileURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("invalid file url: %w", err)
+	}
+	s.authorize(req)
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reach video file: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK || resp.ContentLength <= 0 {
+		return 0, fmt.Errorf("failed to read video file size (status %d)", resp.StatusCode)
+	}
+	return resp.ContentLength, nil
+}
+
+// ReadRange downloads the inclusive byte range [start, end] of the file at fileURL
+func (s *Source) ReadRange(ctx context.Context, fileURL string, start, end int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fileURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file url: %w", err)
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	s.authorize(req)
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download video chunk: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("storage did not honour range request (status %d)", resp.StatusCode)
+	}
+
+	want := end - start + 1
+	chunk, err := io.ReadAll(io.LimitReader(resp.Body, want))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download video chunk: %w", err)
+	}
+	if int64(len(chunk)) != want {
+		return nil, fmt.Errorf("short read from storage: got %d bytes, want %d", len(chunk), want)
+	}
+	return chunk, nil
+}
+
+// authorize adds the service role key to requests for Origin only
+func (s *Source) authorize(req *http.Request) {
+	if s.Token != "" && s.trusted(req.URL) {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+}
+
+// checkRedirect drops the service role key when Origin redirects to another server
+func (s *Source) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if !s.trusted(req.URL) {
+		req.Header.Del("Authorization")
+	}
+	return nil
+}
+
+// trusted reports whether u is on Origin, comparing scheme, host and port
+func (s *Source) trusted(u *url.URL) bool {
+	origin, err := url.Parse(s.Origin)
+	if err != nil || u.User != nil {
+		return false
+	}
+	want := originOf(origin)
+	return want != "" && originOf(u) == want
+}
+
+// originOf returns scheme://host:port with the default port filled in, or "" for non-HTTP URLs
+func originOf(u *url.URL) string {
+	scheme := strings.ToLower(u.Scheme)
+	if (scheme != "http" && scheme != "https") || u.Hostname() == "" {
+		return ""
+	}
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[scheme]
+	}
+	return scheme + "://" + net.JoinHostPort(strings.ToLower(u.Hostname()), port)
+}
@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeStorage serves one file with range support and records the Authorization headers it receives
+type fakeStorage struct {
+	*httptest.Server
+	mu    sync.Mutex
+	auths []string
+}
+
+func newFakeStorage(t *testing.T, content []byte) *fakeStorage {
+	t.Helper()
+	f := &fakeStorage{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.auths = append(f.auths, r.Header.Get("Authorization"))
+		f.mu.Unlock()
+
+		switch r.URL.Path {
+		case "/videos/clip.mp4":
+			http.ServeContent(w, r, "clip.mp4", time.Time{}, bytes.NewReader(content))
+		case "/videos/moved.mp4":
+			http.Redirect(w, r, r.URL.Query().Get("to"), http.StatusFound)
+		case "/videos/no-range.mp4":
+			w.Write(content)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeStorage) authorizations() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.auths...)
+}
+
+func TestCheckURL(t *testing.T) {
+	source := &Source{Origin: "https://project.supabase.co"}
+
+	cases := []struct {
+		url     string
+		trusted bool
+	}{
+		{"https://project.supabase.co/storage/v1/object/public/videos/a.mp4", true},
+		{"https://PROJECT.supabase.co:443/storage/v1/object/videos/a.mp4", true},
+		{"http://project.supabase.co/storage/v1/object/videos/a.mp4", false},
+		{"https://project.supabase.co:8443/storage/v1/object/videos/a.mp4", false},
+		{"https://other.supabase.co/storage/v1/object/videos/a.mp4", false},
+		{"https://project.supabase.co.attacker.example.com/a.mp4", false},
+		{"https://key@project.supabase.co/storage/v1/object/videos/a.mp4", false},
+		{"http://169.254.169.254/latest/meta-data/", false},
+		{"file:///etc/passwd", false},
+		{"/storage/v1/object/videos/a.mp4", false},
+		{"", false},
+	}
+	for _, tc := range cases {
+		err := source.CheckURL(tc.url)
+		if tc.trusted && err != nil {
+			t.Errorf("CheckURL(%q) = %v, want nil", tc.url, err)
+		}
+		if !tc.trusted && !errors.Is(err, ErrUntrustedURL) {
+			t.Errorf("CheckURL(%q) = %v, want ErrUntrustedURL", tc.url, err)
+		}
+	}
+
+	// Without a configured origin nothing is trusted
+	if err := (&Source{}).CheckURL("https://project.supabase.co/a.mp4"); !errors.Is(err, ErrUntrustedURL) {
+		t.Errorf("CheckURL without origin = %v, want ErrUntrustedURL", err)
+	}
+}
+
+func TestTokenOnlySentToOrigin(t *testing.T) {
+	content := []byte("0123456789")
+	storage := newFakeStorage(t, content)
+	elsewhere := newFakeStorage(t, content)
+	source := &Source{HTTPClient: storage.Client(), Origin: storage.URL, Token: "service-role-key"}
+	ctx := context.Background()
+
+	for _, server := range []*fakeStorage{storage, elsewhere} {
+		if _, err := source.Size(ctx, server.URL+"/videos/clip.mp4"); err != nil {
+			t.Fatalf("Size: %v", err)
+		}
+		if _, err := source.ReadRange(ctx, server.URL+"/videos/clip.mp4", 0, 3); err != nil {
+			t.Fatalf("ReadRange: %v", err)
+		}
+	}
+
+	for _, auth := range storage.authorizations() {
+		if auth != "Bearer service-role-key" {
+			t.Errorf("storage got Authorization %q", auth)
+		}
+	}
+	for _, auth := range elsewhere.authorizations() {
+		if auth != "" {
+			t.Errorf("another server got Authorization %q", auth)
+		}
+	}
+}
+
+func TestTokenDroppedOnRedirect(t *testing.T) {
+	content := []byte("0123456789")
+	storage := newFakeStorage(t, content)
+	opus := newFakeStorage(t, content)
+	source := &Source{Origin: storage.URL, Token: "service-role-key"}
+	source.HTTPClient = &http.Client{CheckRedirect: source.checkRedirect}
+
+	moved := storage.URL + "/videos/moved.mp4?to=" + url.QueryEscape(opus.URL+"/videos/clip.mp4")
+	chunk, err := source.ReadRange(context.Background(), moved, 0, 3)
+	if err != nil || string(chunk) != "0123" {
+		t.Fatalf("ReadRange = %q, %v", chunk, err)
+	}
+	if auths := storage.authorizations(); len(auths) != 1 || auths[0] != "Bearer service-role-key" {
+		t.Errorf("storage got Authorization %q", auths)
+	}
+	if auths := opus.authorizations(); len(auths) != 1 || auths[0] != "" {
+		t.Errorf("redirect target got Authorization %q", auths)
+	}
+}
+
+func TestSizeAndReadRange(t *testing.T) {
+	content := []byte(strings.Repeat("abcdefghij", 10))
+	storage := newFakeStorage(t, content)
+	source := &Source{HTTPClient: storage.Client(), Origin: storage.URL}
+	ctx := context.Background()
+
+	size, err := source.Size(ctx, storage.URL+"/videos/clip.mp4")
+	if err != nil || size != int64(len(content)) {
+		t.Fatalf("Size = %d, %v, want %d", size, err, len(content))
+	}
+	chunk, err := source.ReadRange(ctx, storage.URL+"/videos/clip.mp4", 10, 24)
+	if err != nil || !bytes.Equal(chunk, content[10:25]) {
+		t.Fatalf("ReadRange = %q, %v, want %q", chunk, err, content[10:25])
+	}
+
+	if _, err := source.Size(ctx, storage.URL+"/videos/missing.mp4"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Size of a missing file: err = %v, want ErrNotFound", err)
+	}
+	if _, err := source.ReadRange(ctx, storage.URL+"/videos/missing.mp4", 0, 9); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ReadRange of a missing file: err = %v, want ErrNotFound", err)
+	}
+	if _, err := source.ReadRange(ctx, storage.URL+"/videos/no-range.mp4", 0, 9); err == nil {
+		t.Error("ReadRange accepted a response that ignored the range")
+	}
+	if _, err := source.ReadRange(ctx, storage.URL+"/videos/clip.mp4", 95, 120); err == nil {
+		t.Error("ReadRange accepted a short read")
+	}
+}
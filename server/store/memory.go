@@ -0,0 +1,1002 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"viral-cuts-server/models"
+	"viral-cuts-server/utils"
+)
+
+// memoryDB holds every table of the in-memory stores behind one lock
+type memoryDB struct {
+	// txMu serialises transactions; mu guards the maps for single operations
+	txMu          sync.Mutex
+	mu            sync.Mutex
+	users         map[string]models.User
+	sessions      map[string]models.Session // by token
+	verifications map[string]models.Verification
+	accounts      map[string]models.Account
+	roleChanges   []models.RoleChange
+	queue         map[string]models.UploadQueueItem
+	mfa           map[string]models.UserMFA // by user ID
+	recoveryCodes []memoryRecoveryCode
+	outbox        map[string]models.OutboxEmail
+	emailChanges  map[string]models.EmailChange // by user ID
+}
+
+type memoryRecoveryCode struct {
+	userID   string
+	codeHash string
+	used     bool
+}
+
+// NewMemory returns stores kept in process memory, for tests and local experiments.
+// Values are copied in and out so callers can't mutate stored rows.
+func NewMemory() *Store {
+	db := &memoryDB{
+		users:         map[string]models.User{},
+		sessions:      map[string]models.Session{},
+		verifications: map[string]models.Verification{},
+		accounts:      map[string]models.Account{},
+		queue:         map[string]models.UploadQueueItem{},
+		mfa:           map[string]models.UserMFA{},
+		outbox:        map[string]models.OutboxEmail{},
+		emailChanges:  map[string]models.EmailChange{},
+	}
+	s := db.stores()
+	s.inTx = db.inTx
+	return s
+}
+
+func (db *memoryDB) stores() *Store {
+	return &Store{
+		Users:         &memoryUserStore{db},
+		Sessions:      &memorySessionStore{db},
+		Verifications: &memoryVerificationStore{db},
+		Accounts:      &memoryAccountStore{db},
+		Queue:         &memoryQueueStore{db},
+		MFA:           &memoryMFAStore{db},
+		Outbox:        &memoryOutboxStore{db},
+		EmailChanges:  &memoryEmailChangeStore{db},
+	}
+}
+
+// inTx snapshots the tables and restores them when fn fails.
+// Writes made outside the transaction while it runs are lost on rollback, which is fine for tests.
+func (db *memoryDB) inTx(ctx context.Context, fn func(tx *Store) error) error {
+	db.txMu.Lock()
+	defer db.txMu.Unlock()
+
+	db.mu.Lock()
+	snapshot := db.clone()
+	db.mu.Unlock()
+
+	if err := fn(db.stores()); err != nil {
+		db.mu.Lock()
+		db.users = snapshot.users
+		db.sessions = snapshot.sessions
+		db.verifications = snapshot.verifications
+		db.accounts = snapshot.accounts
+		db.roleChanges = snapshot.roleChanges
+		db.queue = snapshot.queue
+		db.mfa = snapshot.mfa
+		db.recoveryCodes = snapshot.recoveryCodes
+		db.outbox = snapshot.outbox
+		db.emailChanges = snapshot.emailChanges
+		db.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (db *memoryDB) clone() *memoryDB {
+	c := &memoryDB{
+		users:         make(map[string]models.User, len(db.users)),
+		sessions:      make(map[string]models.Session, len(db.sessions)),
+		verifications: make(map[string]models.Verification, len(db.verifications)),
+		accounts:      make(map[string]models.Account, len(db.accounts)),
+		roleChanges:   append([]models.RoleChange(nil), db.roleChanges...),
+		queue:         make(map[string]models.UploadQueueItem, len(db.queue)),
+		mfa:           make(map[string]models.UserMFA, len(db.mfa)),
+		recoveryCodes: append([]memoryRecoveryCode(nil), db.recoveryCodes...),
+		outbox:        make(map[string]models.OutboxEmail, len(db.outbox)),
+		emailChanges:  make(map[string]models.EmailChange, len(db.emailChanges)),
+	}
+	for k, v := range db.users {
+		c.users[k] = v
+	}
+	for k, v := range db.sessions {
+		c.sessions[k] = v
+	}
+	for k, v := range db.verifications {
+		c.verifications[k] = v
+	}
+	for k, v := range db.accounts {
+		c.accounts[k] = v
+	}
+	for k, v := range db.queue {
+		c.queue[k] = v
+	}
+	for k, v := range db.mfa {
+		c.mfa[k] = v
+	}
+	for k, v := range db.outbox {
+		c.outbox[k] = v
+	}
+	for k, v := range db.emailChanges {
+		c.emailChanges[k] = v
+	}
+	return c
+}
+
+type memoryUserStore struct {
+	db *memoryDB
+}
+
+func (s *memoryUserStore) Create(ctx context.Context, user *models.User) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if _, exists := s.db.users[user.ID]; exists {
+		return ErrDuplicate
+	}
+	for _, existing := range s.db.users {
+		if existing.Email == user.Email {
+			return ErrDuplicate
+		}
+	}
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+	if user.Locale == "" {
+		user.Locale = models.DefaultLocale
+	}
+	s.db.users[user.ID] = *user
+	return nil
+}
+
+func (s *memoryUserStore) GetByID(ctx context.Context, id string) (*models.User, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	user, ok := s.db.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (s *memoryUserStore) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for _, user := range s.db.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryUserStore) List(ctx context.Context, filter UserFilter) ([]*models.User, int, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	search := strings.ToLower(filter.Search)
+	matches := []*models.User{}
+	for _, user := range s.db.users {
+		if search != "" && !strings.Contains(strings.ToLower(user.Name), search) &&
+			!strings.Contains(strings.ToLower(user.Email), search) {
+			continue
+		}
+		user := user
+		matches = append(matches, &user)
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].CreatedAt.After(matches[j].CreatedAt) })
+
+	total := len(matches)
+	if filter.Offset >= total {
+		return []*models.User{}, total, nil
+	}
+	matches = matches[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(matches) {
+		matches = matches[:filter.Limit]
+	}
+	return matches, total, nil
+}
+
+func (s *memoryUserStore) MarkEmailVerified(ctx context.Context, email string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for id, user := range s.db.users {
+		if user.Email == email {
+			user.EmailVerified = true
+			user.UpdatedAt = time.Now()
+			s.db.users[id] = user
+		}
+	}
+	return nil
+}
+
+func (s *memoryUserStore) UpdateEmail(ctx context.Context, userID, email string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	user, ok := s.db.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	for id, existing := range s.db.users {
+		if id != userID && existing.Email == email {
+			return ErrDuplicate
+		}
+	}
+	user.Email = email
+	user.EmailVerified = true
+	user.UpdatedAt = time.Now()
+	s.db.users[userID] = user
+	return nil
+}
+
+func (s *memoryUserStore) UpdateLocale(ctx context.Context, userID, locale string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	user, ok := s.db.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	user.Locale = locale
+	user.UpdatedAt = time.Now()
+	s.db.users[userID] = user
+	return nil
+}
+
+func (s *memoryUserStore) UpdateRole(ctx context.Context, userID, role, changedBy string) (string, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	user, ok := s.db.users[userID]
+	if !ok {
+		return "", ErrNotFound
+	}
+	oldRole := user.Role
+	if oldRole == role {
+		return oldRole, nil
+	}
+
+	now := time.Now()
+	user.Role = role
+	user.UpdatedAt = now
+	s.db.users[userID] = user
+
+	s.db.roleChanges = append(s.db.roleChanges, models.RoleChange{
+		ID:        utils.GenerateID(),
+		UserID:    userID,
+		ChangedBy: &changedBy,
+		OldRole:   oldRole,
+		NewRole:   role,
+		CreatedAt: now,
+	})
+	return oldRole, nil
+}
+
+func (s *memoryUserStore) RoleChanges(ctx context.Context, userID string, limit int) ([]*models.RoleChange, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	changes := []*models.RoleChange{}
+	for i := len(s.db.roleChanges) - 1; i >= 0 && len(changes) < limit; i-- {
+		change := s.db.roleChanges[i]
+		if userID == "" || change.UserID == userID {
+			changes = append(changes, &change)
+		}
+	}
+	return changes, nil
+}
+
+type memorySessionStore struct {
+	db *memoryDB
+}
+
+func (s *memorySessionStore) Create(ctx context.Context, session *models.Session) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if _, exists := s.db.sessions[session.Token]; exists {
+		return ErrDuplicate
+	}
+	s.db.sessions[session.Token] = *session
+	return nil
+}
+
+func (s *memorySessionStore) GetByToken(ctx context.Context, token string) (*models.Session, *models.User, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	session, ok := s.db.sessions[token]
+	if !ok || !session.ExpiresAt.After(time.Now()) {
+		return nil, nil, ErrNotFound
+	}
+	user, ok := s.db.users[session.UserID]
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+	return &session, &user, nil
+}
+
+func (s *memorySessionStore) ListByUser(ctx context.Context, userID string) ([]*models.Session, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	now := time.Now()
+	sessions := []*models.Session{}
+	for _, session := range s.db.sessions {
+		if session.UserID == userID && session.ExpiresAt.After(now) {
+			session := session
+			sessions = append(sessions, &session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt) })
+	return sessions, nil
+}
+
+func (s *memorySessionStore) DeleteByToken(ctx context.Context, token string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	delete(s.db.sessions, token)
+	return nil
+}
+
+func (s *memorySessionStore) Delete(ctx context.Context, userID, id string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for token, session := range s.db.sessions {
+		if session.ID == id && session.UserID == userID {
+			delete(s.db.sessions, token)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memorySessionStore) DeleteByUser(ctx context.Context, userID string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for token, session := range s.db.sessions {
+		if session.UserID == userID {
+			delete(s.db.sessions, token)
+		}
+	}
+	return nil
+}
+
+func (s *memorySessionStore) DeleteOthers(ctx context.Context, userID, keepID string) (int, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	removed := 0
+	for token, session := range s.db.sessions {
+		if session.UserID == userID && session.ID != keepID {
+			delete(s.db.sessions, token)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (s *memorySessionStore) Touch(ctx context.Context, id string, lastActivity, expiresAt time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for token, session := range s.db.sessions {
+		if session.ID == id {
+			session.UpdatedAt = lastActivity
+			session.ExpiresAt = expiresAt
+			s.db.sessions[token] = session
+		}
+	}
+	return nil
+}
+
+func (s *memorySessionStore) DeleteExpired(ctx context.Context, createdBefore time.Time) (int, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	now := time.Now()
+	removed := 0
+	for token, session := range s.db.sessions {
+		if !session.ExpiresAt.After(now) || session.CreatedAt.Before(createdBefore) {
+			delete(s.db.sessions, token)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+type memoryVerificationStore struct {
+	db *memoryDB
+}
+
+func (s *memoryVerificationStore) Create(ctx context.Context, verification *models.Verification) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if _, exists := s.db.verifications[verification.ID]; exists {
+		return ErrDuplicate
+	}
+	s.db.verifications[verification.ID] = *verification
+	return nil
+}
+
+func (s *memoryVerificationStore) Get(ctx context.Context, purpose, value string) (*models.Verification, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	now := time.Now()
+	for _, verification := range s.db.verifications {
+		if verification.Purpose == purpose && verification.Value == value && verification.ExpiresAt.After(now) {
+			return &verification, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryVerificationStore) Consume(ctx context.Context, purpose, value string) (*models.Verification, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	now := time.Now()
+	for id, verification := range s.db.verifications {
+		if verification.Purpose == purpose && verification.Value == value && verification.ExpiresAt.After(now) {
+			delete(s.db.verifications, id)
+			return &verification, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryVerificationStore) Delete(ctx context.Context, id string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	delete(s.db.verifications, id)
+	return nil
+}
+
+func (s *memoryVerificationStore) DeleteByIdentifier(ctx context.Context, purpose, identifier string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for id, verification := range s.db.verifications {
+		if verification.Purpose == purpose && verification.Identifier == identifier {
+			delete(s.db.verifications, id)
+		}
+	}
+	return nil
+}
+
+type memoryAccountStore struct {
+	db *memoryDB
+}
+
+func (s *memoryAccountStore) Create(ctx context.Context, account *models.Account) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if _, exists := s.db.accounts[account.ID]; exists {
+		return ErrDuplicate
+	}
+	s.db.accounts[account.ID] = *account
+	return nil
+}
+
+func (s *memoryAccountStore) GetCredential(ctx context.Context, userID string) (*models.Account, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for _, account := range s.db.accounts {
+		if account.UserID == userID && account.ProviderID == CredentialProvider {
+			return &account, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryAccountStore) UpdatePassword(ctx context.Context, userID, passwordHash string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for id, account := range s.db.accounts {
+		if account.UserID == userID && account.ProviderID == CredentialProvider {
+			account.Password = &passwordHash
+			account.UpdatedAt = time.Now()
+			s.db.accounts[id] = account
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryAccountStore) GetByProvider(ctx context.Context, providerID, accountID string) (*models.Account, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for _, account := range s.db.accounts {
+		if account.ProviderID == providerID && account.AccountID == accountID {
+			return &account, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryAccountStore) DeleteCredential(ctx context.Context, userID string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for id, account := range s.db.accounts {
+		if account.UserID == userID && account.ProviderID == CredentialProvider {
+			delete(s.db.accounts, id)
+		}
+	}
+	return nil
+}
+
+func (s *memoryAccountStore) UpdateCredentialEmail(ctx context.Context, userID, email string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for id, account := range s.db.accounts {
+		if account.UserID == userID && account.ProviderID == CredentialProvider {
+			account.AccountID = email
+			account.UpdatedAt = time.Now()
+			s.db.accounts[id] = account
+		}
+	}
+	return nil
+}
+
+type memoryMFAStore struct {
+	db *memoryDB
+}
+
+func (s *memoryMFAStore) Get(ctx context.Context, userID string) (*models.UserMFA, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	mfa, ok := s.db.mfa[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &mfa, nil
+}
+
+func (s *memoryMFAStore) SetPending(ctx context.Context, mfa *models.UserMFA) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	pending := *mfa
+	if existing, ok := s.db.mfa[mfa.UserID]; ok {
+		pending.ID = existing.ID
+		pending.CreatedAt = existing.CreatedAt
+	}
+	pending.EnabledAt = nil
+	pending.LastUsedStep = 0
+	pending.FailedAttempts = 0
+	s.db.mfa[mfa.UserID] = pending
+	return nil
+}
+
+func (s *memoryMFAStore) Enable(ctx context.Context, userID string, step int64) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	mfa, ok := s.db.mfa[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	now := time.Now()
+	mfa.EnabledAt = &now
+	mfa.LastUsedStep = step
+	mfa.FailedAttempts = 0
+	mfa.UpdatedAt = now
+	s.db.mfa[userID] = mfa
+	return nil
+}
+
+func (s *memoryMFAStore) AcceptStep(ctx context.Context, userID string, step int64) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	mfa, ok := s.db.mfa[userID]
+	if !ok || mfa.LastUsedStep >= step {
+		return ErrNotFound
+	}
+	mfa.LastUsedStep = step
+	mfa.FailedAttempts = 0
+	mfa.UpdatedAt = time.Now()
+	s.db.mfa[userID] = mfa
+	return nil
+}
+
+func (s *memoryMFAStore) RecordFailure(ctx context.Context, userID string) (int, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	mfa, ok := s.db.mfa[userID]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	mfa.FailedAttempts++
+	mfa.UpdatedAt = time.Now()
+	s.db.mfa[userID] = mfa
+	return mfa.FailedAttempts, nil
+}
+
+func (s *memoryMFAStore) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	kept := []memoryRecoveryCode{}
+	for _, code := range s.db.recoveryCodes {
+		if code.userID != userID {
+			kept = append(kept, code)
+		}
+	}
+	for _, hash := range codeHashes {
+		kept = append(kept, memoryRecoveryCode{userID: userID, codeHash: hash})
+	}
+	s.db.recoveryCodes = kept
+	return nil
+}
+
+func (s *memoryMFAStore) UseRecoveryCode(ctx context.Context, userID, codeHash string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for i, code := range s.db.recoveryCodes {
+		if code.userID == userID && code.codeHash == codeHash && !code.used {
+			s.db.recoveryCodes[i].used = true
+			if mfa, ok := s.db.mfa[userID]; ok {
+				mfa.FailedAttempts = 0
+				s.db.mfa[userID] = mfa
+			}
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryMFAStore) RecoveryCodesLeft(ctx context.Context, userID string) (int, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	count := 0
+	for _, code := range s.db.recoveryCodes {
+		if code.userID == userID && !code.used {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *memoryMFAStore) Delete(ctx context.Context, userID string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	delete(s.db.mfa, userID)
+	kept := []memoryRecoveryCode{}
+	for _, code := range s.db.recoveryCodes {
+		if code.userID != userID {
+			kept = append(kept, code)
+		}
+	}
+	s.db.recoveryCodes = kept
+	return nil
+}
+
+type memoryEmailChangeStore struct {
+	db *memoryDB
+}
+
+func (s *memoryEmailChangeStore) Set(ctx context.Context, change *models.EmailChange) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	s.db.emailChanges[change.UserID] = *change
+	return nil
+}
+
+func (s *memoryEmailChangeStore) Get(ctx context.Context, userID string) (*models.EmailChange, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	change, ok := s.db.emailChanges[userID]
+	if !ok || !change.ExpiresAt.After(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return &change, nil
+}
+
+func (s *memoryEmailChangeStore) Delete(ctx context.Context, userID string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	delete(s.db.emailChanges, userID)
+	return nil
+}
+
+type memoryOutboxStore struct {
+	db *memoryDB
+}
+
+func (s *memoryOutboxStore) Enqueue(ctx context.Context, email *models.OutboxEmail) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if _, ok := s.db.outbox[email.ID]; ok {
+		return ErrDuplicate
+	}
+	if email.Status == "" {
+		email.Status = models.OutboxStatusPending
+	}
+	stored := *email
+	stored.Attempts = 0
+	s.db.outbox[email.ID] = stored
+	return nil
+}
+
+func (s *memoryOutboxStore) ClaimDue(ctx context.Context, limit int, staleBefore time.Time) ([]*models.OutboxEmail, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	now := time.Now()
+	due := []models.OutboxEmail{}
+	for _, email := range s.db.outbox {
+		pending := email.Status == models.OutboxStatusPending && !email.NextAttemptAt.After(now)
+		stale := email.Status == models.OutboxStatusSending && email.ClaimedAt != nil && email.ClaimedAt.Before(staleBefore)
+		if pending || stale {
+			due = append(due, email)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(due[j].NextAttemptAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := []*models.OutboxEmail{}
+	for _, email := range due {
+		email.Status = models.OutboxStatusSending
+		email.ClaimedAt = &now
+		email.Attempts++
+		email.UpdatedAt = now
+		s.db.outbox[email.ID] = email
+		claimed = append(claimed, &email)
+	}
+	return claimed, nil
+}
+
+// updateClaimed applies fn to an email still held by the claim made at claimedAt
+func (s *memoryOutboxStore) updateClaimed(id string, claimedAt time.Time, fn func(email *models.OutboxEmail)) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	email, ok := s.db.outbox[id]
+	if !ok || email.Status != models.OutboxStatusSending || email.ClaimedAt == nil || !email.ClaimedAt.Equal(claimedAt) {
+		return ErrNotFound
+	}
+	fn(&email)
+	email.UpdatedAt = time.Now()
+	s.db.outbox[id] = email
+	return nil
+}
+
+func (s *memoryOutboxStore) MarkSent(ctx context.Context, id string, claimedAt time.Time) error {
+	return s.updateClaimed(id, claimedAt, func(email *models.OutboxEmail) {
+		now := time.Now()
+		email.Status = models.OutboxStatusSent
+		email.HTML = ""
+		email.Text = ""
+		email.LastError = nil
+		email.ClaimedAt = nil
+		email.SentAt = &now
+	})
+}
+
+func (s *memoryOutboxStore) Reschedule(ctx context.Context, id string, claimedAt, nextAttempt time.Time, lastError string) error {
+	return s.updateClaimed(id, claimedAt, func(email *models.OutboxEmail) {
+		email.Status = models.OutboxStatusPending
+		email.NextAttemptAt = nextAttempt
+		email.LastError = &lastError
+		email.ClaimedAt = nil
+	})
+}
+
+func (s *memoryOutboxStore) MarkDead(ctx context.Context, id string, claimedAt time.Time, lastError string) error {
+	return s.updateClaimed(id, claimedAt, func(email *models.OutboxEmail) {
+		email.Status = models.OutboxStatusDead
+		email.LastError = &lastError
+		email.ClaimedAt = nil
+	})
+}
+
+func (s *memoryOutboxStore) List(ctx context.Context, filter OutboxFilter) ([]*models.OutboxEmail, int, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	matched := []models.OutboxEmail{}
+	for _, email := range s.db.outbox {
+		if filter.Status == "" || email.Status == filter.Status {
+			email.HTML = ""
+			email.Text = ""
+			matched = append(matched, email)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].CreatedAt.After(matched[j].CreatedAt) })
+
+	emails := []*models.OutboxEmail{}
+	for i := filter.Offset; i < len(matched) && i < filter.Offset+filter.Limit; i++ {
+		emails = append(emails, &matched[i])
+	}
+	return emails, len(matched), nil
+}
+
+func (s *memoryOutboxStore) Retry(ctx context.Context, id string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	email, ok := s.db.outbox[id]
+	if !ok || email.Status != models.OutboxStatusDead {
+		return ErrNotFound
+	}
+	now := time.Now()
+	email.Status = models.OutboxStatusPending
+	email.Attempts = 0
+	email.NextAttemptAt = now
+	email.UpdatedAt = now
+	s.db.outbox[id] = email
+	return nil
+}
+
+func (s *memoryOutboxStore) DeleteSent(ctx context.Context, sentBefore time.Time) (int, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	removed := 0
+	for id, email := range s.db.outbox {
+		if email.Status == models.OutboxStatusSent && email.SentAt != nil && email.SentAt.Before(sentBefore) {
+			delete(s.db.outbox, id)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// memoryQueueStore has no user settings, so items created without a privacy status are private
+type memoryQueueStore struct {
+	db *memoryDB
+}
+
+func (s *memoryQueueStore) List(ctx context.Context, userID, status string) ([]*models.UploadQueueItem, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	matched := []models.UploadQueueItem{}
+	for _, item := range s.db.queue {
+		if item.UserID == userID && (status == "" || item.Status == status) {
+			matched = append(matched, item)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].CreatedAt.Before(matched[j].CreatedAt) })
+
+	items := []*models.UploadQueueItem{}
+	for i := range matched {
+		items = append(items, &matched[i])
+	}
+	return items, nil
+}
+
+func (s *memoryQueueStore) Get(ctx context.Context, userID, id string) (*models.UploadQueueItem, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	item, ok := s.db.queue[id]
+	if !ok || item.UserID != userID {
+		return nil, ErrNotFound
+	}
+	return &item, nil
+}
+
+func (s *memoryQueueStore) Create(ctx context.Context, item *models.UploadQueueItem) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if _, ok := s.db.queue[item.ID]; ok {
+		return ErrDuplicate
+	}
+	now := time.Now()
+	item.Status = models.QueueStatusReady
+	if item.PrivacyStatus == "" {
+		item.PrivacyStatus = "private"
+	}
+	item.Attempts = 0
+	item.CreatedAt = now
+	item.UpdatedAt = now
+	s.db.queue[item.ID] = *item
+	return nil
+}
+
+// update applies fn to an item of userID that allowed accepts
+func (s *memoryQueueStore) update(userID, id string, allowed func(item *models.UploadQueueItem) bool, fn func(item *models.UploadQueueItem)) (*models.UploadQueueItem, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	item, ok := s.db.queue[id]
+	if !ok || item.UserID != userID || !allowed(&item) {
+		return nil, ErrNotFound
+	}
+	fn(&item)
+	item.UpdatedAt = time.Now()
+	s.db.queue[id] = item
+	return &item, nil
+}
+
+func (s *memoryQueueStore) Update(ctx context.Context, userID, id string, update QueueItemUpdate) (*models.UploadQueueItem, error) {
+	editable := func(item *models.UploadQueueItem) bool {
+		return item.Status == models.QueueStatusReady || item.Status == models.QueueStatusError
+	}
+	return s.update(userID, id, editable, func(item *models.UploadQueueItem) {
+		if update.Title != nil {
+			item.Title = *update.Title
+		}
+		if update.Description != nil {
+			item.Description = update.Description
+		}
+		if update.Platform != nil {
+			item.Platform = update.Platform
+		}
+		if update.ScheduledAt != nil {
+			item.ScheduledAt = update.ScheduledAt
+		}
+		if update.PublishAt != nil {
+			item.PublishAt = update.PublishAt
+		}
+		if update.PrivacyStatus != nil {
+			item.PrivacyStatus = *update.PrivacyStatus
+		}
+	})
+}
+
+func (s *memoryQueueStore) SetStatus(ctx context.Context, userID, id, from, to string, errorMessage *string) (*models.UploadQueueItem, error) {
+	inFrom := func(item *models.UploadQueueItem) bool { return item.Status == from }
+	return s.update(userID, id, inFrom, func(item *models.UploadQueueItem) {
+		item.Status = to
+		item.ErrorMessage = nil
+		if to == models.QueueStatusError {
+			item.ErrorMessage = errorMessage
+		}
+		if to == models.QueueStatusReady {
+			item.Attempts = 0
+		}
+		item.NextAttemptAt = nil
+	})
+}
+
+func (s *memoryQueueStore) Delete(ctx context.Context, userID, id string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	item, ok := s.db.queue[id]
+	if !ok || item.UserID != userID || item.Status == models.QueueStatusUploading {
+		return ErrNotFound
+	}
+	delete(s.db.queue, id)
+	return nil
+}
@@ -0,0 +1,962 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"viral-cuts-server/models"
+	"viral-cuts-server/utils"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// querier is satisfied by *pgxpool.Pool and pgx.Tx
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// NewPostgres returns stores backed by the database
+func NewPostgres(db *pgxpool.Pool) *Store {
+	s := newPostgres(db)
+	s.inTx = func(ctx context.Context, fn func(tx *Store) error) error {
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+
+		// The transaction's stores have no inTx, so nested calls join this transaction
+		if err := fn(newPostgres(tx)); err != nil {
+			return err
+		}
+		return tx.Commit(ctx)
+	}
+	return s
+}
+
+func newPostgres(db querier) *Store {
+	return &Store{
+		Users:         &pgUserStore{db: db},
+		Sessions:      &pgSessionStore{db: db},
+		Verifications: &pgVerificationStore{db: db},
+		Accounts:      &pgAccountStore{db: db},
+		Queue:         &pgQueueStore{db: db},
+		MFA:           &pgMFAStore{db: db},
+		Outbox:        &pgOutboxStore{db: db},
+		EmailChanges:  &pgEmailChangeStore{db: db},
+	}
+}
+
+// translate maps pgx errors onto store errors
+func translate(err error) error {
+	if err == pgx.ErrNoRows {
+		return ErrNotFound
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrDuplicate
+	}
+	return err
+}
+
+const userColumns = `id, name, email, email_verified, image, role, locale, created_at, updated_at`
+
+func scanUser(row models.RowScanner) (*models.User, error) {
+	var user models.User
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.EmailVerified, &user.Image, &user.Role, &user.Locale,
+		&user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+type pgUserStore struct {
+	db querier
+}
+
+func (s *pgUserStore) Create(ctx context.Context, user *models.User) error {
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+	if user.Locale == "" {
+		user.Locale = models.DefaultLocale
+	}
+	_, err := s.db.Exec(ctx,
+		`INSERT INTO "user" (id, name, email, email_verified, image, role, locale, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		user.ID, user.Name, user.Email, user.EmailVerified, user.Image, user.Role, user.Locale, user.CreatedAt,
+		user.UpdatedAt,
+	)
+	return translate(err)
+}
+
+func (s *pgUserStore) GetByID(ctx context.Context, id string) (*models.User, error) {
+	return scanUser(s.db.QueryRow(ctx, `SELECT `+userColumns+` FROM "user" WHERE id = $1`, id))
+}
+
+func (s *pgUserStore) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return scanUser(s.db.QueryRow(ctx, `SELECT `+userColumns+` FROM "user" WHERE email = $1`, email))
+}
+
+// likeEscaper escapes LIKE wildcards so user input only matches literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (s *pgUserStore) List(ctx context.Context, filter UserFilter) ([]*models.User, int, error) {
+	pattern := ""
+	if filter.Search != "" {
+		pattern = "%" + likeEscaper.Replace(filter.Search) + "%"
+	}
+
+	var total int
+	err := s.db.QueryRow(ctx,
+		`SELECT COUNT(*) FROM "user" WHERE $1 = '' OR name ILIKE $1 ESCAPE '\' OR email ILIKE $1 ESCAPE '\'`,
+		pattern,
+	).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.Query(ctx,
+		`SELECT `+userColumns+`
+		 FROM "user"
+		 WHERE $1 = '' OR name ILIKE $1 ESCAPE '\' OR email ILIKE $1 ESCAPE '\'
+		 ORDER BY created_at DESC
+		 LIMIT $2 OFFSET $3`,
+		pattern, filter.Limit, filter.Offset,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []*models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+	return users, total, rows.Err()
+}
+
+func (s *pgUserStore) MarkEmailVerified(ctx context.Context, email string) error {
+	_, err := s.db.Exec(ctx,
+		`UPDATE "user" SET email_verified = true, updated_at = NOW() WHERE email = $1`,
+		email,
+	)
+	return err
+}
+
+func (s *pgUserStore) UpdateEmail(ctx context.Context, userID, email string) error {
+	tag, err := s.db.Exec(ctx,
+		`UPDATE "user" SET email = $2, email_verified = true, updated_at = NOW() WHERE id = $1`,
+		userID, email,
+	)
+	if err != nil {
+		return translate(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *pgUserStore) UpdateLocale(ctx context.Context, userID, locale string) error {
+	tag, err := s.db.Exec(ctx, `UPDATE "user" SET locale = $2, updated_at = NOW() WHERE id = $1`, userID, locale)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *pgUserStore) UpdateRole(ctx context.Context, userID, role, changedBy string) (string, error) {
+	// Callers outside a transaction get one, so the audit row is never lost
+	if pool, ok := s.db.(*pgxpool.Pool); ok {
+		tx, err := pool.Begin(ctx)
+		if err != nil {
+			return "", err
+		}
+		defer tx.Rollback(ctx)
+
+		oldRole, err := (&pgUserStore{db: tx}).UpdateRole(ctx, userID, role, changedBy)
+		if err != nil {
+			return "", err
+		}
+		return oldRole, tx.Commit(ctx)
+	}
+
+	var oldRole string
+	err := s.db.QueryRow(ctx, `SELECT role FROM "user" WHERE id = $1 FOR UPDATE`, userID).Scan(&oldRole)
+	if err != nil {
+		return "", translate(err)
+	}
+	if oldRole == role {
+		return oldRole, nil
+	}
+
+	now := time.Now()
+	_, err = s.db.Exec(ctx, `UPDATE "user" SET role = $1, updated_at = $2 WHERE id = $3`, role, now, userID)
+	if err != nil {
+		return "", err
+	}
+
+	// Record every role change
+	_, err = s.db.Exec(ctx,
+		`INSERT INTO role_change (id, user_id, changed_by, old_role, new_role, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		utils.GenerateID(), userID, changedBy, oldRole, role, now,
+	)
+	if err != nil {
+		return "", err
+	}
+	return oldRole, nil
+}
+
+func (s *pgUserStore) RoleChanges(ctx context.Context, userID string, limit int) ([]*models.RoleChange, error) {
+	rows, err := s.db.Query(ctx,
+		`SELECT id, user_id, changed_by, old_role, new_role, created_at
+		 FROM role_change
+		 WHERE $1 = '' OR user_id = $1
+		 ORDER BY created_at DESC
+		 LIMIT $2`,
+		userID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []*models.RoleChange{}
+	for rows.Next() {
+		var change models.RoleChange
+		if err := rows.Scan(&change.ID, &change.UserID, &change.ChangedBy, &change.OldRole, &change.NewRole, &change.CreatedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, &change)
+	}
+	return changes, rows.Err()
+}
+
+type pgSessionStore struct {
+	db querier
+}
+
+func (s *pgSessionStore) Create(ctx context.Context, session *models.Session) error {
+	_, err := s.db.Exec(ctx,
+		`INSERT INTO "session" (id, token, expires_at, user_id, ip_address, user_agent, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		session.ID, session.Token, session.ExpiresAt, session.UserID, session.IPAddress, session.UserAgent,
+		session.CreatedAt, session.UpdatedAt,
+	)
+	return translate(err)
+}
+
+func (s *pgSessionStore) GetByToken(ctx context.Context, token string) (*models.Session, *models.User, error) {
+	var user models.User
+	session := models.Session{Token: token}
+
+	err := s.db.QueryRow(ctx,
+		`SELECT s.id, s.expires_at, s.created_at, s.updated_at, s.ip_address, s.user_agent,
+		        u.id, u.name, u.email, u.email_verified, u.image, u.role, u.locale, u.created_at, u.updated_at
+		 FROM "session" s
+		 JOIN "user" u ON s.user_id = u.id
+		 WHERE s.token = $1 AND s.expires_at > NOW()`,
+		token,
+	).Scan(
+		&session.ID, &session.ExpiresAt, &session.CreatedAt, &session.UpdatedAt, &session.IPAddress, &session.UserAgent,
+		&user.ID, &user.Name, &user.Email, &user.EmailVerified, &user.Image, &user.Role, &user.Locale,
+		&user.CreatedAt, &user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, nil, translate(err)
+	}
+
+	session.UserID = user.ID
+	return &session, &user, nil
+}
+
+func (s *pgSessionStore) ListByUser(ctx context.Context, userID string) ([]*models.Session, error) {
+	rows, err := s.db.Query(ctx,
+		`SELECT id, token, expires_at, created_at, updated_at, ip_address, user_agent, user_id
+		 FROM "session"
+		 WHERE user_id = $1 AND expires_at > NOW()
+		 ORDER BY updated_at DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*models.Session{}
+	for rows.Next() {
+		var session models.Session
+		err := rows.Scan(&session.ID, &session.Token, &session.ExpiresAt, &session.CreatedAt, &session.UpdatedAt,
+			&session.IPAddress, &session.UserAgent, &session.UserID)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &session)
+	}
+	return sessions, rows.Err()
+}
+
+func (s *pgSessionStore) DeleteByToken(ctx context.Context, token string) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM "session" WHERE token = $1`, token)
+	return err
+}
+
+func (s *pgSessionStore) Delete(ctx context.Context, userID, id string) error {
+	result, err := s.db.Exec(ctx, `DELETE FROM "session" WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *pgSessionStore) DeleteByUser(ctx context.Context, userID string) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM "session" WHERE user_id = $1`, userID)
+	return err
+}
+
+func (s *pgSessionStore) DeleteOthers(ctx context.Context, userID, keepID string) (int, error) {
+	result, err := s.db.Exec(ctx, `DELETE FROM "session" WHERE user_id = $1 AND id <> $2`, userID, keepID)
+	if err != nil {
+		return 0, err
+	}
+	return int(result.RowsAffected()), nil
+}
+
+func (s *pgSessionStore) Touch(ctx context.Context, id string, lastActivity, expiresAt time.Time) error {
+	_, err := s.db.Exec(ctx,
+		`UPDATE "session" SET updated_at = $2, expires_at = $3 WHERE id = $1`,
+		id, lastActivity, expiresAt,
+	)
+	return err
+}
+
+func (s *pgSessionStore) DeleteExpired(ctx context.Context, createdBefore time.Time) (int, error) {
+	result, err := s.db.Exec(ctx,
+		`DELETE FROM "session" WHERE expires_at <= NOW() OR created_at < $1`,
+		createdBefore,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return int(result.RowsAffected()), nil
+}
+
+type pgVerificationStore struct {
+	db querier
+}
+
+func (s *pgVerificationStore) Create(ctx context.Context, verification *models.Verification) error {
+	_, err := s.db.Exec(ctx,
+		`INSERT INTO verification (id, identifier, purpose, value, expires_at, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		verification.ID, verification.Identifier, verification.Purpose, verification.Value,
+		verification.ExpiresAt, verification.CreatedAt, verification.UpdatedAt,
+	)
+	return translate(err)
+}
+
+func (s *pgVerificationStore) Get(ctx context.Context, purpose, value string) (*models.Verification, error) {
+	var verification models.Verification
+	err := s.db.QueryRow(ctx,
+		`SELECT id, identifier, purpose, value, expires_at, created_at, updated_at
+		 FROM verification
+		 WHERE purpose = $1 AND value = $2 AND expires_at > NOW()`,
+		purpose, value,
+	).Scan(&verification.ID, &verification.Identifier, &verification.Purpose, &verification.Value,
+		&verification.ExpiresAt, &verification.CreatedAt, &verification.UpdatedAt)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &verification, nil
+}
+
+func (s *pgVerificationStore) Consume(ctx context.Context, purpose, value string) (*models.Verification, error) {
+	var verification models.Verification
+	err := s.db.QueryRow(ctx,
+		`DELETE FROM verification
+		 WHERE purpose = $1 AND value = $2 AND expires_at > NOW()
+		 RETURNING id, identifier, purpose, value, expires_at, created_at, updated_at`,
+		purpose, value,
+	).Scan(&verification.ID, &verification.Identifier, &verification.Purpose, &verification.Value,
+		&verification.ExpiresAt, &verification.CreatedAt, &verification.UpdatedAt)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &verification, nil
+}
+
+func (s *pgVerificationStore) Delete(ctx context.Context, id string) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM verification WHERE id = $1`, id)
+	return err
+}
+
+func (s *pgVerificationStore) DeleteByIdentifier(ctx context.Context, purpose, identifier string) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM verification WHERE purpose = $1 AND identifier = $2`, purpose, identifier)
+	return err
+}
+
+type pgAccountStore struct {
+	db querier
+}
+
+func (s *pgAccountStore) Create(ctx context.Context, account *models.Account) error {
+	_, err := s.db.Exec(ctx,
+		`INSERT INTO "account" (id, account_id, provider_id, user_id, password, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		account.ID, account.AccountID, account.ProviderID, account.UserID, account.Password,
+		account.CreatedAt, account.UpdatedAt,
+	)
+	return translate(err)
+}
+
+func (s *pgAccountStore) GetCredential(ctx context.Context, userID string) (*models.Account, error) {
+	var account models.Account
+	err := s.db.QueryRow(ctx,
+		`SELECT id, account_id, provider_id, user_id, password, created_at, updated_at
+		 FROM "account"
+		 WHERE user_id = $1 AND provider_id = $2`,
+		userID, CredentialProvider,
+	).Scan(&account.ID, &account.AccountID, &account.ProviderID, &account.UserID, &account.Password,
+		&account.CreatedAt, &account.UpdatedAt)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &account, nil
+}
+
+func (s *pgAccountStore) UpdatePassword(ctx context.Context, userID, passwordHash string) error {
+	result, err := s.db.Exec(ctx,
+		`UPDATE "account" SET password = $1, updated_at = $2 WHERE user_id = $3 AND provider_id = $4`,
+		passwordHash, time.Now(), userID, CredentialProvider,
+	)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *pgAccountStore) GetByProvider(ctx context.Context, providerID, accountID string) (*models.Account, error) {
+	var account models.Account
+	err := s.db.QueryRow(ctx,
+		`SELECT id, account_id, provider_id, user_id, password, created_at, updated_at
+		 FROM "account"
+		 WHERE provider_id = $1 AND account_id = $2
+		 ORDER BY created_at
+		 LIMIT 1`,
+		providerID, accountID,
+	).Scan(&account.ID, &account.AccountID, &account.ProviderID, &account.UserID, &account.Password,
+		&account.CreatedAt, &account.UpdatedAt)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &account, nil
+}
+
+func (s *pgAccountStore) DeleteCredential(ctx context.Context, userID string) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM "account" WHERE user_id = $1 AND provider_id = $2`, userID, CredentialProvider)
+	return err
+}
+
+func (s *pgAccountStore) UpdateCredentialEmail(ctx context.Context, userID, email string) error {
+	_, err := s.db.Exec(ctx,
+		`UPDATE "account" SET account_id = $3, updated_at = NOW() WHERE user_id = $1 AND provider_id = $2`,
+		userID, CredentialProvider, email,
+	)
+	return translate(err)
+}
+
+// pgMFAStore keeps TOTP secrets encrypted with the secrets keyring
+type pgMFAStore struct {
+	db querier
+}
+
+func (s *pgMFAStore) Get(ctx context.Context, userID string) (*models.UserMFA, error) {
+	var mfa models.UserMFA
+	err := s.db.QueryRow(ctx,
+		`SELECT id, user_id, secret, enabled_at, last_used_step, failed_attempts, created_at, updated_at
+		 FROM user_mfa
+		 WHERE user_id = $1`,
+		userID,
+	).Scan(&mfa.ID, &mfa.UserID, &mfa.Secret, &mfa.EnabledAt, &mfa.LastUsedStep, &mfa.FailedAttempts,
+		&mfa.CreatedAt, &mfa.UpdatedAt)
+	if err != nil {
+		return nil, translate(err)
+	}
+	if mfa.Secret, err = utils.DecryptSecret(mfa.Secret); err != nil {
+		return nil, err
+	}
+	return &mfa, nil
+}
+
+func (s *pgMFAStore) SetPending(ctx context.Context, mfa *models.UserMFA) error {
+	secret, err := utils.EncryptSecret(mfa.Secret)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(ctx,
+		`INSERT INTO user_mfa (id, user_id, secret, enabled_at, last_used_step, failed_attempts, created_at, updated_at)
+		 VALUES ($1, $2, $3, NULL, 0, 0, $4, $5)
+		 ON CONFLICT (user_id) DO UPDATE
+		 SET secret = EXCLUDED.secret, enabled_at = NULL, last_used_step = 0, failed_attempts = 0,
+		     updated_at = EXCLUDED.updated_at`,
+		mfa.ID, mfa.UserID, secret, mfa.CreatedAt, mfa.UpdatedAt,
+	)
+	return translate(err)
+}
+
+func (s *pgMFAStore) Enable(ctx context.Context, userID string, step int64) error {
+	tag, err := s.db.Exec(ctx,
+		`UPDATE user_mfa SET enabled_at = NOW(), last_used_step = $2, failed_attempts = 0, updated_at = NOW()
+		 WHERE user_id = $1`,
+		userID, step,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *pgMFAStore) AcceptStep(ctx context.Context, userID string, step int64) error {
+	tag, err := s.db.Exec(ctx,
+		`UPDATE user_mfa SET last_used_step = $2, failed_attempts = 0, updated_at = NOW()
+		 WHERE user_id = $1 AND last_used_step < $2`,
+		userID, step,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *pgMFAStore) RecordFailure(ctx context.Context, userID string) (int, error) {
+	var attempts int
+	err := s.db.QueryRow(ctx,
+		`UPDATE user_mfa SET failed_attempts = failed_attempts + 1, updated_at = NOW()
+		 WHERE user_id = $1
+		 RETURNING failed_attempts`,
+		userID,
+	).Scan(&attempts)
+	return attempts, translate(err)
+}
+
+func (s *pgMFAStore) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	if _, err := s.db.Exec(ctx, `DELETE FROM mfa_recovery_code WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		_, err := s.db.Exec(ctx,
+			`INSERT INTO mfa_recovery_code (id, user_id, code_hash, created_at) VALUES ($1, $2, $3, NOW())`,
+			utils.GenerateID(), userID, hash,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *pgMFAStore) UseRecoveryCode(ctx context.Context, userID, codeHash string) error {
+	tag, err := s.db.Exec(ctx,
+		`UPDATE mfa_recovery_code SET used_at = NOW()
+		 WHERE id = (
+		     SELECT id FROM mfa_recovery_code
+		     WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+		     LIMIT 1
+		 ) AND used_at IS NULL`,
+		userID, codeHash,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	_, err = s.db.Exec(ctx, `UPDATE user_mfa SET failed_attempts = 0, updated_at = NOW() WHERE user_id = $1`, userID)
+	return err
+}
+
+func (s *pgMFAStore) RecoveryCodesLeft(ctx context.Context, userID string) (int, error) {
+	var count int
+	err := s.db.QueryRow(ctx,
+		`SELECT COUNT(*) FROM mfa_recovery_code WHERE user_id = $1 AND used_at IS NULL`,
+		userID,
+	).Scan(&count)
+	return count, err
+}
+
+func (s *pgMFAStore) Delete(ctx context.Context, userID string) error {
+	if _, err := s.db.Exec(ctx, `DELETE FROM mfa_recovery_code WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(ctx, `DELETE FROM user_mfa WHERE user_id = $1`, userID)
+	return err
+}
+
+type pgEmailChangeStore struct {
+	db querier
+}
+
+func (s *pgEmailChangeStore) Set(ctx context.Context, change *models.EmailChange) error {
+	_, err := s.db.Exec(ctx,
+		`INSERT INTO email_change (id, user_id, new_email, expires_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (user_id) DO UPDATE
+		 SET id = EXCLUDED.id, new_email = EXCLUDED.new_email, expires_at = EXCLUDED.expires_at,
+		     created_at = EXCLUDED.created_at`,
+		change.ID, change.UserID, change.NewEmail, change.ExpiresAt, change.CreatedAt,
+	)
+	return translate(err)
+}
+
+func (s *pgEmailChangeStore) Get(ctx context.Context, userID string) (*models.EmailChange, error) {
+	var change models.EmailChange
+	err := s.db.QueryRow(ctx,
+		`SELECT id, user_id, new_email, expires_at, created_at
+		 FROM email_change
+		 WHERE user_id = $1 AND expires_at > NOW()`,
+		userID,
+	).Scan(&change.ID, &change.UserID, &change.NewEmail, &change.ExpiresAt, &change.CreatedAt)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &change, nil
+}
+
+func (s *pgEmailChangeStore) Delete(ctx context.Context, userID string) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM email_change WHERE user_id = $1`, userID)
+	return err
+}
+
+// pgOutboxStore keeps email bodies encrypted with the secrets keyring, since they carry token links
+type pgOutboxStore struct {
+	db querier
+}
+
+const outboxColumns = `id, sender, recipient, subject, status, attempts, next_attempt_at, claimed_at, last_error,
+	sent_at, created_at, updated_at`
+
+func scanOutboxEmail(row models.RowScanner, withBody bool) (*models.OutboxEmail, error) {
+	var email models.OutboxEmail
+	var html, text *string
+	dest := []any{&email.ID, &email.Sender, &email.Recipient, &email.Subject, &email.Status, &email.Attempts,
+		&email.NextAttemptAt, &email.ClaimedAt, &email.LastError, &email.SentAt, &email.CreatedAt, &email.UpdatedAt}
+	if withBody {
+		dest = append(dest, &html, &text)
+	}
+	if err := row.Scan(dest...); err != nil {
+		return nil, translate(err)
+	}
+
+	var err error
+	if html, err = utils.DecryptSecretPtr(html); err != nil {
+		return nil, err
+	}
+	if text, err = utils.DecryptSecretPtr(text); err != nil {
+		return nil, err
+	}
+	if html != nil {
+		email.HTML = *html
+	}
+	if text != nil {
+		email.Text = *text
+	}
+	return &email, nil
+}
+
+func (s *pgOutboxStore) Enqueue(ctx context.Context, email *models.OutboxEmail) error {
+	html, err := utils.EncryptSecret(email.HTML)
+	if err != nil {
+		return err
+	}
+	var text *string
+	if email.Text != "" {
+		if text, err = utils.EncryptSecretPtr(&email.Text); err != nil {
+			return err
+		}
+	}
+	if email.Status == "" {
+		email.Status = models.OutboxStatusPending
+	}
+	_, err = s.db.Exec(ctx,
+		`INSERT INTO email_outbox (id, sender, recipient, subject, html, text_body, status, attempts, next_attempt_at,
+		     created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, 0, $8, $9, $10)`,
+		email.ID, email.Sender, email.Recipient, email.Subject, html, text, email.Status, email.NextAttemptAt,
+		email.CreatedAt, email.UpdatedAt,
+	)
+	return translate(err)
+}
+
+func (s *pgOutboxStore) ClaimDue(ctx context.Context, limit int, staleBefore time.Time) ([]*models.OutboxEmail, error) {
+	rows, err := s.db.Query(ctx,
+		`UPDATE email_outbox
+		 SET status = 'sending', claimed_at = NOW(), attempts = attempts + 1, updated_at = NOW()
+		 WHERE id IN (
+		     SELECT id FROM email_outbox
+		     WHERE (status = 'pending' AND next_attempt_at <= NOW())
+		        OR (status = 'sending' AND claimed_at < $2)
+		     ORDER BY next_attempt_at
+		     LIMIT $1
+		     FOR UPDATE SKIP LOCKED
+		 )
+		 RETURNING `+outboxColumns+`, html, text_body`,
+		limit, staleBefore,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	emails := []*models.OutboxEmail{}
+	for rows.Next() {
+		email, err := scanOutboxEmail(rows, true)
+		if err != nil {
+			return nil, err
+		}
+		emails = append(emails, email)
+	}
+	return emails, rows.Err()
+}
+
+func (s *pgOutboxStore) MarkSent(ctx context.Context, id string, claimedAt time.Time) error {
+	result, err := s.db.Exec(ctx,
+		`UPDATE email_outbox
+		 SET status = 'sent', html = NULL, text_body = NULL, last_error = NULL, claimed_at = NULL,
+		     sent_at = NOW(), updated_at = NOW()
+		 WHERE id = $1 AND status = 'sending' AND claimed_at = $2`,
+		id, claimedAt,
+	)
+	return claimResult(result, err)
+}
+
+func (s *pgOutboxStore) Reschedule(ctx context.Context, id string, claimedAt, nextAttempt time.Time, lastError string) error {
+	result, err := s.db.Exec(ctx,
+		`UPDATE email_outbox
+		 SET status = 'pending', next_attempt_at = $3, last_error = $4, claimed_at = NULL, updated_at = NOW()
+		 WHERE id = $1 AND status = 'sending' AND claimed_at = $2`,
+		id, claimedAt, nextAttempt, lastError,
+	)
+	return claimResult(result, err)
+}
+
+func (s *pgOutboxStore) MarkDead(ctx context.Context, id string, claimedAt time.Time, lastError string) error {
+	result, err := s.db.Exec(ctx,
+		`UPDATE email_outbox SET status = 'dead', last_error = $3, claimed_at = NULL, updated_at = NOW()
+		 WHERE id = $1 AND status = 'sending' AND claimed_at = $2`,
+		id, claimedAt, lastError,
+	)
+	return claimResult(result, err)
+}
+
+// claimResult maps an update guarded by a claim to ErrNotFound when the claim was lost
+func claimResult(result pgconn.CommandTag, err error) error {
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *pgOutboxStore) List(ctx context.Context, filter OutboxFilter) ([]*models.OutboxEmail, int, error) {
+	var total int
+	err := s.db.QueryRow(ctx,
+		`SELECT COUNT(*) FROM email_outbox WHERE $1 = '' OR status = $1`,
+		filter.Status,
+	).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.Query(ctx,
+		`SELECT `+outboxColumns+`
+		 FROM email_outbox
+		 WHERE $1 = '' OR status = $1
+		 ORDER BY created_at DESC
+		 LIMIT $2 OFFSET $3`,
+		filter.Status, filter.Limit, filter.Offset,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	emails := []*models.OutboxEmail{}
+	for rows.Next() {
+		email, err := scanOutboxEmail(rows, false)
+		if err != nil {
+			return nil, 0, err
+		}
+		emails = append(emails, email)
+	}
+	return emails, total, rows.Err()
+}
+
+func (s *pgOutboxStore) Retry(ctx context.Context, id string) error {
+	tag, err := s.db.Exec(ctx,
+		`UPDATE email_outbox SET status = 'pending', attempts = 0, next_attempt_at = NOW(), updated_at = NOW()
+		 WHERE id = $1 AND status = 'dead'`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *pgOutboxStore) DeleteSent(ctx context.Context, sentBefore time.Time) (int, error) {
+	tag, err := s.db.Exec(ctx, `DELETE FROM email_outbox WHERE status = 'sent' AND sent_at < $1`, sentBefore)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+type pgQueueStore struct {
+	db querier
+}
+
+func (s *pgQueueStore) List(ctx context.Context, userID, status string) ([]*models.UploadQueueItem, error) {
+	rows, err := s.db.Query(ctx,
+		`SELECT `+models.QueueItemColumns+`
+		 FROM upload_queue
+		 WHERE user_id = $1 AND ($2 = '' OR status = $2)
+		 ORDER BY created_at ASC`,
+		userID, status,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []*models.UploadQueueItem{}
+	for rows.Next() {
+		item, err := models.ScanUploadQueueItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *pgQueueStore) Get(ctx context.Context, userID, id string) (*models.UploadQueueItem, error) {
+	item, err := models.ScanUploadQueueItem(s.db.QueryRow(ctx,
+		`SELECT `+models.QueueItemColumns+` FROM upload_queue WHERE id = $1 AND user_id = $2`,
+		id, userID,
+	))
+	if err != nil {
+		return nil, translate(err)
+	}
+	return item, nil
+}
+
+func (s *pgQueueStore) Create(ctx context.Context, item *models.UploadQueueItem) error {
+	var privacyStatus *string
+	if item.PrivacyStatus != "" {
+		privacyStatus = &item.PrivacyStatus
+	}
+
+	now := time.Now()
+	created, err := models.ScanUploadQueueItem(s.db.QueryRow(ctx,
+		`INSERT INTO upload_queue (id, user_id, title, description, source, platform, status, file_url, file_name,
+		                           scheduled_at, publish_at, privacy_status, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11,
+		         COALESCE($12, (SELECT default_visibility FROM user_settings WHERE user_id = $2), 'private'), $13, $14)
+		 RETURNING `+models.QueueItemColumns,
+		item.ID, item.UserID, item.Title, item.Description, item.Source, item.Platform, models.QueueStatusReady,
+		item.FileURL, item.FileName, item.ScheduledAt, item.PublishAt, privacyStatus, now, now,
+	))
+	if err != nil {
+		return translate(err)
+	}
+	*item = *created
+	return nil
+}
+
+func (s *pgQueueStore) Update(ctx context.Context, userID, id string, update QueueItemUpdate) (*models.UploadQueueItem, error) {
+	item, err := models.ScanUploadQueueItem(s.db.QueryRow(ctx,
+		`UPDATE upload_queue
+		 SET title = COALESCE($1, title),
+		     description = COALESCE($2, description),
+		     platform = COALESCE($3, platform),
+		     scheduled_at = COALESCE($4, scheduled_at),
+		     publish_at = COALESCE($5, publish_at),
+		     privacy_status = COALESCE($6, privacy_status),
+		     updated_at = $7
+		 WHERE id = $8 AND user_id = $9 AND status IN ($10, $11)
+		 RETURNING `+models.QueueItemColumns,
+		update.Title, update.Description, update.Platform, update.ScheduledAt, update.PublishAt, update.PrivacyStatus,
+		time.Now(), id, userID, models.QueueStatusReady, models.QueueStatusError,
+	))
+	if err != nil {
+		return nil, translate(err)
+	}
+	return item, nil
+}
+
+func (s *pgQueueStore) SetStatus(ctx context.Context, userID, id, from, to string, errorMessage *string) (*models.UploadQueueItem, error) {
+	if to != models.QueueStatusError {
+		errorMessage = nil
+	}
+	item, err := models.ScanUploadQueueItem(s.db.QueryRow(ctx,
+		`UPDATE upload_queue
+		 SET status = $1,
+		     error_message = $2,
+		     attempts = CASE WHEN $1 = 'ready' THEN 0 ELSE attempts END,
+		     next_attempt_at = NULL,
+		     updated_at = $3
+		 WHERE id = $4 AND user_id = $5 AND status = $6
+		 RETURNING `+models.QueueItemColumns,
+		to, errorMessage, time.Now(), id, userID, from,
+	))
+	if err != nil {
+		return nil, translate(err)
+	}
+	return item, nil
+}
+
+func (s *pgQueueStore) Delete(ctx context.Context, userID, id string) error {
+	result, err := s.db.Exec(ctx,
+		`DELETE FROM upload_queue WHERE id = $1 AND user_id = $2 AND status <> $3`,
+		id, userID, models.QueueStatusUploading,
+	)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+// Package store keeps the SQL of the auth and upload queue tables out of the gin handlers.
+//
+// Each table gets an interface with a Postgres implementation (NewPostgres) and an in-memory one
+// (NewMemory) so handlers can be exercised without a database.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+	"viral-cuts-server/models"
+)
+
+var (
+	// ErrNotFound is returned when no row matches
+	ErrNotFound = errors.New("not found")
+	// ErrDuplicate is returned when a unique column already holds the value
+	ErrDuplicate = errors.New("already exists")
+)
+
+// CredentialProvider is the account provider_id of email/password accounts
+const CredentialProvider = "credential"
+
+// UserFilter selects a page of users for ListUsers
+type UserFilter struct {
+	Search string // matched against name and email, case-insensitive
+	Limit  int
+	Offset int
+}
+
+// UserStore manages rows of "user"
+type UserStore interface {
+	Create(ctx context.Context, user *models.User) error
+	GetByID(ctx context.Context, id string) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	List(ctx context.Context, filter UserFilter) ([]*models.User, int, error)
+	MarkEmailVerified(ctx context.Context, email string) error
+	// UpdateEmail replaces the email of a user and marks it verified, returning ErrDuplicate when
+	// another user has it
+	UpdateEmail(ctx context.Context, userID, email string) error
+	// UpdateLocale sets the language of a user's emails
+	UpdateLocale(ctx context.Context, userID, locale string) error
+	// UpdateRole changes a user's role and records the change, returning the previous role.
+	// Nothing is written when the role is unchanged.
+	UpdateRole(ctx context.Context, userID, role, changedBy string) (string, error)
+	RoleChanges(ctx context.Context, userID string, limit int) ([]*models.RoleChange, error)
+}
+
+// SessionStore manages rows of "session"
+type SessionStore interface {
+	Create(ctx context.Context, session *models.Session) error
+	// GetByToken returns an unexpired session and its user
+	GetByToken(ctx context.Context, token string) (*models.Session, *models.User, error)
+	// ListByUser returns the unexpired sessions of a user, most recently used first
+	ListByUser(ctx context.Context, userID string) ([]*models.Session, error)
+	DeleteByToken(ctx context.Context, token string) error
+	// Delete removes a session of a user, returning ErrNotFound when the user has no such session
+	Delete(ctx context.Context, userID, id string) error
+	DeleteByUser(ctx context.Context, userID string) error
+	// DeleteOthers removes every session of a user except keepID and returns how many were removed
+	DeleteOthers(ctx context.Context, userID, keepID string) (int, error)
+	// Touch records activity on a session (updated_at) and moves its expiry
+	Touch(ctx context.Context, id string, lastActivity, expiresAt time.Time) error
+	// DeleteExpired removes sessions past their expiry or created before createdBefore
+	DeleteExpired(ctx context.Context, createdBefore time.Time) (int, error)
+}
+
+// VerificationStore manages rows of "verification"
+type VerificationStore interface {
+	Create(ctx context.Context, verification *models.Verification) error
+	// Get returns an unexpired verification by purpose and stored value
+	Get(ctx context.Context, purpose, value string) (*models.Verification, error)
+	// Consume deletes an unexpired verification by purpose and stored value and returns it,
+	// so a token is used at most once
+	Consume(ctx context.Context, purpose, value string) (*models.Verification, error)
+	Delete(ctx context.Context, id string) error
+	DeleteByIdentifier(ctx context.Context, purpose, identifier string) error
+}
+
+// AccountStore manages rows of "account" that hold login credentials
+type AccountStore interface {
+	Create(ctx context.Context, account *models.Account) error
+	// GetCredential returns the email/password account of a user
+	GetCredential(ctx context.Context, userID string) (*models.Account, error)
+	UpdatePassword(ctx context.Context, userID, passwordHash string) error
+	// GetByProvider returns the account a provider knows by accountID (e.g. a Google subject)
+	GetByProvider(ctx context.Context, providerID, accountID string) (*models.Account, error)
+	DeleteCredential(ctx context.Context, userID string) error
+	// UpdateCredentialEmail keeps the account_id of the email/password account equal to the user's email
+	UpdateCredentialEmail(ctx context.Context, userID, email string) error
+}
+
+// EmailChangeStore manages pending email changes ("email_change"), one per user
+type EmailChangeStore interface {
+	// Set replaces the pending change of change.UserID
+	Set(ctx context.Context, change *models.EmailChange) error
+	// Get returns the unexpired pending change of a user
+	Get(ctx context.Context, userID string) (*models.EmailChange, error)
+	Delete(ctx context.Context, userID string) error
+}
+
+// MFAStore manages TOTP enrollments ("user_mfa") and recovery codes ("mfa_recovery_code")
+type MFAStore interface {
+	// Get returns the enrollment of a user, confirmed or not
+	Get(ctx context.Context, userID string) (*models.UserMFA, error)
+	// SetPending replaces the enrollment of mfa.UserID with an unconfirmed secret
+	SetPending(ctx context.Context, mfa *models.UserMFA) error
+	// Enable confirms the enrollment, remembering the time step of the confirming code
+	Enable(ctx context.Context, userID string, step int64) error
+	// AcceptStep records the time step of an accepted code and clears failed attempts.
+	// It returns ErrNotFound when step isn't newer than the last accepted one, i.e. on replay.
+	AcceptStep(ctx context.Context, userID string, step int64) error
+	// RecordFailure counts a wrong code and returns the failed attempts since the last accepted one
+	RecordFailure(ctx context.Context, userID string) (int, error)
+	// ReplaceRecoveryCodes drops the recovery codes of a user and stores new ones by hash
+	ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error
+	// UseRecoveryCode marks an unused recovery code as used and clears failed attempts,
+	// returning ErrNotFound when no unused code matches
+	UseRecoveryCode(ctx context.Context, userID, codeHash string) error
+	RecoveryCodesLeft(ctx context.Context, userID string) (int, error)
+	// Delete removes the enrollment and recovery codes of a user
+	Delete(ctx context.Context, userID string) error
+}
+
+// OutboxFilter selects a page of outbox emails
+type OutboxFilter struct {
+	Status string // empty for every status
+	Limit  int
+	Offset int
+}
+
+// OutboxStore manages the transactional email outbox ("email_outbox").
+// HTML and Text are encrypted with the secrets keyring.
+type OutboxStore interface {
+	// Enqueue stores a pending email. Inside InTx it is only delivered if the transaction commits.
+	Enqueue(ctx context.Context, email *models.OutboxEmail) error
+	// ClaimDue moves up to limit due pending emails, and emails left sending since before staleBefore,
+	// to sending and counts an attempt. Concurrent callers never claim the same email.
+	ClaimDue(ctx context.Context, limit int, staleBefore time.Time) ([]*models.OutboxEmail, error)
+	// MarkSent records delivery and drops the bodies.
+	// Like Reschedule and MarkDead it only applies while the email is still held by the claim made at
+	// claimedAt, and returns ErrNotFound once another caller has reclaimed it.
+	MarkSent(ctx context.Context, id string, claimedAt time.Time) error
+	// Reschedule returns a claimed email to pending until nextAttempt
+	Reschedule(ctx context.Context, id string, claimedAt, nextAttempt time.Time, lastError string) error
+	// MarkDead stops retrying an email
+	MarkDead(ctx context.Context, id string, claimedAt time.Time, lastError string) error
+	// List returns a page of emails without their bodies, newest first, and the total count
+	List(ctx context.Context, filter OutboxFilter) ([]*models.OutboxEmail, int, error)
+	// Retry makes a dead email pending again with no attempts, returning ErrNotFound unless it is dead
+	Retry(ctx context.Context, id string) error
+	// DeleteSent removes emails sent before sentBefore and returns how many were removed
+	DeleteSent(ctx context.Context, sentBefore time.Time) (int, error)
+}
+
+// QueueItemUpdate holds the editable fields of a queue item; nil fields are left unchanged
+type QueueItemUpdate struct {
+	Title         *string
+	Description   *string
+	Platform      *string
+	ScheduledAt   *time.Time
+	PublishAt     *time.Time
+	PrivacyStatus *string
+}
+
+// QueueStore manages rows of "upload_queue". Every method is scoped to the owning user.
+type QueueStore interface {
+	// List returns the items of a user, oldest first, keeping only those in status unless it is empty
+	List(ctx context.Context, userID, status string) ([]*models.UploadQueueItem, error)
+	Get(ctx context.Context, userID, id string) (*models.UploadQueueItem, error)
+	// Create stores item as ready and fills in the stored row. An empty PrivacyStatus falls back to
+	// the user's default visibility, or private.
+	Create(ctx context.Context, item *models.UploadQueueItem) error
+	// Update edits an item that is ready or in error, returning ErrNotFound otherwise
+	Update(ctx context.Context, userID, id string, update QueueItemUpdate) (*models.UploadQueueItem, error)
+	// SetStatus moves an item from one status to another, returning ErrNotFound when it is no longer in
+	// from. Moving to ready resets the attempts; errorMessage is only kept when moving to error.
+	SetStatus(ctx context.Context, userID, id, from, to string, errorMessage *string) (*models.UploadQueueItem, error)
+	// Delete removes an item that is not uploading, returning ErrNotFound otherwise
+	Delete(ctx context.Context, userID, id string) error
+}
+
+// Store groups the stores handed to the handlers
+type Store struct {
+	Users         UserStore
+	Sessions      SessionStore
+	Verifications VerificationStore
+	Accounts      AccountStore
+	Queue         QueueStore
+	MFA           MFAStore
+	Outbox        OutboxStore
+	EmailChanges  EmailChangeStore
+
+	inTx func(ctx context.Context, fn func(tx *Store) error) error
+}
+
+// InTx runs fn with stores bound to a single transaction. The transaction commits when fn returns nil
+// and rolls back otherwise. Calling InTx on the stores passed to fn reuses the same transaction.
+func (s *Store) InTx(ctx context.Context, fn func(tx *Store) error) error {
+	if s.inTx == nil {
+		return fn(s)
+	}
+	return s.inTx(ctx, fn)
+}
@@ -0,0 +1,220 @@
+package tiktok
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// AccessTokenSource returns a valid access token for a user
+type AccessTokenSource interface {
+	AccessToken(ctx context.Context, userID string) (string, error)
+}
+
+// APIError is an error envelope returned by the TikTok Open API
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	LogID      string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("tiktok api error %d (%s): %s [log_id %s]", e.StatusCode, e.Code, e.Message, e.LogID)
+}
+
+// Retryable reports whether the request may succeed later
+func (e *APIError) Retryable() bool {
+	switch e.Code {
+	case "rate_limit_exceeded", "internal_error":
+		return true
+	}
+	return e.StatusCode >= 500
+}
+
+// Client wraps the TikTok Content Posting API.
+// BaseURL is a field so tests can point it at a local stub.
+type Client struct {
+	BaseURL    string // e.g. https://open.tiktokapis.com
+	HTTPClient *http.Client
+
+	tokens AccessTokenSource
+}
+
+// NewClient creates a client for the public API, overridable with TIKTOK_API_BASE_URL
+func NewClient(tokens AccessTokenSource) *Client {
+	return &Client{
+		BaseURL:    envOr("TIKTOK_API_BASE_URL", "https://open.tiktokapis.com"),
+		HTTPClient: &http.Client{Timeout: 5 * time.Minute},
+		tokens:     tokens,
+	}
+}
+
+// UserInfo is the subset of /v2/user/info/ we use
+type UserInfo struct {
+	OpenID      string `json:"open_id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url"`
+}
+
+// PostInfo describes the post created by InitVideoUpload
+type PostInfo struct {
+	Title          string `json:"title"`
+	PrivacyLevel   string `json:"privacy_level"`
+	DisableComment bool   `json:"disable_comment"`
+	DisableDuet    bool   `json:"disable_duet"`
+	DisableStitch  bool   `json:"disable_stitch"`
+}
+
+// SourceInfo describes the file pushed with UploadChunk
+type SourceInfo struct {
+	Source          string `json:"source"`
+	VideoSize       int64  `json:"video_size"`
+	ChunkSize       int64  `json:"chunk_size"`
+	TotalChunkCount int64  `json:"total_chunk_count"`
+}
+
+// UploadTarget is returned by InitVideoUpload
+type UploadTarget struct {
+	PublishID string `json:"publish_id"`
+	UploadURL string `json:"upload_url"`
+}
+
+// Publish statuses returned by FetchPublishStatus
+const (
+	StatusProcessingUpload   = "PROCESSING_UPLOAD"
+	StatusProcessingDownload = "PROCESSING_DOWNLOAD"
+	StatusSendToUserInbox    = "SEND_TO_USER_INBOX"
+	StatusPublishComplete    = "PUBLISH_COMPLETE"
+	StatusFailed             = "FAILED"
+)
+
+// PublishStatus is returned by FetchPublishStatus
+type PublishStatus struct {
+	Status     string   `json:"status"`
+	FailReason string   `json:"fail_reason"`
+	PostIDs    []string `json:"publicaly_available_post_id"` // sic, TikTok's spelling
+}
+
+// UserInfo returns the connected account's profile
+func (c *Client) UserInfo(ctx context.Context, userID string) (*UserInfo, error) {
+	var data struct {
+		User UserInfo `json:"user"`
+	}
+	err := c.call(ctx, userID, "GET", "/v2/user/info/?fields=open_id,username,display_name,avatar_url", nil, &data)
+	if err != nil {
+		return nil, err
+	}
+	return &data.User, nil
+}
+
+// InitVideoUpload starts a direct post with a file upload
+func (c *Client) InitVideoUpload(ctx context.Context, userID string, post PostInfo, source SourceInfo) (*UploadTarget, error) {
+	source.Source = "FILE_UPLOAD"
+	body := map[string]any{"post_info": post, "source_info": source}
+
+	var target UploadTarget
+	if err := c.call(ctx, userID, "POST", "/v2/post/publish/video/init/", body, &target); err != nil {
+		return nil, err
+	}
+	return &target, nil
+}
+
+// UploadChunk sends bytes [start, start+len(chunk)) of a video of the given total size
+func (c *Client) UploadChunk(ctx context.Context, uploadURL string, chunk []byte, start, total int64) error {
+	req, err := http.NewRequestWithContext(ctx, "PUT", uploadURL, bytes.NewReader(chunk))
+	if err != nil {
+		return fmt.Errorf("failed to create chunk request: %w", err)
+	}
+	end := start + int64(len(chunk)) - 1
+	req.Header.Set("Content-Type", "video/mp4")
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, total))
+	req.ContentLength = int64(len(chunk))
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("chunk upload failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 206 while chunks are missing, 201 once the file is complete
+	if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return &APIError{StatusCode: resp.StatusCode, Code: "upload_failed", Message: string(message)}
+	}
+	return nil
+}
+
+// FetchPublishStatus returns the processing status of a post
+func (c *Client) FetchPublishStatus(ctx context.Context, userID, publishID string) (*PublishStatus, error) {
+	var status PublishStatus
+	body := map[string]string{"publish_id": publishID}
+	if err := c.call(ctx, userID, "POST", "/v2/post/publish/status/fetch/", body, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// call sends an authorized JSON request and unwraps the {data, error} envelope into out
+func (c *Client) call(ctx context.Context, userID, method, path string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	token, err := c.tokens.AccessToken(ctx, userID)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("tiktok request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		Data  json.RawMessage `json:"data"`
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+			LogID   string `json:"log_id"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return &APIError{StatusCode: resp.StatusCode, Code: "invalid_response", Message: "status " + strconv.Itoa(resp.StatusCode)}
+	}
+	if resp.StatusCode != http.StatusOK || (envelope.Error.Code != "" && envelope.Error.Code != "ok") {
+		return &APIError{
+			StatusCode: resp.StatusCode,
+			Code:       envelope.Error.Code,
+			Message:    envelope.Error.Message,
+			LogID:      envelope.Error.LogID,
+		}
+	}
+
+	if out != nil && len(envelope.Data) > 0 {
+		if err := json.Unmarshal(envelope.Data, out); err != nil {
+			return fmt.Errorf("failed to decode tiktok response: %w", err)
+		}
+	}
+	return nil
+}
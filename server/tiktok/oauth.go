@@ -0,0 +1,57 @@
+package tiktok
+
+import (
+	"os"
+	"viral-cuts-server/oauth"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ProviderID is the account.provider_id used for connected TikTok accounts
+const ProviderID = "tiktok"
+
+// Scopes requested when connecting an account
+var Scopes = []string{
+	"user.info.basic",
+	"video.upload",
+	"video.publish",
+}
+
+// ErrNotConnected is returned when the user has no usable TikTok account
+var ErrNotConnected = oauth.ErrNotConnected
+
+// NewOAuthConfig builds the TikTok OAuth2 config from the environment.
+// TIKTOK_AUTH_URL and TIKTOK_TOKEN_URL can point at a local stub.
+func NewOAuthConfig() *oauth.Config {
+	redirectURL := os.Getenv("TIKTOK_REDIRECT_URL")
+	if redirectURL == "" {
+		backendURL := os.Getenv("BACKEND_URL")
+		if backendURL == "" {
+			backendURL = "http://localhost:3000"
+		}
+		redirectURL = backendURL + "/api/tiktok/callback"
+	}
+
+	return &oauth.Config{
+		ClientID:       os.Getenv("TIKTOK_CLIENT_KEY"),
+		ClientSecret:   os.Getenv("TIKTOK_CLIENT_SECRET"),
+		AuthURL:        envOr("TIKTOK_AUTH_URL", "https://www.tiktok.com/v2/auth/authorize/"),
+		TokenURL:       envOr("TIKTOK_TOKEN_URL", "https://open.tiktokapis.com/v2/oauth/token/"),
+		RedirectURL:    redirectURL,
+		Scopes:         Scopes,
+		ClientIDParam:  "client_key",
+		ScopeSeparator: ",",
+	}
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// NewTokenStore stores TikTok tokens in the account table
+func NewTokenStore(db *pgxpool.Pool, config *oauth.Config) *oauth.TokenStore {
+	return oauth.NewTokenStore(db, ProviderID, config)
+}
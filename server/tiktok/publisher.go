@@ -0,0 +1,217 @@
+package tiktok
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"viral-cuts-server/models"
+	"viral-cuts-server/storage"
+	"viral-cuts-server/worker"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Chunk limits of the Content Posting API: chunks are 5-64 MB, the last one may absorb the remainder
+const (
+	minChunkSize     = 5 * 1024 * 1024
+	defaultChunkSize = 10 * 1024 * 1024
+)
+
+// errStillProcessing makes the scheduler retry later; the publish_id is kept so nothing is posted twice
+var errStillProcessing = errors.New("tiktok is still processing the video")
+
+// Publisher posts queue items to TikTok (init, chunk upload, publish status polling).
+// The publish_id is stored on the queue row so a restarted worker resumes polling instead of posting again.
+// TikTok can't hold a post until a release time, so the scheduler only hands items over once publish_at has passed.
+type Publisher struct {
+	db     *pgxpool.Pool
+	client *Client
+	source *storage.Source
+
+	PollInterval time.Duration
+	PollTimeout  time.Duration
+}
+
+func NewPublisher(db *pgxpool.Pool, client *Client, source *storage.Source) *Publisher {
+	return &Publisher{
+		db:           db,
+		client:       client,
+		source:       source,
+		PollInterval: 5 * time.Second,
+		PollTimeout:  5 * time.Minute,
+	}
+}
+
+// Publish implements worker.Publisher
+func (p *Publisher) Publish(ctx context.Context, item *models.UploadQueueItem) (string, error) {
+	if item.FileURL == nil || *item.FileURL == "" {
+		return "", worker.Permanent(errors.New("queue item has no file to upload"))
+	}
+
+	var publishID *string
+	err := p.db.QueryRow(ctx,
+		`SELECT platform_publish_id FROM upload_queue WHERE id = $1`,
+		item.ID,
+	).Scan(&publishID)
+	if err != nil {
+		return "", fmt.Errorf("failed to load publish state: %w", err)
+	}
+
+	if publishID == nil {
+		id, err := p.upload(ctx, item)
+		if err != nil {
+			return "", classify(err)
+		}
+		publishID = &id
+	}
+
+	return p.waitForPublish(ctx, item, *publishID)
+}
+
+// upload pushes the video and stores the publish_id
+func (p *Publisher) upload(ctx context.Context, item *models.UploadQueueItem) (string, error) {
+	publishID, err := p.push(ctx, item)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = p.db.Exec(ctx,
+		`UPDATE upload_queue SET platform_publish_id = $1, updated_at = NOW() WHERE id = $2`,
+		publishID, item.ID,
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to save publish id: %w", err)
+	}
+	return publishID, nil
+}
+
+// push checks the visibility, then initialises the post and uploads every chunk of the source video
+func (p *Publisher) push(ctx context.Context, item *models.UploadQueueItem) (string, error) {
+	size, err := p.source.Size(ctx, *item.FileURL)
+	if err != nil {
+		return "", err
+	}
+
+	privacy, err := privacyLevel(item.PrivacyStatus)
+	if err != nil {
+		return "", err
+	}
+
+	chunkSize, chunkCount := chunkLayout(size)
+	target, err := p.client.InitVideoUpload(ctx, item.UserID, PostInfo{
+		Title:        item.Title,
+		PrivacyLevel: privacy,
+	}, SourceInfo{
+		VideoSize:       size,
+		ChunkSize:       chunkSize,
+		TotalChunkCount: chunkCount,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for i := int64(0); i < chunkCount; i++ {
+		start := i * chunkSize
+		end := start + chunkSize - 1
+		if i == chunkCount-1 {
+			end = size - 1
+		}
+
+		chunk, err := p.source.ReadRange(ctx, *item.FileURL, start, end)
+		if err != nil {
+			return "", err
+		}
+		if err := p.client.UploadChunk(ctx, target.UploadURL, chunk, start, size); err != nil {
+			return "", err
+		}
+	}
+	return target.PublishID, nil
+}
+
+// waitForPublish polls the publish status until TikTok finishes processing and returns the post URL,
+// or "" when TikTok hasn't made the post public
+func (p *Publisher) waitForPublish(ctx context.Context, item *models.UploadQueueItem, publishID string) (string, error) {
+	deadline := time.Now().Add(p.PollTimeout)
+
+	for {
+		status, err := p.client.FetchPublishStatus(ctx, item.UserID, publishID)
+		if err != nil {
+			return "", classify(err)
+		}
+
+		switch status.Status {
+		case StatusPublishComplete:
+			return p.shareURL(ctx, item.UserID, status.PostIDs)
+		case StatusSendToUserInbox:
+			// Unaudited apps can only post to the creator's inbox; there is no public URL yet
+			return "", nil
+		case StatusFailed:
+			// Forget the publish_id so a manual retry uploads again
+			p.db.Exec(ctx, `UPDATE upload_queue SET platform_publish_id = NULL WHERE id = $1`, item.ID)
+			return "", worker.Permanent(fmt.Errorf("tiktok rejected the video: %s", status.FailReason))
+		}
+
+		if time.Now().After(deadline) {
+			return "", errStillProcessing
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(p.PollInterval):
+		}
+	}
+}
+
+// shareURL builds the public URL of the post, or "" when TikTok returned no public post
+func (p *Publisher) shareURL(ctx context.Context, userID string, postIDs []string) (string, error) {
+	if len(postIDs) == 0 {
+		return "", nil
+	}
+
+	user, err := p.client.UserInfo(ctx, userID)
+	if err != nil || user.Username == "" {
+		log.Printf("TikTok publisher: failed to load username for share url: %v", err)
+		return "https://www.tiktok.com/video/" + postIDs[0], nil
+	}
+	return fmt.Sprintf("https://www.tiktok.com/@%s/video/%s", user.Username, postIDs[0]), nil
+}
+
+// chunkLayout splits size into TikTok-compatible chunks
+func chunkLayout(size int64) (chunkSize, chunkCount int64) {
+	if size < minChunkSize {
+		return size, 1
+	}
+	chunkSize = defaultChunkSize
+	if size < chunkSize {
+		chunkSize = size
+	}
+	return chunkSize, size / chunkSize
+}
+
+// privacyLevel maps queue visibility to TikTok privacy levels.
+// TikTok has no unlisted posts, and guessing a wider or narrower audience isn't ours to decide.
+func privacyLevel(privacyStatus string) (string, error) {
+	switch privacyStatus {
+	case "public":
+		return "PUBLIC_TO_EVERYONE", nil
+	case "private", "":
+		return "SELF_ONLY", nil
+	default:
+		return "", worker.Permanent(fmt.Errorf("tiktok has no %s visibility, choose private or public", privacyStatus))
+	}
+}
+
+// classify marks errors that retrying won't fix as permanent
+func classify(err error) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && !apiErr.Retryable() {
+		return worker.Permanent(err)
+	}
+	if errors.Is(err, ErrNotConnected) || errors.Is(err, storage.ErrNotFound) {
+		return worker.Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,229 @@
+package tiktok
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"viral-cuts-server/models"
+	"viral-cuts-server/storage"
+)
+
+type staticTokens string
+
+func (t staticTokens) AccessToken(ctx context.Context, userID string) (string, error) {
+	return string(t), nil
+}
+
+// fakeTikTok serves the source video, the Content Posting API and the upload URL
+type fakeTikTok struct {
+	t      *testing.T
+	server *httptest.Server
+	video  []byte
+
+	mu       sync.Mutex
+	init     map[string]any
+	received []byte
+	ranges   []string
+	statuses []string // returned by successive status fetches, the last one repeats
+	polls    int
+}
+
+func newFakeTikTok(t *testing.T, video []byte, statuses ...string) *fakeTikTok {
+	f := &fakeTikTok{t: t, video: video, statuses: statuses}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/videos/clip.mp4", f.serveVideo)
+	mux.HandleFunc("/v2/post/publish/video/init/", f.serveInit)
+	mux.HandleFunc("/upload", f.serveChunk)
+	mux.HandleFunc("/v2/post/publish/status/fetch/", f.serveStatus)
+	mux.HandleFunc("/v2/user/info/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":{"user":{"open_id":"tt-1","username":"cortes"}},"error":{"code":"ok"}}`)
+	})
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeTikTok) serveVideo(w http.ResponseWriter, r *http.Request) {
+	http.ServeContent(w, r, "clip.mp4", time.Time{}, bytes.NewReader(f.video))
+}
+
+func (f *fakeTikTok) serveInit(w http.ResponseWriter, r *http.Request) {
+	if got := r.Header.Get("Authorization"); got != "Bearer access-1" {
+		f.t.Errorf("Authorization = %q", got)
+	}
+	f.mu.Lock()
+	json.NewDecoder(r.Body).Decode(&f.init)
+	f.mu.Unlock()
+	fmt.Fprintf(w, `{"data":{"publish_id":"v_pub_1","upload_url":%q},"error":{"code":"ok"}}`, f.server.URL+"/upload")
+}
+
+func (f *fakeTikTok) serveChunk(w http.ResponseWriter, r *http.Request) {
+	chunk, _ := io.ReadAll(r.Body)
+	f.mu.Lock()
+	f.received = append(f.received, chunk...)
+	f.ranges = append(f.ranges, r.Header.Get("Content-Range"))
+	complete := len(f.received) == len(f.video)
+	f.mu.Unlock()
+
+	if complete {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusPartialContent)
+	}
+}
+
+func (f *fakeTikTok) serveStatus(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		PublishID string `json:"publish_id"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+	if body.PublishID != "v_pub_1" {
+		f.t.Errorf("publish_id = %q", body.PublishID)
+	}
+
+	f.mu.Lock()
+	status := f.statuses[min(f.polls, len(f.statuses)-1)]
+	f.polls++
+	f.mu.Unlock()
+
+	if status == StatusPublishComplete {
+		fmt.Fprintf(w, `{"data":{"status":%q,"publicaly_available_post_id":["7300000000000000001"]},"error":{"code":"ok"}}`, status)
+		return
+	}
+	fmt.Fprintf(w, `{"data":{"status":%q},"error":{"code":"ok"}}`, status)
+}
+
+func (f *fakeTikTok) publisher() *Publisher {
+	client := NewClient(staticTokens("access-1"))
+	client.BaseURL = f.server.URL
+	client.HTTPClient = f.server.Client()
+
+	source := &storage.Source{HTTPClient: f.server.Client()}
+	publisher := NewPublisher(nil, client, source)
+	publisher.PollInterval = time.Millisecond
+	publisher.PollTimeout = time.Second
+	return publisher
+}
+
+func (f *fakeTikTok) item() *models.UploadQueueItem {
+	fileURL := f.server.URL + "/videos/clip.mp4"
+	return &models.UploadQueueItem{ID: "item-1", UserID: "user-1", Title: "Best play", FileURL: &fileURL, PrivacyStatus: "public"}
+}
+
+func TestPushUploadsEveryChunk(t *testing.T) {
+	video := bytes.Repeat([]byte("0123456789abcdef"), (2*defaultChunkSize+3)/16+1)[:2*defaultChunkSize+3]
+	fake := newFakeTikTok(t, video, StatusPublishComplete)
+
+	publishID, err := fake.publisher().push(context.Background(), fake.item())
+	if err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if publishID != "v_pub_1" {
+		t.Errorf("publish id = %q", publishID)
+	}
+
+	post := fake.init["post_info"].(map[string]any)
+	source := fake.init["source_info"].(map[string]any)
+	if post["title"] != "Best play" || post["privacy_level"] != "PUBLIC_TO_EVERYONE" {
+		t.Errorf("post_info = %v", post)
+	}
+	if source["source"] != "FILE_UPLOAD" || source["video_size"] != float64(len(video)) ||
+		source["chunk_size"] != float64(defaultChunkSize) || source["total_chunk_count"] != float64(2) {
+		t.Errorf("source_info = %v", source)
+	}
+
+	wantRanges := []string{
+		fmt.Sprintf("bytes 0-%d/%d", defaultChunkSize-1, len(video)),
+		fmt.Sprintf("bytes %d-%d/%d", defaultChunkSize, len(video)-1, len(video)),
+	}
+	if fmt.Sprint(fake.ranges) != fmt.Sprint(wantRanges) {
+		t.Errorf("chunk ranges = %v, want %v", fake.ranges, wantRanges)
+	}
+	if !bytes.Equal(fake.received, video) {
+		t.Error("uploaded bytes differ from the source video")
+	}
+}
+
+func TestWaitForPublishPollsUntilComplete(t *testing.T) {
+	fake := newFakeTikTok(t, nil, StatusProcessingUpload, StatusProcessingDownload, StatusPublishComplete)
+
+	url, err := fake.publisher().waitForPublish(context.Background(), fake.item(), "v_pub_1")
+	if err != nil {
+		t.Fatalf("waitForPublish: %v", err)
+	}
+	if url != "https://www.tiktok.com/@cortes/video/7300000000000000001" {
+		t.Errorf("url = %q", url)
+	}
+	if fake.polls != 3 {
+		t.Errorf("polled %d times, want 3", fake.polls)
+	}
+}
+
+func TestWaitForPublishTimesOut(t *testing.T) {
+	fake := newFakeTikTok(t, nil, StatusProcessingUpload)
+	publisher := fake.publisher()
+	publisher.PollTimeout = 5 * time.Millisecond
+
+	if _, err := publisher.waitForPublish(context.Background(), fake.item(), "v_pub_1"); err != errStillProcessing {
+		t.Fatalf("err = %v, want errStillProcessing", err)
+	}
+}
+
+func TestWaitForPublishInboxHasNoURL(t *testing.T) {
+	fake := newFakeTikTok(t, nil, StatusProcessingUpload, StatusSendToUserInbox)
+
+	url, err := fake.publisher().waitForPublish(context.Background(), fake.item(), "v_pub_1")
+	if err != nil || url != "" {
+		t.Fatalf("waitForPublish = %q, %v, want no url", url, err)
+	}
+}
+
+func TestPushRejectsUnlisted(t *testing.T) {
+	fake := newFakeTikTok(t, []byte("video"), StatusPublishComplete)
+	item := fake.item()
+	item.PrivacyStatus = "unlisted"
+
+	if _, err := fake.publisher().push(context.Background(), item); err == nil || !strings.Contains(err.Error(), "unlisted") {
+		t.Fatalf("err = %v, want the unlisted visibility rejected", err)
+	}
+	if fake.init != nil {
+		t.Errorf("post was initialised for an unlisted item: %v", fake.init)
+	}
+}
+
+func TestPrivacyLevel(t *testing.T) {
+	for status, want := range map[string]string{"public": "PUBLIC_TO_EVERYONE", "private": "SELF_ONLY", "": "SELF_ONLY"} {
+		if got, err := privacyLevel(status); err != nil || got != want {
+			t.Errorf("privacyLevel(%q) = %q, %v, want %q", status, got, err, want)
+		}
+	}
+	if got, err := privacyLevel("unlisted"); err == nil {
+		t.Errorf("privacyLevel(unlisted) = %q, want an error", got)
+	}
+}
+
+func TestChunkLayout(t *testing.T) {
+	cases := []struct {
+		size             int64
+		chunkSize, count int64
+	}{
+		{size: 1024, chunkSize: 1024, count: 1},
+		{size: minChunkSize, chunkSize: minChunkSize, count: 1},
+		{size: defaultChunkSize + 1, chunkSize: defaultChunkSize, count: 1},
+		{size: 3*defaultChunkSize + 5, chunkSize: defaultChunkSize, count: 3},
+	}
+	for _, tc := range cases {
+		chunkSize, count := chunkLayout(tc.size)
+		if chunkSize != tc.chunkSize || count != tc.count {
+			t.Errorf("chunkLayout(%d) = (%d, %d), want (%d, %d)", tc.size, chunkSize, count, tc.chunkSize, tc.count)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Package tokens issues one-time tokens for emailed links and OAuth state.
+//
+// Every token has a purpose and is stored in the verification table as a SHA-256 hash, so a token
+// issued for one flow is rejected by every other flow and a database leak doesn't yield usable links.
+package tokens
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+	"viral-cuts-server/models"
+	"viral-cuts-server/store"
+	"viral-cuts-server/utils"
+)
+
+// Purpose scopes a token to one flow
+type Purpose string
+
+const (
+	EmailVerify   Purpose = "email_verify"
+	PasswordReset Purpose = "password_reset"
+	EmailChange   Purpose = "email_change"
+	OAuthState    Purpose = "oauth_state"
+	MFAChallenge  Purpose = "mfa_challenge"
+
+	// EmailChangeCancel is sent to the old address of a pending email change
+	EmailChangeCancel Purpose = "email_change_cancel"
+)
+
+// Default lifetimes
+const (
+	EmailVerifyTTL   = 24 * time.Hour
+	PasswordResetTTL = 1 * time.Hour
+	EmailChangeTTL   = 24 * time.Hour
+	OAuthStateTTL    = 10 * time.Minute
+	MFAChallengeTTL  = 5 * time.Minute
+)
+
+// ErrInvalid is returned for unknown, expired, already used or wrong-purpose tokens
+var ErrInvalid = errors.New("invalid or expired token")
+
+// Hash returns the stored form of a token
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Issue creates a token for identifier (an email address or another flow-specific key) and
+// returns the raw value to embed in the link. Only its hash is stored.
+func Issue(ctx context.Context, verifications store.VerificationStore, purpose Purpose, identifier string, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now()
+	err := verifications.Create(ctx, &models.Verification{
+		ID:         utils.GenerateID(),
+		Identifier: identifier,
+		Purpose:    string(purpose),
+		Value:      Hash(token),
+		ExpiresAt:  now.Add(ttl),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Check returns the verification of a valid token without using it up
+func Check(ctx context.Context, verifications store.VerificationStore, purpose Purpose, token string) (*models.Verification, error) {
+	if token == "" {
+		return nil, ErrInvalid
+	}
+	verification, err := verifications.Get(ctx, string(purpose), Hash(token))
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrInvalid
+	}
+	return verification, err
+}
+
+// Consume deletes a valid token and returns its verification. Only one concurrent caller succeeds.
+func Consume(ctx context.Context, verifications store.VerificationStore, purpose Purpose, token string) (*models.Verification, error) {
+	if token == "" {
+		return nil, ErrInvalid
+	}
+	verification, err := verifications.Consume(ctx, string(purpose), Hash(token))
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrInvalid
+	}
+	return verification, err
+}
+
+// Revoke deletes every outstanding token of a purpose for identifier
+func Revoke(ctx context.Context, verifications store.VerificationStore, purpose Purpose, identifier string) error {
+	return verifications.DeleteByIdentifier(ctx, string(purpose), identifier)
+}
@@ -0,0 +1,122 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"viral-cuts-server/store"
+)
+
+func TestIssueStoresHash(t *testing.T) {
+	ctx := context.Background()
+	verifications := store.NewMemory().Verifications
+
+	token, err := Issue(ctx, verifications, PasswordReset, "ana@example.com", time.Hour)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if len(token) != 43 {
+		t.Errorf("token %q has length %d, want 43", token, len(token))
+	}
+
+	// The raw token never reaches the store
+	if _, err := verifications.Get(ctx, string(PasswordReset), token); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("lookup by raw token: err = %v, want ErrNotFound", err)
+	}
+	verification, err := verifications.Get(ctx, string(PasswordReset), Hash(token))
+	if err != nil {
+		t.Fatalf("lookup by hash: %v", err)
+	}
+	if verification.Value != Hash(token) || verification.Identifier != "ana@example.com" || verification.Purpose != string(PasswordReset) {
+		t.Errorf("stored verification = %+v", verification)
+	}
+
+	other, _ := Issue(ctx, verifications, PasswordReset, "ana@example.com", time.Hour)
+	if other == token {
+		t.Error("Issue returned the same token twice")
+	}
+}
+
+func TestCheckAndConsume(t *testing.T) {
+	ctx := context.Background()
+	verifications := store.NewMemory().Verifications
+	token, err := Issue(ctx, verifications, EmailVerify, "ana@example.com", time.Hour)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	// Check leaves the token usable
+	for i := 0; i < 2; i++ {
+		verification, err := Check(ctx, verifications, EmailVerify, token)
+		if err != nil || verification.Identifier != "ana@example.com" {
+			t.Fatalf("Check #%d = %+v, %v", i+1, verification, err)
+		}
+	}
+
+	verification, err := Consume(ctx, verifications, EmailVerify, token)
+	if err != nil || verification.Identifier != "ana@example.com" {
+		t.Fatalf("Consume = %+v, %v", verification, err)
+	}
+	if _, err := Consume(ctx, verifications, EmailVerify, token); !errors.Is(err, ErrInvalid) {
+		t.Errorf("second Consume: err = %v, want ErrInvalid", err)
+	}
+	if _, err := Check(ctx, verifications, EmailVerify, token); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Check after Consume: err = %v, want ErrInvalid", err)
+	}
+}
+
+func TestInvalidTokens(t *testing.T) {
+	ctx := context.Background()
+	verifications := store.NewMemory().Verifications
+	verifyToken, _ := Issue(ctx, verifications, EmailVerify, "ana@example.com", time.Hour)
+	expired, _ := Issue(ctx, verifications, PasswordReset, "ana@example.com", -time.Minute)
+
+	cases := []struct {
+		name    string
+		purpose Purpose
+		token   string
+	}{
+		{"other purpose", PasswordReset, verifyToken},
+		{"expired", PasswordReset, expired},
+		{"unknown", EmailVerify, "not-a-token"},
+		{"empty", EmailVerify, ""},
+	}
+	for _, tc := range cases {
+		if _, err := Check(ctx, verifications, tc.purpose, tc.token); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: Check err = %v, want ErrInvalid", tc.name, err)
+		}
+		if _, err := Consume(ctx, verifications, tc.purpose, tc.token); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: Consume err = %v, want ErrInvalid", tc.name, err)
+		}
+	}
+
+	// A rejected wrong-purpose attempt doesn't use up the token
+	if _, err := Consume(ctx, verifications, EmailVerify, verifyToken); err != nil {
+		t.Errorf("Consume with the right purpose: %v", err)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	ctx := context.Background()
+	verifications := store.NewMemory().Verifications
+	first, _ := Issue(ctx, verifications, PasswordReset, "ana@example.com", time.Hour)
+	second, _ := Issue(ctx, verifications, PasswordReset, "ana@example.com", time.Hour)
+	otherUser, _ := Issue(ctx, verifications, PasswordReset, "bruno@example.com", time.Hour)
+	otherPurpose, _ := Issue(ctx, verifications, EmailVerify, "ana@example.com", time.Hour)
+
+	if err := Revoke(ctx, verifications, PasswordReset, "ana@example.com"); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	for _, token := range []string{first, second} {
+		if _, err := Check(ctx, verifications, PasswordReset, token); !errors.Is(err, ErrInvalid) {
+			t.Errorf("revoked token: err = %v, want ErrInvalid", err)
+		}
+	}
+	if _, err := Check(ctx, verifications, PasswordReset, otherUser); err != nil {
+		t.Errorf("another identifier's token was revoked: %v", err)
+	}
+	if _, err := Check(ctx, verifications, EmailVerify, otherPurpose); err != nil {
+		t.Errorf("another purpose's token was revoked: %v", err)
+	}
+}
@@ -0,0 +1,281 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Encrypted values look like "enc:v<key version>:<base64 payload>". Anything without the prefix is
+// treated as legacy plaintext so rows written before encryption was enabled keep working.
+const secretPrefix = "enc:v"
+
+// ErrNoEncryptionKey is returned when an encrypted value is read without the key that wrote it
+var ErrNoEncryptionKey = errors.New("encryption key not configured")
+
+// Keyring holds the key-encryption keys by version. New values are always written with the current version.
+//
+// Every value gets a fresh random data key: the value is sealed with the data key and the data key is
+// sealed with the keyring key (envelope encryption). Rotate decrypts and re-encrypts the whole value,
+// so it gets a new data key as well as the current keyring key.
+type Keyring struct {
+	current int
+	keys    map[int][]byte
+}
+
+// NewKeyring builds a keyring from 32-byte AES-256 keys indexed by version
+func NewKeyring(current int, keys map[int][]byte) (*Keyring, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("encryption key version %d is not configured", current)
+	}
+	for version, key := range keys {
+		if version < 1 {
+			return nil, fmt.Errorf("invalid encryption key version %d", version)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("encryption key version %d must be 32 bytes, got %d", version, len(key))
+		}
+	}
+	return &Keyring{current: current, keys: keys}, nil
+}
+
+// KeyringFromEnv parses ENCRYPTION_KEYS ("1:<base64 key>,2:<base64 key>") and ENCRYPTION_KEY_VERSION
+// (defaults to the highest version). It returns nil when no keys are configured, which is an error
+// when GO_ENV=production so secrets are never stored in plaintext there.
+func KeyringFromEnv() (*Keyring, error) {
+	raw := strings.TrimSpace(os.Getenv("ENCRYPTION_KEYS"))
+	if raw == "" {
+		if os.Getenv("GO_ENV") == "production" {
+			return nil, errors.New("ENCRYPTION_KEYS must be set in production")
+		}
+		return nil, nil
+	}
+
+	keys := map[int][]byte{}
+	current := 0
+	for _, entry := range strings.Split(raw, ",") {
+		versionText, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			return nil, errors.New("ENCRYPTION_KEYS entries must look like <version>:<base64 key>")
+		}
+		version, err := strconv.Atoi(versionText)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key version %q", versionText)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key version %d is not valid base64: %w", version, err)
+		}
+		keys[version] = key
+		if version > current {
+			current = version
+		}
+	}
+
+	if v := os.Getenv("ENCRYPTION_KEY_VERSION"); v != "" {
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ENCRYPTION_KEY_VERSION %q", v)
+		}
+		current = version
+	}
+
+	return NewKeyring(current, keys)
+}
+
+// CurrentVersion returns the key version new values are encrypted with
+func (k *Keyring) CurrentVersion() int {
+	return k.current
+}
+
+// Encrypt seals plaintext under the current key. A nil keyring stores plaintext.
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	if k == nil {
+		return plaintext, nil
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	wrappedKey, err := seal(k.keys[k.current], dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+
+	// payload = len(wrapped key) | wrapped key | ciphertext
+	payload := make([]byte, 0, 1+len(wrappedKey)+len(ciphertext))
+	payload = append(payload, byte(len(wrappedKey)))
+	payload = append(payload, wrappedKey...)
+	payload = append(payload, ciphertext...)
+
+	return secretPrefix + strconv.Itoa(k.current) + ":" + base64.RawURLEncoding.EncodeToString(payload), nil
+}
+
+// Decrypt opens a value written by Encrypt. Values without the prefix are returned unchanged.
+func (k *Keyring) Decrypt(value string) (string, error) {
+	version, payload, ok, err := parseSecret(value)
+	if err != nil || !ok {
+		return value, err
+	}
+	if k == nil {
+		return "", ErrNoEncryptionKey
+	}
+	key, found := k.keys[version]
+	if !found {
+		return "", fmt.Errorf("%w (version %d)", ErrNoEncryptionKey, version)
+	}
+
+	if len(payload) < 1 || len(payload) < 1+int(payload[0]) {
+		return "", errors.New("malformed encrypted value")
+	}
+	wrappedKey := payload[1 : 1+int(payload[0])]
+	ciphertext := payload[1+int(payload[0]):]
+
+	dataKey, err := open(key, wrappedKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	plaintext, err := open(dataKey, ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation reports whether value is plaintext or encrypted under an older key
+func (k *Keyring) NeedsRotation(value string) bool {
+	if k == nil {
+		return false
+	}
+	version, _, ok, err := parseSecret(value)
+	return err == nil && (!ok || version != k.current)
+}
+
+// Rotate re-encrypts value under the current key
+func (k *Keyring) Rotate(value string) (string, error) {
+	plaintext, err := k.Decrypt(value)
+	if err != nil {
+		return "", err
+	}
+	return k.Encrypt(plaintext)
+}
+
+func parseSecret(value string) (version int, payload []byte, ok bool, err error) {
+	if !strings.HasPrefix(value, secretPrefix) {
+		return 0, nil, false, nil
+	}
+	versionText, encoded, found := strings.Cut(strings.TrimPrefix(value, secretPrefix), ":")
+	if !found {
+		return 0, nil, false, errors.New("malformed encrypted value")
+	}
+	version, err = strconv.Atoi(versionText)
+	if err != nil {
+		return 0, nil, false, errors.New("malformed encrypted value")
+	}
+	payload, err = base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0, nil, false, errors.New("malformed encrypted value")
+	}
+	return version, payload, true, nil
+}
+
+// seal encrypts with AES-256-GCM and prepends the nonce
+func seal(key, plaintext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key, sealed []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+var (
+	secretsOnce    sync.Once
+	secretsKeyring *Keyring
+	secretsErr     error
+)
+
+// SecretsKeyring returns the process-wide keyring loaded from the environment
+func SecretsKeyring() (*Keyring, error) {
+	secretsOnce.Do(func() {
+		secretsKeyring, secretsErr = KeyringFromEnv()
+	})
+	return secretsKeyring, secretsErr
+}
+
+// EncryptSecret encrypts a third-party secret (API keys, OAuth tokens) before it is stored
+func EncryptSecret(plaintext string) (string, error) {
+	keyring, err := SecretsKeyring()
+	if err != nil {
+		return "", err
+	}
+	return keyring.Encrypt(plaintext)
+}
+
+// DecryptSecret decrypts a value stored with EncryptSecret
+func DecryptSecret(value string) (string, error) {
+	keyring, err := SecretsKeyring()
+	if err != nil {
+		return "", err
+	}
+	return keyring.Decrypt(value)
+}
+
+// EncryptSecretPtr is EncryptSecret for nullable columns
+func EncryptSecretPtr(plaintext *string) (*string, error) {
+	if plaintext == nil {
+		return nil, nil
+	}
+	value, err := EncryptSecret(*plaintext)
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+// DecryptSecretPtr is DecryptSecret for nullable columns
+func DecryptSecretPtr(value *string) (*string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	plaintext, err := DecryptSecret(*value)
+	if err != nil {
+		return nil, err
+	}
+	return &plaintext, nil
+}
@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testKeyring(t *testing.T, current int, versions ...int) *Keyring {
+	t.Helper()
+	keys := map[int][]byte{}
+	for _, version := range versions {
+		keys[version] = bytes.Repeat([]byte{byte(version)}, 32)
+	}
+	keyring, err := NewKeyring(current, keys)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	return keyring
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	keyring := testKeyring(t, 1, 1)
+
+	first, err := keyring.Encrypt("opus-api-key")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	second, _ := keyring.Encrypt("opus-api-key")
+	if !strings.HasPrefix(first, "enc:v1:") || strings.Contains(first, "opus-api-key") {
+		t.Errorf("ciphertext = %q", first)
+	}
+	if first == second {
+		t.Error("encrypting twice produced the same ciphertext")
+	}
+
+	for _, value := range []string{first, second} {
+		plaintext, err := keyring.Decrypt(value)
+		if err != nil || plaintext != "opus-api-key" {
+			t.Errorf("Decrypt = %q, %v", plaintext, err)
+		}
+	}
+}
+
+func TestDecryptLegacyPlaintext(t *testing.T) {
+	keyring := testKeyring(t, 1, 1)
+	for _, k := range []*Keyring{keyring, nil} {
+		plaintext, err := k.Decrypt("ya29.legacy-token")
+		if err != nil || plaintext != "ya29.legacy-token" {
+			t.Errorf("Decrypt = %q, %v, want the value unchanged", plaintext, err)
+		}
+	}
+
+	var none *Keyring
+	if value, _ := none.Encrypt("secret"); value != "secret" {
+		t.Errorf("nil keyring Encrypt = %q, want plaintext", value)
+	}
+}
+
+func TestDecryptUnknownKeyVersion(t *testing.T) {
+	old := testKeyring(t, 2, 2)
+	value, _ := old.Encrypt("secret")
+
+	if _, err := testKeyring(t, 1, 1).Decrypt(value); !errors.Is(err, ErrNoEncryptionKey) {
+		t.Errorf("err = %v, want ErrNoEncryptionKey", err)
+	}
+	var none *Keyring
+	if _, err := none.Decrypt(value); !errors.Is(err, ErrNoEncryptionKey) {
+		t.Errorf("nil keyring err = %v, want ErrNoEncryptionKey", err)
+	}
+}
+
+func TestDecryptTamperedCiphertext(t *testing.T) {
+	keyring := testKeyring(t, 1, 1)
+	value, _ := keyring.Encrypt("secret")
+
+	prefix, encoded, _ := strings.Cut(strings.TrimPrefix(value, secretPrefix), ":")
+	payload, _ := base64.RawURLEncoding.DecodeString(encoded)
+	payload[len(payload)-1] ^= 0x01
+	tampered := secretPrefix + prefix + ":" + base64.RawURLEncoding.EncodeToString(payload)
+
+	if _, err := keyring.Decrypt(tampered); err == nil {
+		t.Error("decrypted a tampered value")
+	}
+	if _, err := keyring.Decrypt("enc:v1:%%%"); err == nil {
+		t.Error("decrypted a malformed value")
+	}
+	if _, err := keyring.Decrypt("enc:v1:" + base64.RawURLEncoding.EncodeToString([]byte{200, 1, 2})); err == nil {
+		t.Error("decrypted a truncated value")
+	}
+}
+
+func TestNeedsRotationAndRotate(t *testing.T) {
+	v1 := testKeyring(t, 1, 1)
+	old, _ := v1.Encrypt("secret")
+
+	keyring := testKeyring(t, 2, 1, 2)
+	current, _ := keyring.Encrypt("secret")
+
+	cases := []struct {
+		value string
+		want  bool
+	}{
+		{"plaintext", true},
+		{old, true},
+		{current, false},
+		{"enc:v1", false}, // malformed values are left for a human to look at
+	}
+	for _, tc := range cases {
+		if got := keyring.NeedsRotation(tc.value); got != tc.want {
+			t.Errorf("NeedsRotation(%.12q) = %v, want %v", tc.value, got, tc.want)
+		}
+	}
+
+	for _, value := range []string{"plaintext", old} {
+		rotated, err := keyring.Rotate(value)
+		if err != nil {
+			t.Fatalf("Rotate: %v", err)
+		}
+		if !strings.HasPrefix(rotated, "enc:v2:") || keyring.NeedsRotation(rotated) {
+			t.Errorf("rotated = %q, want a v2 value", rotated)
+		}
+		want := value
+		if value == old {
+			want = "secret"
+		}
+		if plaintext, _ := keyring.Decrypt(rotated); plaintext != want {
+			t.Errorf("rotated value decrypts to %q, want %q", plaintext, want)
+		}
+	}
+}
+
+func TestKeyringFromEnv(t *testing.T) {
+	key1 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	key2 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))
+
+	t.Setenv("ENCRYPTION_KEYS", "")
+	if keyring, err := KeyringFromEnv(); keyring != nil || err != nil {
+		t.Errorf("unset = %v, %v, want nil keyring", keyring, err)
+	}
+	t.Setenv("GO_ENV", "production")
+	if _, err := KeyringFromEnv(); err == nil {
+		t.Error("accepted missing keys in production")
+	}
+	t.Setenv("GO_ENV", "")
+
+	t.Setenv("ENCRYPTION_KEYS", "1:"+key1+", 2:"+key2)
+	keyring, err := KeyringFromEnv()
+	if err != nil || keyring.CurrentVersion() != 2 {
+		t.Fatalf("keyring = %v, %v, want current version 2", keyring, err)
+	}
+
+	t.Setenv("ENCRYPTION_KEY_VERSION", "1")
+	if keyring, err := KeyringFromEnv(); err != nil || keyring.CurrentVersion() != 1 {
+		t.Errorf("ENCRYPTION_KEY_VERSION ignored: %v, %v", keyring, err)
+	}
+
+	t.Setenv("ENCRYPTION_KEY_VERSION", "3")
+	if _, err := KeyringFromEnv(); err == nil {
+		t.Error("accepted a current version without a key")
+	}
+
+	t.Setenv("ENCRYPTION_KEY_VERSION", "")
+	for _, raw := range []string{key1, "1:" + base64.StdEncoding.EncodeToString([]byte("short")), "x:" + key1, "0:" + key1} {
+		t.Setenv("ENCRYPTION_KEYS", raw)
+		if _, err := KeyringFromEnv(); err == nil {
+			t.Errorf("accepted ENCRYPTION_KEYS=%q", raw)
+		}
+	}
+}
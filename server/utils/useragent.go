@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+// UserAgentInfo is the device summary shown in session lists
+type UserAgentInfo struct {
+	Browser string `json:"browser"`
+	OS      string `json:"os"`
+	Device  string `json:"device"` // desktop, mobile, tablet, bot or unknown
+}
+
+// browserPatterns are tried in order: most browsers also claim to be Chrome and Safari
+var browserPatterns = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"Edge", regexp.MustCompile(`Edg(?:e|A|iOS)?/(\d+)`)},
+	{"Opera", regexp.MustCompile(`(?:OPR|Opera)/(\d+)`)},
+	{"Samsung Internet", regexp.MustCompile(`SamsungBrowser/(\d+)`)},
+	{"Firefox", regexp.MustCompile(`(?:Firefox|FxiOS)/(\d+)`)},
+	{"Chrome", regexp.MustCompile(`(?:Chrome|CriOS)/(\d+)`)},
+	{"Safari", regexp.MustCompile(`Version/(\d+).*Safari/`)},
+}
+
+// ParseUserAgent extracts browser, OS and device type from a User-Agent header.
+// It only recognises the common browsers; anything else is reported as "Unknown".
+func ParseUserAgent(userAgent string) UserAgentInfo {
+	info := UserAgentInfo{Browser: "Unknown", OS: "Unknown", Device: "desktop"}
+
+	for _, browser := range browserPatterns {
+		if match := browser.pattern.FindStringSubmatch(userAgent); match != nil {
+			info.Browser = browser.name + " " + match[1]
+			break
+		}
+	}
+
+	switch {
+	case strings.Contains(userAgent, "iPhone"):
+		info.OS = "iOS"
+	case strings.Contains(userAgent, "iPad"):
+		info.OS = "iPadOS"
+	case strings.Contains(userAgent, "Android"):
+		info.OS = "Android"
+	case strings.Contains(userAgent, "Windows"):
+		info.OS = "Windows"
+	case strings.Contains(userAgent, "CrOS"):
+		info.OS = "ChromeOS"
+	case strings.Contains(userAgent, "Mac OS X"):
+		info.OS = "macOS"
+	case strings.Contains(userAgent, "Linux"):
+		info.OS = "Linux"
+	}
+
+	lower := strings.ToLower(userAgent)
+	switch {
+	case strings.Contains(lower, "bot") || strings.Contains(lower, "crawler") || strings.Contains(lower, "spider"):
+		info.Device = "bot"
+	case strings.Contains(userAgent, "iPad") || strings.Contains(userAgent, "Tablet") ||
+		(info.OS == "Android" && !strings.Contains(userAgent, "Mobile")):
+		info.Device = "tablet"
+	case strings.Contains(userAgent, "Mobile") || info.OS == "iOS":
+		info.Device = "mobile"
+	case info.Browser == "Unknown" && info.OS == "Unknown":
+		info.Device = "unknown"
+	}
+	return info
+}
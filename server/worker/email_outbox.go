@@ -0,0 +1,142 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"viral-cuts-server/mail"
+	"viral-cuts-server/models"
+	"viral-cuts-server/store"
+)
+
+// OutboxConfig controls polling and retry behaviour of the email outbox
+type OutboxConfig struct {
+	PollInterval time.Duration // how often to look for due emails
+	BatchSize    int           // max emails claimed per poll
+	MaxAttempts  int           // attempts before an email is dead-lettered
+	BaseBackoff  time.Duration // first retry delay, doubled on every attempt
+	MaxBackoff   time.Duration
+	ClaimTimeout time.Duration // emails stuck in sending longer than this are reclaimed
+	SendTimeout  time.Duration // deadline of each send; BatchSize sends must fit well within ClaimTimeout
+	Retention    time.Duration // sent emails are deleted after this long
+}
+
+// DefaultOutboxConfig polls often since sign-up and password reset wait on these emails
+func DefaultOutboxConfig() OutboxConfig {
+	return OutboxConfig{
+		PollInterval: 5 * time.Second,
+		BatchSize:    20,
+		MaxAttempts:  8,
+		BaseBackoff:  30 * time.Second,
+		MaxBackoff:   time.Hour,
+		ClaimTimeout: 5 * time.Minute,
+		SendTimeout:  10 * time.Second,
+		Retention:    7 * 24 * time.Hour,
+	}
+}
+
+// EmailOutbox delivers the emails handlers queue in the outbox table.
+// A crash between claiming and sending re-sends the email after ClaimTimeout, so delivery is at least once.
+// Results are only recorded while the claim is still ours, so a reclaimed email isn't overwritten.
+type EmailOutbox struct {
+	outbox store.OutboxStore
+	mailer mail.Mailer
+	config OutboxConfig
+}
+
+func NewEmailOutbox(outbox store.OutboxStore, mailer mail.Mailer, config OutboxConfig) *EmailOutbox {
+	return &EmailOutbox{outbox: outbox, mailer: mailer, config: config}
+}
+
+// Start polls for due emails until ctx is cancelled
+func (o *EmailOutbox) Start(ctx context.Context) {
+	ticker := time.NewTicker(o.config.PollInterval)
+	defer ticker.Stop()
+
+	log.Printf("Email outbox started (interval %s)", o.config.PollInterval)
+
+	lastCleanup := time.Time{}
+	for {
+		if err := o.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Email outbox: %v", err)
+		}
+		if time.Since(lastCleanup) > time.Hour {
+			o.cleanup(ctx)
+			lastCleanup = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Email outbox stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce claims and sends one batch of due emails
+func (o *EmailOutbox) RunOnce(ctx context.Context) error {
+	emails, err := o.outbox.ClaimDue(ctx, o.config.BatchSize, time.Now().Add(-o.config.ClaimTimeout))
+	if err != nil {
+		return fmt.Errorf("failed to claim due emails: %w", err)
+	}
+
+	for _, email := range emails {
+		o.deliver(ctx, email)
+	}
+	return nil
+}
+
+func (o *EmailOutbox) deliver(ctx context.Context, email *models.OutboxEmail) {
+	sendCtx, cancel := context.WithTimeout(ctx, o.config.SendTimeout)
+	defer cancel()
+
+	err := o.mailer.Send(sendCtx, &mail.Message{
+		From:    email.Sender,
+		To:      email.Recipient,
+		Subject: email.Subject,
+		HTML:    email.HTML,
+		Text:    email.Text,
+	})
+	if err != nil {
+		o.fail(ctx, email, err)
+		return
+	}
+
+	if err := o.outbox.MarkSent(ctx, email.ID, *email.ClaimedAt); errors.Is(err, store.ErrNotFound) {
+		log.Printf("Email outbox: email %s was sent after its claim expired", email.ID)
+	} else if err != nil {
+		log.Printf("Email outbox: failed to mark email %s as sent: %v", email.ID, err)
+	}
+}
+
+// fail schedules a retry with exponential backoff, or dead-letters the email when it was rejected
+// or is out of attempts
+func (o *EmailOutbox) fail(ctx context.Context, email *models.OutboxEmail, cause error) {
+	if errors.Is(cause, mail.ErrRejected) || email.Attempts >= o.config.MaxAttempts {
+		if err := o.outbox.MarkDead(ctx, email.ID, *email.ClaimedAt, cause.Error()); err != nil {
+			log.Printf("Email outbox: failed to mark email %s as dead: %v", email.ID, err)
+		}
+		log.Printf("Email outbox: email %s to %s failed permanently after %d attempt(s): %v", email.ID, email.Recipient, email.Attempts, cause)
+		return
+	}
+
+	nextAttempt := time.Now().Add(backoff(o.config.BaseBackoff, o.config.MaxBackoff, email.Attempts))
+	if err := o.outbox.Reschedule(ctx, email.ID, *email.ClaimedAt, nextAttempt, cause.Error()); err != nil {
+		log.Printf("Email outbox: failed to reschedule email %s: %v", email.ID, err)
+	}
+	log.Printf("Email outbox: email %s attempt %d failed, retrying at %s: %v", email.ID, email.Attempts, nextAttempt.Format(time.RFC3339), cause)
+}
+
+func (o *EmailOutbox) cleanup(ctx context.Context) {
+	removed, err := o.outbox.DeleteSent(ctx, time.Now().Add(-o.config.Retention))
+	if err != nil {
+		log.Printf("Email outbox cleanup failed: %v", err)
+		return
+	}
+	if removed > 0 {
+		log.Printf("Email outbox cleanup removed %d sent email(s)", removed)
+	}
+}
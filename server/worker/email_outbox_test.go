@@ -0,0 +1,122 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"viral-cuts-server/mail"
+	"viral-cuts-server/models"
+	"viral-cuts-server/store"
+)
+
+func enqueueTestEmail(t *testing.T, outbox store.OutboxStore, id string) {
+	t.Helper()
+	now := time.Now()
+	err := outbox.Enqueue(context.Background(), &models.OutboxEmail{
+		ID:            id,
+		Sender:        "ViralCuts <noreply@viralcuts.com>",
+		Recipient:     "ana@example.com",
+		Subject:       "Verify your email",
+		HTML:          "<p>hi</p>",
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	})
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+}
+
+func outboxEmail(t *testing.T, outbox store.OutboxStore, id string) *models.OutboxEmail {
+	t.Helper()
+	emails, _, err := outbox.List(context.Background(), store.OutboxFilter{Limit: 100})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	for _, email := range emails {
+		if email.ID == id {
+			return email
+		}
+	}
+	t.Fatalf("email %s not found", id)
+	return nil
+}
+
+func TestEmailOutboxDelivers(t *testing.T) {
+	s := store.NewMemory()
+	recorder := mail.NewRecorder()
+	enqueueTestEmail(t, s.Outbox, "e1")
+
+	if err := NewEmailOutbox(s.Outbox, recorder, DefaultOutboxConfig()).RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+
+	if sent := recorder.SentTo("ana@example.com"); len(sent) != 1 || sent[0].Subject != "Verify your email" {
+		t.Fatalf("sent = %+v, want one verification email", sent)
+	}
+	if email := outboxEmail(t, s.Outbox, "e1"); email.Status != models.OutboxStatusSent {
+		t.Errorf("status = %q, want sent", email.Status)
+	}
+}
+
+func TestEmailOutboxSendTimeout(t *testing.T) {
+	s := store.NewMemory()
+	enqueueTestEmail(t, s.Outbox, "e1")
+
+	// A server that never answers must not hold the batch past the send deadline
+	hanging := mailerFunc(func(ctx context.Context, msg *mail.Message) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	config := DefaultOutboxConfig()
+	config.SendTimeout = 50 * time.Millisecond
+
+	start := time.Now()
+	if err := NewEmailOutbox(s.Outbox, hanging, config).RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("RunOnce took %s, want it bounded by SendTimeout", elapsed)
+	}
+
+	email := outboxEmail(t, s.Outbox, "e1")
+	if email.Status != models.OutboxStatusPending || email.LastError == nil ||
+		!strings.Contains(*email.LastError, context.DeadlineExceeded.Error()) {
+		t.Errorf("email = %+v, want pending with a deadline error", email)
+	}
+}
+
+func TestEmailOutboxLostClaim(t *testing.T) {
+	s := store.NewMemory()
+	ctx := context.Background()
+	enqueueTestEmail(t, s.Outbox, "e1")
+
+	first, err := s.Outbox.ClaimDue(ctx, 10, time.Now().Add(-time.Minute))
+	if err != nil || len(first) != 1 {
+		t.Fatalf("first claim = %v, %v", first, err)
+	}
+	// Another machine reclaims the email as stale while the first send is still running
+	time.Sleep(time.Millisecond)
+	second, err := s.Outbox.ClaimDue(ctx, 10, time.Now().Add(time.Minute))
+	if err != nil || len(second) != 1 {
+		t.Fatalf("second claim = %v, %v", second, err)
+	}
+
+	if err := s.Outbox.MarkDead(ctx, "e1", *first[0].ClaimedAt, "boom"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("MarkDead with the old claim = %v, want ErrNotFound", err)
+	}
+	if err := s.Outbox.MarkSent(ctx, "e1", *second[0].ClaimedAt); err != nil {
+		t.Fatalf("MarkSent with the current claim: %v", err)
+	}
+	if email := outboxEmail(t, s.Outbox, "e1"); email.Status != models.OutboxStatusSent {
+		t.Errorf("status = %q, want sent", email.Status)
+	}
+}
+
+type mailerFunc func(ctx context.Context, msg *mail.Message) error
+
+func (f mailerFunc) Send(ctx context.Context, msg *mail.Message) error {
+	return f(ctx, msg)
+}
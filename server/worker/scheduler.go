@@ -0,0 +1,311 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"viral-cuts-server/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Publisher uploads a queue item to its platform and returns the public URL,
+// or "" when the platform doesn't give one yet
+type Publisher interface {
+	Publish(ctx context.Context, item *models.UploadQueueItem) (string, error)
+}
+
+// PublisherFunc adapts a function to the Publisher interface
+type PublisherFunc func(ctx context.Context, item *models.UploadQueueItem) (string, error)
+
+func (f PublisherFunc) Publish(ctx context.Context, item *models.UploadQueueItem) (string, error) {
+	return f(ctx, item)
+}
+
+// ReleaseScheduler is implemented by publishers whose platform holds a post until publish_at by itself.
+// Their items are uploaded as soon as they are queued; items of other platforms wait for publish_at.
+type ReleaseScheduler interface {
+	SchedulesRelease() bool
+}
+
+// permanentError marks a failure that must not be retried
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the scheduler fails the item immediately instead of retrying
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// SchedulerConfig controls polling and retry behaviour
+type SchedulerConfig struct {
+	PollInterval time.Duration // how often to look for due items
+	BatchSize    int           // max items published per poll
+	MaxAttempts  int           // attempts before an item is marked as error
+	BaseBackoff  time.Duration // first retry delay, doubled on every attempt
+	MaxBackoff   time.Duration
+	ClaimTimeout time.Duration // items without a heartbeat for longer than this are reclaimed
+}
+
+// DefaultSchedulerConfig returns sensible defaults for a single shared-cpu Fly machine
+func DefaultSchedulerConfig() SchedulerConfig {
+	return SchedulerConfig{
+		PollInterval: 30 * time.Second,
+		BatchSize:    5,
+		MaxAttempts:  5,
+		BaseBackoff:  time.Minute,
+		MaxBackoff:   time.Hour,
+		ClaimTimeout: 2 * time.Hour,
+	}
+}
+
+// Scheduler publishes queue items once they are due.
+// Items are claimed with FOR UPDATE SKIP LOCKED so several machines can poll the same table safely.
+// Each item is claimed right before it is published, so no claimed item waits behind another upload
+// long enough to look stale to the other machines, and a heartbeat keeps the claim fresh while it uploads.
+// Results are only recorded while the claim is still ours, so a reclaimed item isn't overwritten.
+type Scheduler struct {
+	db         *pgxpool.Pool
+	config     SchedulerConfig
+	publishers map[string]Publisher
+	releasing  []string // platforms whose publisher schedules the release at publish_at
+}
+
+func NewScheduler(db *pgxpool.Pool, config SchedulerConfig) *Scheduler {
+	return &Scheduler{
+		db:         db,
+		config:     config,
+		publishers: make(map[string]Publisher),
+	}
+}
+
+// Register sets the publisher used for queue items of the given platform
+func (s *Scheduler) Register(platform string, publisher Publisher) {
+	s.publishers[platform] = publisher
+	if releaser, ok := publisher.(ReleaseScheduler); ok && releaser.SchedulesRelease() {
+		s.releasing = append(s.releasing, platform)
+	}
+}
+
+// Start polls for due items until ctx is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.config.PollInterval)
+	defer ticker.Stop()
+
+	log.Printf("Upload scheduler started (interval %s)", s.config.PollInterval)
+
+	for {
+		if err := s.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Upload scheduler: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Upload scheduler stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce claims and publishes up to BatchSize due items, one at a time
+func (s *Scheduler) RunOnce(ctx context.Context) error {
+	for i := 0; i < s.config.BatchSize && ctx.Err() == nil; i++ {
+		claimed, err := s.claimNext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to claim due item: %w", err)
+		}
+		if claimed == nil {
+			return nil
+		}
+		s.process(ctx, claimed)
+	}
+	return nil
+}
+
+// claimNext moves the next due item to uploading in a single statement and returns nil when none is due.
+// Items with publish_at on a platform that schedules the release itself are uploaded immediately;
+// other items wait until scheduled_at and publish_at have passed, and items with neither are due right away.
+func (s *Scheduler) claimNext(ctx context.Context) (*claim, error) {
+	var claimedAt time.Time
+	item, err := models.ScanUploadQueueItem(claimRow{
+		row: s.db.QueryRow(ctx,
+			`UPDATE upload_queue
+			 SET status = 'uploading', claimed_at = NOW(), heartbeat_at = NOW(), attempts = attempts + 1, updated_at = NOW()
+			 WHERE id IN (
+			     SELECT id FROM upload_queue
+			     WHERE (
+			         status = 'ready'
+			         AND (
+			             (publish_at IS NOT NULL AND platform = ANY($2))
+			             OR COALESCE(GREATEST(scheduled_at, publish_at), NOW()) <= NOW()
+			         )
+			         AND (next_attempt_at IS NULL OR next_attempt_at <= NOW())
+			     ) OR (
+			         status = 'uploading'
+			         AND COALESCE(heartbeat_at, claimed_at) < NOW() - make_interval(secs => $1)
+			     )
+			     ORDER BY COALESCE(scheduled_at, created_at)
+			     LIMIT 1
+			     FOR UPDATE SKIP LOCKED
+			 )
+			 RETURNING claimed_at, `+models.QueueItemColumns,
+			s.config.ClaimTimeout.Seconds(), s.releasing,
+		),
+		claimedAt: &claimedAt,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &claim{item: item, claimedAt: claimedAt}, nil
+}
+
+// claim is a queue item in uploading together with the claimed_at that identifies this claim
+type claim struct {
+	item      *models.UploadQueueItem
+	claimedAt time.Time
+}
+
+// claimRow scans claimed_at ahead of the queue item columns
+type claimRow struct {
+	row       pgx.Row
+	claimedAt *time.Time
+}
+
+func (r claimRow) Scan(dest ...any) error {
+	return r.row.Scan(append([]any{r.claimedAt}, dest...)...)
+}
+
+// heartbeat refreshes heartbeat_at until ctx is cancelled, so an upload that takes longer than
+// ClaimTimeout isn't reclaimed by another machine
+func (s *Scheduler) heartbeat(ctx context.Context, c *claim) {
+	ticker := time.NewTicker(s.config.ClaimTimeout / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		tag, err := s.db.Exec(ctx,
+			`UPDATE upload_queue SET heartbeat_at = NOW()
+			 WHERE id = $1 AND status = 'uploading' AND claimed_at = $2`,
+			c.item.ID, c.claimedAt,
+		)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Upload scheduler: failed to refresh claim on item %s: %v", c.item.ID, err)
+			}
+			continue
+		}
+		if tag.RowsAffected() == 0 {
+			log.Printf("Upload scheduler: lost the claim on item %s while uploading", c.item.ID)
+			return
+		}
+	}
+}
+
+func (s *Scheduler) process(ctx context.Context, c *claim) {
+	item := c.item
+	platform := ""
+	if item.Platform != nil {
+		platform = *item.Platform
+	}
+
+	publisher, ok := s.publishers[platform]
+	if !ok {
+		s.fail(ctx, c, Permanent(fmt.Errorf("no publisher configured for platform %q", platform)))
+		return
+	}
+
+	publishCtx, stopHeartbeat := context.WithCancel(ctx)
+	go s.heartbeat(publishCtx, c)
+	url, err := publisher.Publish(publishCtx, item)
+	stopHeartbeat()
+	if err != nil {
+		s.fail(ctx, c, err)
+		return
+	}
+
+	tag, err := s.db.Exec(ctx,
+		`UPDATE upload_queue
+		 SET status = 'done', uploaded_url = NULLIF($1, ''), error_message = NULL, claimed_at = NULL, heartbeat_at = NULL,
+		     next_attempt_at = NULL, updated_at = NOW()
+		 WHERE id = $2 AND status = 'uploading' AND claimed_at = $3`,
+		url, item.ID, c.claimedAt,
+	)
+	if err != nil {
+		log.Printf("Upload scheduler: failed to mark item %s as done: %v", item.ID, err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		log.Printf("Upload scheduler: item %s was published after its claim expired", item.ID)
+		return
+	}
+	log.Printf("Upload scheduler: published item %s to %s", item.ID, platform)
+}
+
+// fail schedules a retry with exponential backoff, or marks the item as error when out of attempts
+func (s *Scheduler) fail(ctx context.Context, c *claim, cause error) {
+	item := c.item
+	var permanent *permanentError
+	if errors.As(cause, &permanent) || item.Attempts >= s.config.MaxAttempts {
+		tag, err := s.db.Exec(ctx,
+			`UPDATE upload_queue
+			 SET status = 'error', error_message = $1, claimed_at = NULL, heartbeat_at = NULL, next_attempt_at = NULL,
+			     updated_at = NOW()
+			 WHERE id = $2 AND status = 'uploading' AND claimed_at = $3`,
+			cause.Error(), item.ID, c.claimedAt,
+		)
+		if err != nil {
+			log.Printf("Upload scheduler: failed to mark item %s as error: %v", item.ID, err)
+		} else if tag.RowsAffected() == 0 {
+			log.Printf("Upload scheduler: item %s failed after its claim expired: %v", item.ID, cause)
+			return
+		}
+		log.Printf("Upload scheduler: item %s failed permanently after %d attempt(s): %v", item.ID, item.Attempts, cause)
+		return
+	}
+
+	nextAttempt := time.Now().Add(backoff(s.config.BaseBackoff, s.config.MaxBackoff, item.Attempts))
+	tag, err := s.db.Exec(ctx,
+		`UPDATE upload_queue
+		 SET status = 'ready', claimed_at = NULL, heartbeat_at = NULL, next_attempt_at = $1, updated_at = NOW()
+		 WHERE id = $2 AND status = 'uploading' AND claimed_at = $3`,
+		nextAttempt, item.ID, c.claimedAt,
+	)
+	if err != nil {
+		log.Printf("Upload scheduler: failed to reschedule item %s: %v", item.ID, err)
+	} else if tag.RowsAffected() == 0 {
+		log.Printf("Upload scheduler: item %s failed after its claim expired: %v", item.ID, cause)
+		return
+	}
+	log.Printf("Upload scheduler: item %s attempt %d failed, retrying at %s: %v", item.ID, item.Attempts, nextAttempt.Format(time.RFC3339), cause)
+}
+
+// backoff returns the delay before retrying after the given attempt: base, doubled per attempt, capped at maxDelay
+func backoff(base, maxDelay time.Duration, attempt int) time.Duration {
+	delay := base
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= maxDelay {
+			return maxDelay
+		}
+	}
+	return delay
+}
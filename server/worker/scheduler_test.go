@@ -0,0 +1,91 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	want := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 10 * time.Minute, 10 * time.Minute}
+	for i, delay := range want {
+		if got := backoff(time.Minute, 10*time.Minute, i+1); got != delay {
+			t.Errorf("backoff(%d) = %s, want %s", i+1, got, delay)
+		}
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	if Permanent(nil) != nil {
+		t.Error("Permanent(nil) != nil")
+	}
+
+	cause := errors.New("video rejected")
+	err := fmt.Errorf("publish: %w", Permanent(cause))
+	var permanent *permanentError
+	if !errors.As(err, &permanent) {
+		t.Error("wrapped permanent error not detected")
+	}
+	if !errors.Is(err, cause) || err.Error() != "publish: video rejected" {
+		t.Errorf("err = %v, want the cause unchanged", err)
+	}
+	if errors.As(errors.New("timeout"), &permanent) {
+		t.Error("plain error detected as permanent")
+	}
+}
+
+// fakeRow scans fixed values into pointers of matching types
+type fakeRow []any
+
+func (r fakeRow) Scan(dest ...any) error {
+	if len(dest) != len(r) {
+		return fmt.Errorf("scanned %d values into %d destinations", len(r), len(dest))
+	}
+	for i, value := range r {
+		switch d := dest[i].(type) {
+		case *time.Time:
+			*d = value.(time.Time)
+		case *string:
+			*d = value.(string)
+		default:
+			return fmt.Errorf("unexpected destination %T", d)
+		}
+	}
+	return nil
+}
+
+func TestClaimRowScansClaimedAtFirst(t *testing.T) {
+	claimedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	var gotClaimedAt time.Time
+	var id, title string
+
+	row := claimRow{row: fakeRow{claimedAt, "item-1", "Corte 1"}, claimedAt: &gotClaimedAt}
+	if err := row.Scan(&id, &title); err != nil {
+		t.Fatal(err)
+	}
+	if !gotClaimedAt.Equal(claimedAt) || id != "item-1" || title != "Corte 1" {
+		t.Errorf("scanned claimed_at = %s, id = %q, title = %q", gotClaimedAt, id, title)
+	}
+}
+
+type releasingPublisher struct {
+	PublisherFunc
+	schedules bool
+}
+
+func (p releasingPublisher) SchedulesRelease() bool { return p.schedules }
+
+func TestRegisterTracksReleasingPlatforms(t *testing.T) {
+	s := NewScheduler(nil, DefaultSchedulerConfig())
+	s.Register("YouTube", releasingPublisher{schedules: true})
+	s.Register("Instagram", releasingPublisher{schedules: false})
+	s.Register("TikTok", PublisherFunc(nil))
+
+	if fmt.Sprint(s.releasing) != "[YouTube]" {
+		t.Errorf("releasing platforms = %v, want [YouTube]", s.releasing)
+	}
+	if len(s.publishers) != 3 {
+		t.Errorf("registered %d publishers, want 3", len(s.publishers))
+	}
+}
@@ -0,0 +1,49 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+	"viral-cuts-server/store"
+)
+
+// SessionCollector deletes expired sessions so the session table doesn't grow without bound.
+// Expired sessions are already rejected on use; this only reclaims the rows.
+type SessionCollector struct {
+	sessions    store.SessionStore
+	maxLifetime time.Duration
+	Interval    time.Duration
+}
+
+// NewSessionCollector removes sessions past their expiry or older than maxLifetime
+func NewSessionCollector(sessions store.SessionStore, maxLifetime time.Duration) *SessionCollector {
+	return &SessionCollector{sessions: sessions, maxLifetime: maxLifetime, Interval: time.Hour}
+}
+
+// Start collects once right away and then every Interval until ctx is cancelled
+func (c *SessionCollector) Start(ctx context.Context) {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+
+	for {
+		c.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce deletes the sessions that are no longer usable
+func (c *SessionCollector) RunOnce(ctx context.Context) {
+	removed, err := c.sessions.DeleteExpired(ctx, time.Now().Add(-c.maxLifetime))
+	if err != nil {
+		log.Printf("Session cleanup failed: %v", err)
+		return
+	}
+	if removed > 0 {
+		log.Printf("Session cleanup removed %d expired session(s)", removed)
+	}
+}
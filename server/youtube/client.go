@@ -0,0 +1,359 @@
+package youtube
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrQuotaExceeded is matched (errors.Is) by API errors caused by an exhausted quota
+var ErrQuotaExceeded = errors.New("youtube quota exceeded")
+
+// APIError is an error response of a Google API
+type APIError struct {
+	StatusCode int
+	Reason     string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("youtube api error %d (%s): %s", e.StatusCode, e.Reason, e.Message)
+	}
+	return fmt.Sprintf("youtube api error %d: %s", e.StatusCode, e.Message)
+}
+
+// Is makes errors.Is(err, ErrQuotaExceeded) work for quota errors
+func (e *APIError) Is(target error) bool {
+	if target != ErrQuotaExceeded {
+		return false
+	}
+	switch e.Reason {
+	case "quotaExceeded", "dailyLimitExceeded", "rateLimitExceeded", "userRateLimitExceeded":
+		return true
+	}
+	return false
+}
+
+// AccessTokenSource returns a valid access token for a user
+type AccessTokenSource interface {
+	AccessToken(ctx context.Context, userID string) (string, error)
+}
+
+// Quota reserves and records Data API quota units. *QuotaTracker is the Postgres implementation.
+type Quota interface {
+	// Reserve fails with an ErrQuotaExceeded error when cost units don't fit in today's quota
+	Reserve(ctx context.Context, cost int) error
+	// Record adds cost units to today's usage of the user
+	Record(ctx context.Context, userID string, cost int) error
+}
+
+// Client wraps the YouTube Data API v3 and the YouTube Analytics API v2.
+// Base URLs are fields so tests can point them at an httptest server.
+type Client struct {
+	BaseURL          string // Data API, e.g. https://www.googleapis.com/youtube/v3
+	UploadBaseURL    string // Upload API, e.g. https://www.googleapis.com/upload/youtube/v3
+	AnalyticsBaseURL string // Analytics API, e.g. https://youtubeanalytics.googleapis.com/v2
+	HTTPClient       *http.Client
+
+	tokens AccessTokenSource
+	quota  Quota
+}
+
+// NewClient creates a client using the public Google endpoints, overridable with
+// YOUTUBE_API_BASE_URL, YOUTUBE_UPLOAD_BASE_URL and YOUTUBE_ANALYTICS_BASE_URL.
+// quota may be nil to disable quota accounting.
+func NewClient(tokens AccessTokenSource, quota Quota) *Client {
+	return &Client{
+		BaseURL:          envOr("YOUTUBE_API_BASE_URL", "https://www.googleapis.com/youtube/v3"),
+		UploadBaseURL:    envOr("YOUTUBE_UPLOAD_BASE_URL", "https://www.googleapis.com/upload/youtube/v3"),
+		AnalyticsBaseURL: envOr("YOUTUBE_ANALYTICS_BASE_URL", "https://youtubeanalytics.googleapis.com/v2"),
+		HTTPClient:       &http.Client{Timeout: 30 * time.Second},
+		tokens:           tokens,
+		quota:            quota,
+	}
+}
+
+// Thumbnail is a single thumbnail size
+type Thumbnail struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+// Thumbnails maps size names (default, medium, high...) to thumbnails
+type Thumbnails map[string]Thumbnail
+
+// Channel is a channels.list item
+type Channel struct {
+	ID      string `json:"id"`
+	Snippet struct {
+		Title       string     `json:"title"`
+		Description string     `json:"description"`
+		CustomURL   string     `json:"customUrl"`
+		PublishedAt time.Time  `json:"publishedAt"`
+		Thumbnails  Thumbnails `json:"thumbnails"`
+	} `json:"snippet"`
+	Statistics struct {
+		ViewCount             string `json:"viewCount"`
+		SubscriberCount       string `json:"subscriberCount"`
+		HiddenSubscriberCount bool   `json:"hiddenSubscriberCount"`
+		VideoCount            string `json:"videoCount"`
+	} `json:"statistics"`
+	ContentDetails struct {
+		RelatedPlaylists struct {
+			Uploads string `json:"uploads"`
+		} `json:"relatedPlaylists"`
+	} `json:"contentDetails"`
+	BrandingSettings json.RawMessage `json:"brandingSettings,omitempty"`
+}
+
+// PlaylistItem is a playlistItems.list item
+type PlaylistItem struct {
+	ID      string `json:"id"`
+	Snippet struct {
+		Title       string     `json:"title"`
+		Description string     `json:"description"`
+		PublishedAt time.Time  `json:"publishedAt"`
+		Thumbnails  Thumbnails `json:"thumbnails"`
+		ResourceID  struct {
+			VideoID string `json:"videoId"`
+		} `json:"resourceId"`
+	} `json:"snippet"`
+}
+
+// Video is a videos.list item
+type Video struct {
+	ID      string `json:"id"`
+	Snippet struct {
+		Title        string     `json:"title"`
+		Description  string     `json:"description"`
+		ChannelTitle string     `json:"channelTitle"`
+		PublishedAt  time.Time  `json:"publishedAt"`
+		Thumbnails   Thumbnails `json:"thumbnails"`
+		Tags         []string   `json:"tags"`
+	} `json:"snippet"`
+	Statistics struct {
+		ViewCount    string `json:"viewCount"`
+		LikeCount    string `json:"likeCount"`
+		CommentCount string `json:"commentCount"`
+	} `json:"statistics"`
+}
+
+// SearchResult is a search.list item
+type SearchResult struct {
+	ID struct {
+		VideoID string `json:"videoId"`
+	} `json:"id"`
+	Snippet struct {
+		Title        string     `json:"title"`
+		Description  string     `json:"description"`
+		ChannelTitle string     `json:"channelTitle"`
+		PublishedAt  time.Time  `json:"publishedAt"`
+		Thumbnails   Thumbnails `json:"thumbnails"`
+	} `json:"snippet"`
+}
+
+// AnalyticsReport is a reports.query response
+type AnalyticsReport struct {
+	ColumnHeaders []struct {
+		Name       string `json:"name"`
+		ColumnType string `json:"columnType"`
+		DataType   string `json:"dataType"`
+	} `json:"columnHeaders"`
+	Rows [][]any `json:"rows"`
+}
+
+type listResponse[T any] struct {
+	Items         []T    `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// MyChannel returns the authenticated user's channel (channels.list mine=true)
+func (c *Client) MyChannel(ctx context.Context, userID string) (*Channel, error) {
+	var resp listResponse[Channel]
+	params := url.Values{
+		"part": {"snippet,statistics,contentDetails,brandingSettings"},
+		"mine": {"true"},
+	}
+	if err := c.get(ctx, userID, c.BaseURL+"/channels", params, CostChannelsList, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Items) == 0 {
+		return nil, &APIError{StatusCode: http.StatusNotFound, Reason: "channelNotFound", Message: "no channel for this account"}
+	}
+	return &resp.Items[0], nil
+}
+
+// PlaylistItems lists the items of a playlist (playlistItems.list)
+func (c *Client) PlaylistItems(ctx context.Context, userID, playlistID string, maxResults int) ([]PlaylistItem, error) {
+	var resp listResponse[PlaylistItem]
+	params := url.Values{
+		"part":       {"snippet"},
+		"playlistId": {playlistID},
+		"maxResults": {strconv.Itoa(clampMaxResults(maxResults))},
+	}
+	if err := c.get(ctx, userID, c.BaseURL+"/playlistItems", params, CostPlaylistItemsList, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Items, nil
+}
+
+// Videos returns snippet and statistics for up to 50 video ids (videos.list)
+func (c *Client) Videos(ctx context.Context, userID string, ids []string) ([]Video, error) {
+	if len(ids) == 0 {
+		return []Video{}, nil
+	}
+	var resp listResponse[Video]
+	params := url.Values{
+		"part": {"snippet,statistics"},
+		"id":   {strings.Join(ids, ",")},
+	}
+	if err := c.get(ctx, userID, c.BaseURL+"/videos", params, CostVideosList, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Items, nil
+}
+
+// Search searches public videos (search.list, 100 units per call)
+func (c *Client) Search(ctx context.Context, userID, query string, maxResults int) ([]SearchResult, error) {
+	var resp listResponse[SearchResult]
+	params := url.Values{
+		"part":          {"snippet"},
+		"q":             {query},
+		"type":          {"video"},
+		"maxResults":    {strconv.Itoa(clampMaxResults(maxResults))},
+		"videoDuration": {"medium"},
+	}
+	if err := c.get(ctx, userID, c.BaseURL+"/search", params, CostSearchList, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Items, nil
+}
+
+// DailyViews returns the channel's views per day between start and end (YouTube Analytics).
+// Analytics calls use a separate quota and are not counted against the Data API quota.
+func (c *Client) DailyViews(ctx context.Context, userID string, start, end time.Time) (*AnalyticsReport, error) {
+	var report AnalyticsReport
+	params := url.Values{
+		"ids":        {"channel==MINE"},
+		"startDate":  {start.Format("2006-01-02")},
+		"endDate":    {end.Format("2006-01-02")},
+		"metrics":    {"views"},
+		"dimensions": {"day"},
+		"sort":       {"day"},
+	}
+	if err := c.get(ctx, userID, c.AnalyticsBaseURL+"/reports", params, 0, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (c *Client) get(ctx context.Context, userID, endpoint string, params url.Values, cost int, out any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := c.do(ctx, userID, req, cost)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode youtube response: %w", err)
+	}
+	return nil
+}
+
+// do authorizes req, enforces and records quota, and converts error responses to *APIError.
+// The caller must close the body of the returned response.
+func (c *Client) do(ctx context.Context, userID string, req *http.Request, cost int) (*http.Response, error) {
+	if c.quota != nil && cost > 0 {
+		if err := c.quota.Reserve(ctx, cost); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.authorize(ctx, userID, req); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("youtube request failed: %w", err)
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		c.recordQuota(ctx, userID, cost)
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	apiErr := parseAPIError(resp)
+	// Google charges quota for rejected requests too, except when the quota is already gone
+	if !errors.Is(apiErr, ErrQuotaExceeded) {
+		c.recordQuota(ctx, userID, cost)
+	}
+	return nil, apiErr
+}
+
+func (c *Client) authorize(ctx context.Context, userID string, req *http.Request) error {
+	token, err := c.tokens.AccessToken(ctx, userID)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	return nil
+}
+
+func (c *Client) recordQuota(ctx context.Context, userID string, cost int) {
+	if c.quota == nil || cost <= 0 {
+		return
+	}
+	if err := c.quota.Record(ctx, userID, cost); err != nil {
+		log.Printf("YouTube quota: %v", err)
+	}
+}
+
+func parseAPIError(resp *http.Response) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode, Message: resp.Status}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var payload struct {
+		Error struct {
+			Message string `json:"message"`
+			Errors  []struct {
+				Reason string `json:"reason"`
+			} `json:"errors"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &payload) == nil {
+		if payload.Error.Message != "" {
+			apiErr.Message = payload.Error.Message
+		}
+		if len(payload.Error.Errors) > 0 {
+			apiErr.Reason = payload.Error.Errors[0].Reason
+		}
+	}
+	return apiErr
+}
+
+func clampMaxResults(n int) int {
+	if n <= 0 {
+		return 5
+	}
+	if n > 50 {
+		return 50
+	}
+	return n
+}
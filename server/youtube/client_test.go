@@ -0,0 +1,192 @@
+package youtube
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type staticTokens string
+
+func (t staticTokens) AccessToken(ctx context.Context, userID string) (string, error) {
+	return string(t), nil
+}
+
+// fakeQuota records usage in memory and refuses reservations past limit
+type fakeQuota struct {
+	mu    sync.Mutex
+	limit int
+	used  map[string]int
+}
+
+func newFakeQuota(limit int) *fakeQuota {
+	return &fakeQuota{limit: limit, used: map[string]int{}}
+}
+
+func (q *fakeQuota) Reserve(ctx context.Context, cost int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	total := 0
+	for _, units := range q.used {
+		total += units
+	}
+	if total+cost > q.limit {
+		return &APIError{StatusCode: http.StatusForbidden, Reason: "quotaExceeded", Message: "local quota limit reached"}
+	}
+	return nil
+}
+
+func (q *fakeQuota) Record(ctx context.Context, userID string, cost int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.used[userID] += cost
+	return nil
+}
+
+func (q *fakeQuota) usedBy(userID string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.used[userID]
+}
+
+func newTestClient(t *testing.T, handler http.HandlerFunc, quota Quota) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := NewClient(staticTokens("access-1"), quota)
+	client.BaseURL = server.URL
+	client.AnalyticsBaseURL = server.URL
+	client.HTTPClient = server.Client()
+	return client
+}
+
+func TestMyChannel(t *testing.T) {
+	quota := newFakeQuota(DefaultDailyQuota)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/channels" || r.URL.Query().Get("mine") != "true" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer access-1" {
+			t.Errorf("Authorization = %q", got)
+		}
+		w.Write([]byte(`{"items":[{"id":"UC123","snippet":{"title":"Cortes"},"contentDetails":{"relatedPlaylists":{"uploads":"UU123"}}}]}`))
+	}, quota)
+
+	channel, err := client.MyChannel(context.Background(), "user-1")
+	if err != nil {
+		t.Fatalf("MyChannel: %v", err)
+	}
+	if channel.ID != "UC123" || channel.Snippet.Title != "Cortes" || channel.ContentDetails.RelatedPlaylists.Uploads != "UU123" {
+		t.Errorf("channel = %+v", channel)
+	}
+	if got := quota.usedBy("user-1"); got != CostChannelsList {
+		t.Errorf("recorded %d units, want %d", got, CostChannelsList)
+	}
+}
+
+func TestSearchRecordsItsCost(t *testing.T) {
+	quota := newFakeQuota(DefaultDailyQuota)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("q") != "podcast" || r.URL.Query().Get("maxResults") != "50" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		w.Write([]byte(`{"items":[{"id":{"videoId":"v1"}},{"id":{"videoId":"v2"}}]}`))
+	}, quota)
+
+	results, err := client.Search(context.Background(), "user-1", "podcast", 500)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 2 || results[1].ID.VideoID != "v2" {
+		t.Errorf("results = %+v", results)
+	}
+	if got := quota.usedBy("user-1"); got != CostSearchList {
+		t.Errorf("recorded %d units, want %d", got, CostSearchList)
+	}
+}
+
+func TestQuotaExceededResponse(t *testing.T) {
+	quota := newFakeQuota(DefaultDailyQuota)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error":{"code":403,"message":"The request cannot be completed because you have exceeded your quota.","errors":[{"reason":"quotaExceeded"}]}}`))
+	}, quota)
+
+	_, err := client.Videos(context.Background(), "user-1", []string{"v1"})
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("err = %v, want ErrQuotaExceeded", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden || apiErr.Reason != "quotaExceeded" {
+		t.Errorf("err = %#v", err)
+	}
+	// Google doesn't charge requests rejected for an exhausted quota
+	if got := quota.usedBy("user-1"); got != 0 {
+		t.Errorf("recorded %d units, want 0", got)
+	}
+}
+
+func TestRejectedRequestIsCharged(t *testing.T) {
+	quota := newFakeQuota(DefaultDailyQuota)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"code":400,"message":"Invalid playlist id","errors":[{"reason":"invalidValue"}]}}`))
+	}, quota)
+
+	_, err := client.PlaylistItems(context.Background(), "user-1", "nope", 10)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Reason != "invalidValue" || apiErr.Message != "Invalid playlist id" {
+		t.Fatalf("err = %v, want invalidValue APIError", err)
+	}
+	if errors.Is(err, ErrQuotaExceeded) {
+		t.Error("invalidValue must not match ErrQuotaExceeded")
+	}
+	if got := quota.usedBy("user-1"); got != CostPlaylistItemsList {
+		t.Errorf("recorded %d units, want %d", got, CostPlaylistItemsList)
+	}
+}
+
+func TestLocalQuotaBlocksRequest(t *testing.T) {
+	quota := newFakeQuota(CostSearchList - 1)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request sent despite the local quota")
+	}, quota)
+
+	_, err := client.Search(context.Background(), "user-1", "podcast", 5)
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("err = %v, want ErrQuotaExceeded", err)
+	}
+}
+
+func TestAnalyticsIsNotCharged(t *testing.T) {
+	quota := newFakeQuota(0)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/reports" || r.URL.Query().Get("ids") != "channel==MINE" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`{"columnHeaders":[{"name":"day"},{"name":"views"}],"rows":[["2026-10-01",42]]}`))
+	}, quota)
+
+	report, err := client.DailyViews(context.Background(), "user-1", time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 7, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("DailyViews: %v", err)
+	}
+	if len(report.Rows) != 1 || report.Rows[0][1] != float64(42) {
+		t.Errorf("rows = %v", report.Rows)
+	}
+}
+
+func TestQuotaDayUsesPacificTime(t *testing.T) {
+	// 06:59 UTC is still the previous day in Los Angeles (PDT, UTC-7)
+	if got := QuotaDay(time.Date(2026, 10, 2, 6, 59, 0, 0, time.UTC)); got != "2026-10-01" {
+		t.Errorf("QuotaDay = %s, want 2026-10-01", got)
+	}
+	if got := QuotaDay(time.Date(2026, 10, 2, 7, 0, 0, 0, time.UTC)); got != "2026-10-02" {
+		t.Errorf("QuotaDay = %s, want 2026-10-02", got)
+	}
+}
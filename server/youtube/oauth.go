@@ -0,0 +1,57 @@
+package youtube
+
+import (
+	"os"
+	"viral-cuts-server/oauth"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ProviderID is the account.provider_id used for connected YouTube channels
+const ProviderID = "youtube"
+
+// Scopes requested when connecting a channel
+var Scopes = []string{
+	"openid",
+	"email",
+	"https://www.googleapis.com/auth/youtube.readonly",
+	"https://www.googleapis.com/auth/youtube.upload",
+	"https://www.googleapis.com/auth/yt-analytics.readonly",
+}
+
+// ErrNotConnected is returned when the user has no usable YouTube account
+var ErrNotConnected = oauth.ErrNotConnected
+
+// NewOAuthConfig builds the Google OAuth2 config from the environment.
+// GOOGLE_AUTH_URL and GOOGLE_TOKEN_URL can point at a local fake server.
+func NewOAuthConfig() *oauth.Config {
+	redirectURL := os.Getenv("YOUTUBE_REDIRECT_URL")
+	if redirectURL == "" {
+		backendURL := os.Getenv("BACKEND_URL")
+		if backendURL == "" {
+			backendURL = "http://localhost:3000"
+		}
+		redirectURL = backendURL + "/api/youtube/callback"
+	}
+
+	return &oauth.Config{
+		ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
+		ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
+		AuthURL:      envOr("GOOGLE_AUTH_URL", "https://accounts.google.com/o/oauth2/v2/auth"),
+		TokenURL:     envOr("GOOGLE_TOKEN_URL", "https://oauth2.googleapis.com/token"),
+		RedirectURL:  redirectURL,
+		Scopes:       Scopes,
+	}
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// NewTokenStore stores YouTube tokens in the account table
+func NewTokenStore(db *pgxpool.Pool, config *oauth.Config) *oauth.TokenStore {
+	return oauth.NewTokenStore(db, ProviderID, config)
+}
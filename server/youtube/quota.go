@@ -0,0 +1,130 @@
+package youtube
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+	_ "time/tzdata" // the runtime image has no zoneinfo
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Quota unit costs of the YouTube Data API v3 methods we call.
+// See https://developers.google.com/youtube/v3/determine_quota_cost
+const (
+	CostChannelsList      = 1
+	CostPlaylistItemsList = 1
+	CostVideosList        = 1
+	CostSearchList        = 100
+	CostVideosInsert      = 1600
+)
+
+// DefaultDailyQuota is the default daily quota of a Google Cloud project
+const DefaultDailyQuota = 10000
+
+// quotaLocation is the timezone in which YouTube resets daily quotas
+var quotaLocation = mustLoadLocation("America/Los_Angeles")
+
+func mustLoadLocation(name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return location
+}
+
+// QuotaDay returns the quota day (midnight Pacific time) containing t
+func QuotaDay(t time.Time) string {
+	return t.In(quotaLocation).Format("2006-01-02")
+}
+
+// QuotaUsage is the quota spent on one day
+type QuotaUsage struct {
+	ProjectID    string `json:"projectId"`
+	Day          string `json:"day"`
+	UserUnits    int    `json:"userUnits"`
+	UserCalls    int    `json:"userCalls"`
+	ProjectUnits int    `json:"projectUnits"`
+	DailyLimit   int    `json:"dailyLimit"`
+}
+
+// QuotaTracker persists per-user and per-project quota usage
+type QuotaTracker struct {
+	db         *pgxpool.Pool
+	projectID  string
+	dailyLimit int
+}
+
+func NewQuotaTracker(db *pgxpool.Pool, projectID string, dailyLimit int) *QuotaTracker {
+	if dailyLimit <= 0 {
+		dailyLimit = DefaultDailyQuota
+	}
+	return &QuotaTracker{db: db, projectID: projectID, dailyLimit: dailyLimit}
+}
+
+// NewQuotaTrackerFromEnv reads YOUTUBE_PROJECT_ID and YOUTUBE_DAILY_QUOTA
+func NewQuotaTrackerFromEnv(db *pgxpool.Pool) *QuotaTracker {
+	dailyLimit, _ := strconv.Atoi(os.Getenv("YOUTUBE_DAILY_QUOTA"))
+	return NewQuotaTracker(db, envOr("YOUTUBE_PROJECT_ID", "default"), dailyLimit)
+}
+
+// Reserve checks that the project still has room for cost units today
+func (q *QuotaTracker) Reserve(ctx context.Context, cost int) error {
+	var used int
+	err := q.db.QueryRow(ctx,
+		`SELECT COALESCE(SUM(units), 0) FROM youtube_quota_usage WHERE project_id = $1 AND usage_date = $2`,
+		q.projectID, QuotaDay(time.Now()),
+	).Scan(&used)
+	if err != nil {
+		return fmt.Errorf("failed to read quota usage: %w", err)
+	}
+	if used+cost > q.dailyLimit {
+		return &APIError{
+			StatusCode: 403,
+			Reason:     "quotaExceeded",
+			Message:    fmt.Sprintf("local quota limit reached (%d of %d units used today)", used, q.dailyLimit),
+		}
+	}
+	return nil
+}
+
+// Record adds cost units to today's usage of the user
+func (q *QuotaTracker) Record(ctx context.Context, userID string, cost int) error {
+	_, err := q.db.Exec(ctx,
+		`INSERT INTO youtube_quota_usage (project_id, user_id, usage_date, units, calls, updated_at)
+		 VALUES ($1, $2, $3, $4, 1, NOW())
+		 ON CONFLICT (project_id, user_id, usage_date)
+		 DO UPDATE SET units = youtube_quota_usage.units + EXCLUDED.units,
+		               calls = youtube_quota_usage.calls + 1,
+		               updated_at = NOW()`,
+		q.projectID, userID, QuotaDay(time.Now()), cost,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record quota usage: %w", err)
+	}
+	return nil
+}
+
+// Usage returns today's usage of the user and of the whole project
+func (q *QuotaTracker) Usage(ctx context.Context, userID string) (*QuotaUsage, error) {
+	usage := &QuotaUsage{
+		ProjectID:  q.projectID,
+		Day:        QuotaDay(time.Now()),
+		DailyLimit: q.dailyLimit,
+	}
+
+	err := q.db.QueryRow(ctx,
+		`SELECT COALESCE(SUM(units) FILTER (WHERE user_id = $3), 0),
+		        COALESCE(SUM(calls) FILTER (WHERE user_id = $3), 0),
+		        COALESCE(SUM(units), 0)
+		 FROM youtube_quota_usage
+		 WHERE project_id = $1 AND usage_date = $2`,
+		q.projectID, usage.Day, userID,
+	).Scan(&usage.UserUnits, &usage.UserCalls, &usage.ProjectUnits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quota usage: %w", err)
+	}
+	return usage, nil
+}
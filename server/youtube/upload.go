@@ -0,0 +1,359 @@
+package youtube
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+	"viral-cuts-server/models"
+	"viral-cuts-server/storage"
+	"viral-cuts-server/worker"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// chunkSize must be a multiple of 256 KiB (resumable upload protocol requirement)
+const chunkSize = 32 * 256 * 1024 // 8 MiB
+
+// statusResumeIncomplete is the status YouTube uses for partially uploaded sessions
+const statusResumeIncomplete = 308
+
+var rangeHeaderPattern = regexp.MustCompile(`bytes=0-(\d+)`)
+
+// errSessionExpired means the resumable session URI is no longer valid and the upload has to restart
+var errSessionExpired = errors.New("upload session expired")
+
+// Uploader publishes queue items to YouTube with the resumable upload protocol.
+// The session URI and byte offset are persisted on the queue row after every chunk,
+// so an upload interrupted by a restart continues where it stopped.
+type Uploader struct {
+	db     *pgxpool.Pool
+	client *Client
+	source *storage.Source
+
+	// HTTPClient sends chunks; redirects are never followed
+	// because YouTube answers chunk uploads with 308 Resume Incomplete.
+	HTTPClient *http.Client
+}
+
+func NewUploader(db *pgxpool.Pool, client *Client, source *storage.Source) *Uploader {
+	return &Uploader{
+		db:     db,
+		client: client,
+		source: source,
+		HTTPClient: &http.Client{
+			Timeout: 10 * time.Minute,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// SchedulesRelease implements worker.ReleaseScheduler: YouTube keeps a video private until its publishAt
+func (u *Uploader) SchedulesRelease() bool {
+	return true
+}
+
+type uploadState struct {
+	sessionURI  *string
+	offset      int64
+	size        *int64
+	uploadedURL *string
+}
+
+// Publish implements worker.Publisher
+func (u *Uploader) Publish(ctx context.Context, item *models.UploadQueueItem) (string, error) {
+	if item.FileURL == nil || *item.FileURL == "" {
+		return "", worker.Permanent(errors.New("queue item has no file to upload"))
+	}
+
+	state, err := u.loadState(ctx, item.ID)
+	if err != nil {
+		return "", err
+	}
+	// A previous run finished the upload but crashed before marking the item as done
+	if state.uploadedURL != nil && *state.uploadedURL != "" {
+		return *state.uploadedURL, nil
+	}
+
+	size := int64(0)
+	if state.size != nil {
+		size = *state.size
+	} else {
+		size, err = u.source.Size(ctx, *item.FileURL)
+		if err != nil {
+			return "", permanentIfMissing(err)
+		}
+	}
+
+	if state.sessionURI != nil {
+		offset, videoID, err := u.queryProgress(ctx, item.UserID, *state.sessionURI, size)
+		if errors.Is(err, errSessionExpired) {
+			log.Printf("YouTube upload: session for item %s expired, restarting", item.ID)
+			state.sessionURI = nil
+		} else if err != nil {
+			return "", err
+		} else if videoID != "" {
+			return u.finish(ctx, item.ID, videoID)
+		} else {
+			state.offset = offset
+		}
+	}
+
+	if state.sessionURI == nil {
+		sessionURI, err := u.startSession(ctx, item, size)
+		if err != nil {
+			return "", err
+		}
+		state.sessionURI = &sessionURI
+		state.offset = 0
+		if err := u.saveProgress(ctx, item.ID, &sessionURI, 0, size); err != nil {
+			return "", err
+		}
+	}
+
+	for {
+		offset, videoID, err := u.sendChunk(ctx, item, *state.sessionURI, state.offset, size)
+		if errors.Is(err, errSessionExpired) {
+			// Drop the session so the next attempt starts a fresh one
+			u.saveProgress(ctx, item.ID, nil, 0, size)
+			return "", err
+		} else if err != nil {
+			return "", err
+		}
+		if videoID != "" {
+			return u.finish(ctx, item.ID, videoID)
+		}
+		if offset <= state.offset {
+			return "", fmt.Errorf("upload made no progress at offset %d", offset)
+		}
+
+		state.offset = offset
+		if err := u.saveProgress(ctx, item.ID, state.sessionURI, offset, size); err != nil {
+			return "", err
+		}
+	}
+}
+
+// startSession initiates a resumable upload with the video metadata and returns the session URI
+func (u *Uploader) startSession(ctx context.Context, item *models.UploadQueueItem, size int64) (string, error) {
+	settings, err := u.loadSettings(ctx, item.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	metadata, err := json.Marshal(videoMetadata(item, settings))
+	if err != nil {
+		return "", fmt.Errorf("failed to encode video metadata: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST",
+		u.client.UploadBaseURL+"/videos?uploadType=resumable&part=snippet,status", bytes.NewReader(metadata))
+	if err != nil {
+		return "", fmt.Errorf("failed to create upload request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("X-Upload-Content-Length", strconv.FormatInt(size, 10))
+	req.Header.Set("X-Upload-Content-Type", "video/*")
+
+	resp, err := u.client.do(ctx, item.UserID, req, CostVideosInsert)
+	if err != nil {
+		return "", permanentIfRejected(err)
+	}
+	defer resp.Body.Close()
+
+	sessionURI := resp.Header.Get("Location")
+	if sessionURI == "" {
+		return "", errors.New("youtube did not return an upload session URI")
+	}
+	return sessionURI, nil
+}
+
+// queryProgress asks YouTube how many bytes of the session it has received
+func (u *Uploader) queryProgress(ctx context.Context, userID, sessionURI string, size int64) (int64, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "PUT", sessionURI, nil)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to create status request: %w", err)
+	}
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+	req.ContentLength = 0
+
+	return u.sessionRequest(ctx, userID, req)
+}
+
+// sendChunk uploads the next chunk starting at offset
+func (u *Uploader) sendChunk(ctx context.Context, item *models.UploadQueueItem, sessionURI string, offset, size int64) (int64, string, error) {
+	end := offset + chunkSize
+	if end > size {
+		end = size
+	}
+
+	chunk, err := u.source.ReadRange(ctx, *item.FileURL, offset, end-1)
+	if err != nil {
+		return 0, "", permanentIfMissing(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", sessionURI, bytes.NewReader(chunk))
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to create chunk request: %w", err)
+	}
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, end-1, size))
+	req.ContentLength = int64(len(chunk))
+
+	return u.sessionRequest(ctx, item.UserID, req)
+}
+
+// sessionRequest sends a request to the session URI and returns either the next offset or the finished video id
+func (u *Uploader) sessionRequest(ctx context.Context, userID string, req *http.Request) (int64, string, error) {
+	if err := u.client.authorize(ctx, userID, req); err != nil {
+		return 0, "", err
+	}
+
+	resp, err := u.HTTPClient.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("upload request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated:
+		var video struct {
+			ID string `json:"id"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&video); err != nil || video.ID == "" {
+			return 0, "", errors.New("youtube upload finished without a video id")
+		}
+		return 0, video.ID, nil
+	case resp.StatusCode == statusResumeIncomplete:
+		// No Range header means nothing was received yet
+		match := rangeHeaderPattern.FindStringSubmatch(resp.Header.Get("Range"))
+		if match == nil {
+			return 0, "", nil
+		}
+		last, _ := strconv.ParseInt(match[1], 10, 64)
+		return last + 1, "", nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return 0, "", errSessionExpired
+	default:
+		return 0, "", parseAPIError(resp)
+	}
+}
+
+func (u *Uploader) finish(ctx context.Context, itemID, videoID string) (string, error) {
+	url := "https://youtube.com/shorts/" + videoID
+
+	// Store the URL right away so a crash before the scheduler marks the item as done doesn't re-upload
+	_, err := u.db.Exec(ctx,
+		`UPDATE upload_queue
+		 SET uploaded_url = $1, upload_session_uri = NULL, upload_offset = upload_size, updated_at = NOW()
+		 WHERE id = $2`,
+		url, itemID,
+	)
+	if err != nil {
+		log.Printf("YouTube upload: failed to store uploaded url for item %s: %v", itemID, err)
+	}
+	return url, nil
+}
+
+func (u *Uploader) loadState(ctx context.Context, itemID string) (*uploadState, error) {
+	var state uploadState
+	err := u.db.QueryRow(ctx,
+		`SELECT upload_session_uri, upload_offset, upload_size, uploaded_url FROM upload_queue WHERE id = $1`,
+		itemID,
+	).Scan(&state.sessionURI, &state.offset, &state.size, &state.uploadedURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load upload state: %w", err)
+	}
+	return &state, nil
+}
+
+func (u *Uploader) saveProgress(ctx context.Context, itemID string, sessionURI *string, offset, size int64) error {
+	_, err := u.db.Exec(ctx,
+		`UPDATE upload_queue
+		 SET upload_session_uri = $1, upload_offset = $2, upload_size = $3, heartbeat_at = NOW(), updated_at = NOW()
+		 WHERE id = $4`,
+		sessionURI, offset, size, itemID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save upload progress: %w", err)
+	}
+	return nil
+}
+
+func (u *Uploader) loadSettings(ctx context.Context, userID string) (*models.UserSettings, error) {
+	settings := models.DefaultUserSettings(userID)
+	err := u.db.QueryRow(ctx,
+		`SELECT COALESCE(default_visibility, 'public'), COALESCE(default_category, '24'), COALESCE(default_tags, '')
+		 FROM user_settings WHERE user_id = $1`,
+		userID,
+	).Scan(&settings.DefaultVisibility, &settings.DefaultCategory, &settings.DefaultTags)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, fmt.Errorf("failed to load user settings: %w", err)
+	}
+	return settings, nil
+}
+
+type videoInsert struct {
+	Snippet struct {
+		Title       string   `json:"title"`
+		Description string   `json:"description"`
+		Tags        []string `json:"tags,omitempty"`
+		CategoryID  string   `json:"categoryId"`
+	} `json:"snippet"`
+	Status struct {
+		PrivacyStatus           string     `json:"privacyStatus"`
+		PublishAt               *time.Time `json:"publishAt,omitempty"`
+		SelfDeclaredMadeForKids bool       `json:"selfDeclaredMadeForKids"`
+	} `json:"status"`
+}
+
+// videoMetadata builds the videos.insert body from the queue item and the user's defaults
+func videoMetadata(item *models.UploadQueueItem, settings *models.UserSettings) videoInsert {
+	var meta videoInsert
+	meta.Snippet.Title = item.Title
+	meta.Snippet.Description = "Uploaded via ViralCuts Dashboard"
+	if item.Description != nil && *item.Description != "" {
+		meta.Snippet.Description = *item.Description
+	}
+	meta.Snippet.Tags = settings.Tags()
+	meta.Snippet.CategoryID = settings.DefaultCategory
+
+	meta.Status.PrivacyStatus = item.PrivacyStatus
+	if meta.Status.PrivacyStatus == "" {
+		meta.Status.PrivacyStatus = settings.DefaultVisibility
+	}
+	// YouTube only accepts publishAt on private videos
+	if item.PublishAt != nil {
+		meta.Status.PrivacyStatus = "private"
+		meta.Status.PublishAt = item.PublishAt
+	}
+	return meta
+}
+
+// permanentIfRejected marks client errors (other than quota and auth) as not worth retrying
+func permanentIfRejected(err error) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest {
+		return worker.Permanent(err)
+	}
+	if errors.Is(err, ErrNotConnected) {
+		return worker.Permanent(err)
+	}
+	return err
+}
+
+// permanentIfMissing stops retrying when the source video was deleted
+func permanentIfMissing(err error) error {
+	if errors.Is(err, storage.ErrNotFound) {
+		return worker.Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,81 @@
+package youtube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"viral-cuts-server/models"
+)
+
+func TestVideoMetadata(t *testing.T) {
+	settings := models.DefaultUserSettings("user-1")
+	item := &models.UploadQueueItem{Title: "Best play"}
+
+	meta := videoMetadata(item, settings)
+	if meta.Snippet.Title != "Best play" || meta.Snippet.Description != "Uploaded via ViralCuts Dashboard" {
+		t.Errorf("snippet = %+v", meta.Snippet)
+	}
+	if len(meta.Snippet.Tags) != 3 || meta.Snippet.Tags[0] != "shorts" || meta.Snippet.CategoryID != "24" {
+		t.Errorf("defaults not applied: %+v", meta.Snippet)
+	}
+	if meta.Status.PrivacyStatus != "public" || meta.Status.PublishAt != nil {
+		t.Errorf("status = %+v, want the default visibility", meta.Status)
+	}
+
+	publishAt := time.Date(2026, 3, 1, 18, 0, 0, 0, time.UTC)
+	item.PrivacyStatus = "public"
+	item.PublishAt = &publishAt
+	meta = videoMetadata(item, settings)
+	if meta.Status.PrivacyStatus != "private" || meta.Status.PublishAt == nil || !meta.Status.PublishAt.Equal(publishAt) {
+		t.Errorf("status = %+v, want a private video with publishAt", meta.Status)
+	}
+}
+
+func TestSessionRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		rangeHdr   string
+		body       string
+		wantOffset int64
+		wantVideo  string
+		wantErr    error
+	}{
+		{name: "resume incomplete", status: statusResumeIncomplete, rangeHdr: "bytes=0-8388607", wantOffset: 8388608},
+		{name: "nothing received", status: statusResumeIncomplete},
+		{name: "finished", status: http.StatusCreated, body: `{"id":"abc123"}`, wantVideo: "abc123"},
+		{name: "expired", status: http.StatusNotFound, wantErr: errSessionExpired},
+		{name: "gone", status: http.StatusGone, wantErr: errSessionExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("Authorization"); got != "Bearer access-1" {
+					t.Errorf("Authorization = %q", got)
+				}
+				if tt.rangeHdr != "" {
+					w.Header().Set("Range", tt.rangeHdr)
+				}
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			uploader := NewUploader(nil, NewClient(staticTokens("access-1"), nil), nil)
+			req, _ := http.NewRequest("PUT", server.URL+"/session", nil)
+
+			offset, videoID, err := uploader.sessionRequest(context.Background(), "user-1", req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if offset != tt.wantOffset || videoID != tt.wantVideo {
+				t.Errorf("got (%d, %q), want (%d, %q)", offset, videoID, tt.wantOffset, tt.wantVideo)
+			}
+		})
+	}
+}